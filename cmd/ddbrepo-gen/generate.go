@@ -0,0 +1,576 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/rotmistrk/ddbrepo"
+	"go/ast"
+	"go/format"
+	"go/printer"
+	"go/token"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type valueKind int
+
+const (
+	kindString valueKind = iota
+	kindBool
+	kindInt
+	kindUint
+	kindFloat
+	kindBytes
+	kindTime
+	kindList
+	kindMap
+)
+
+type valueType struct {
+	kind    valueKind
+	goType  string
+	bits    int
+	pointer bool
+	elem    *valueType
+}
+
+func (t *valueType) isScalar() bool {
+	return t.kind <= kindFloat
+}
+
+type recordField struct {
+	goName       string
+	attr         string
+	constName    string
+	value        *valueType
+	hashKey      bool
+	rangeKey     bool
+	ttl          bool
+	omitEmpty    bool
+	null         bool
+	timeEncoding ddbrepo.TimeEncoding
+	gsi          map[string]bool
+	lsi          map[string]bool
+}
+
+func (f *recordField) isKey() bool {
+	return f.hashKey || f.rangeKey
+}
+
+type generator struct {
+	fset       *token.FileSet
+	pkg        string
+	qualifier  string
+	decls      map[string]ast.Expr
+	marshalers map[string]bool
+	imports    map[string]bool
+	out        bytes.Buffer
+}
+
+var basicTypes = map[string]valueType{
+	"string":  {kind: kindString},
+	"bool":    {kind: kindBool},
+	"int":     {kind: kindInt},
+	"int8":    {kind: kindInt, bits: 8},
+	"int16":   {kind: kindInt, bits: 16},
+	"int32":   {kind: kindInt, bits: 32},
+	"rune":    {kind: kindInt, bits: 32},
+	"int64":   {kind: kindInt, bits: 64},
+	"uint":    {kind: kindUint},
+	"uint8":   {kind: kindUint, bits: 8},
+	"byte":    {kind: kindUint, bits: 8},
+	"uint16":  {kind: kindUint, bits: 16},
+	"uint32":  {kind: kindUint, bits: 32},
+	"uint64":  {kind: kindUint, bits: 64},
+	"float32": {kind: kindFloat, bits: 32},
+	"float64": {kind: kindFloat, bits: 64},
+}
+
+var timeEncodingNames = map[ddbrepo.TimeEncoding]string{
+	ddbrepo.TimeUnix:        "TimeUnix",
+	ddbrepo.TimeUnixMilli:   "TimeUnixMilli",
+	ddbrepo.TimeUnixNano:    "TimeUnixNano",
+	ddbrepo.TimeRFC3339:     "TimeRFC3339",
+	ddbrepo.TimeRFC3339Nano: "TimeRFC3339Nano",
+}
+
+func generate(fset *token.FileSet, files []*ast.File, typeNames []string) ([]byte, error) {
+	if len(files) == 0 {
+		return nil, errors.New("no Go files to read")
+	}
+	g := &generator{
+		fset:       fset,
+		pkg:        files[0].Name.Name,
+		qualifier:  "ddbrepo.",
+		decls:      make(map[string]ast.Expr),
+		marshalers: make(map[string]bool),
+		imports:    make(map[string]bool),
+	}
+	if g.pkg == "ddbrepo" {
+		g.qualifier = ""
+	}
+	for _, file := range files {
+		g.collect(file)
+	}
+	for _, name := range typeNames {
+		structType, ok := g.decls[name].(*ast.StructType)
+		if !ok {
+			return nil, fmt.Errorf("%v is not a struct type of package %v", name, g.pkg)
+		}
+		fields, err := g.fields(name, structType)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", name, err)
+		}
+		g.emitType(name, fields)
+	}
+	return g.source()
+}
+
+func (g *generator) collect(file *ast.File) {
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				if spec, ok := spec.(*ast.TypeSpec); ok && spec.TypeParams == nil {
+					g.decls[spec.Name.Name] = spec.Type
+				}
+			}
+		case *ast.FuncDecl:
+			name := decl.Name.Name
+			if decl.Recv != nil && (name == "MarshalDynamoDBAttributeValue" || name == "UnmarshalDynamoDBAttributeValue") {
+				receiver := decl.Recv.List[0].Type
+				if star, ok := receiver.(*ast.StarExpr); ok {
+					receiver = star.X
+				}
+				if ident, ok := receiver.(*ast.Ident); ok {
+					g.marshalers[ident.Name] = true
+				}
+			}
+		}
+	}
+}
+
+func (g *generator) fields(typeName string, structType *ast.StructType) ([]*recordField, error) {
+	var result []*recordField
+	names := make(map[string]string)
+	for _, astField := range structType.Fields.List {
+		var tag reflect.StructTag
+		if astField.Tag != nil {
+			value, err := strconv.Unquote(astField.Tag.Value)
+			if err != nil {
+				return nil, err
+			}
+			tag = reflect.StructTag(value)
+		}
+		if len(astField.Names) == 0 {
+			return nil, fmt.Errorf("embedded field %v is not supported", g.text(astField.Type))
+		}
+		for _, name := range astField.Names {
+			field, err := g.field(name, astField.Type, tag)
+			if err != nil {
+				return nil, err
+			} else if field == nil {
+				continue
+			}
+			if other, found := names[field.attr]; found {
+				return nil, fmt.Errorf("fields %v and %v are both stored as %v", other, field.goName, field.attr)
+			}
+			names[field.attr] = field.goName
+			field.constName = typeName + "Attr" + field.goName
+			result = append(result, field)
+		}
+	}
+	return result, nil
+}
+
+func (g *generator) field(name *ast.Ident, fieldType ast.Expr, tag reflect.StructTag) (*recordField, error) {
+	_, ok := tag.Lookup(ddbrepo.TagDdb)
+	_, gsiOk := tag.Lookup(ddbrepo.TagDdbGsi)
+	_, lsiOk := tag.Lookup(ddbrepo.TagDdbLsi)
+	_, projectOk := tag.Lookup(ddbrepo.TagDdbProject)
+	if !name.IsExported() {
+		if ok || gsiOk || lsiOk || projectOk {
+			return nil, errors.New("can't handle ddb tags on private field " + name.Name)
+		}
+		return nil, nil
+	}
+	tags, err := ddbrepo.ParseFieldTags(name.Name, tag)
+	if err != nil || tags == nil {
+		return nil, err
+	}
+	field := &recordField{
+		goName:       name.Name,
+		attr:         tags.Name,
+		hashKey:      tags.HashKey,
+		rangeKey:     tags.RangeKey,
+		ttl:          tags.Ttl,
+		omitEmpty:    tags.OmitEmpty,
+		null:         tags.Null,
+		timeEncoding: tags.TimeEncoding,
+		gsi:          tags.GlobalIndexes,
+		lsi:          tags.LocalIndexes,
+	}
+	if !tags.Tagged {
+		field.attr = strings.ToLower(name.Name[0:1]) + name.Name[1:]
+	}
+	if field.value, err = g.resolve(fieldType); err != nil {
+		return nil, fmt.Errorf("field %v: %w", name.Name, err)
+	}
+	if field.isKey() || field.gsi != nil || field.lsi != nil {
+		if field.value.pointer || !(field.value.isScalar() || field.value.kind == kindBytes || field.value.kind == kindTime) || field.value.kind == kindBool {
+			return nil, fmt.Errorf("type %v is not supported as key for field %v", field.value.goType, name.Name)
+		}
+	}
+	return field, nil
+}
+
+func (g *generator) text(expr ast.Expr) string {
+	var buf bytes.Buffer
+	_ = printer.Fprint(&buf, g.fset, expr)
+	return buf.String()
+}
+
+func (g *generator) resolve(expr ast.Expr) (*valueType, error) {
+	unsupported := fmt.Errorf("type %v is not supported by ddbrepo-gen", g.text(expr))
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		if basic, found := basicTypes[expr.Name]; found {
+			basic.goType = expr.Name
+			return &basic, nil
+		}
+		declared, found := g.decls[expr.Name]
+		if !found || g.marshalers[expr.Name] {
+			return nil, unsupported
+		}
+		result, err := g.resolve(declared)
+		if err != nil {
+			return nil, unsupported
+		} else if result.pointer {
+			return nil, unsupported
+		}
+		result.goType = expr.Name
+		return result, nil
+	case *ast.SelectorExpr:
+		if pkg, ok := expr.X.(*ast.Ident); ok && pkg.Name == "time" && expr.Sel.Name == "Time" {
+			return &valueType{kind: kindTime, goType: "time.Time"}, nil
+		}
+	case *ast.StarExpr:
+		elem, err := g.resolve(expr.X)
+		if err != nil || elem.pointer || !(elem.isScalar() || elem.kind == kindTime) {
+			return nil, unsupported
+		}
+		elem.pointer = true
+		return elem, nil
+	case *ast.ArrayType:
+		if expr.Len != nil {
+			return nil, unsupported
+		} else if ident, ok := expr.Elt.(*ast.Ident); ok && (ident.Name == "byte" || ident.Name == "uint8") {
+			return &valueType{kind: kindBytes, goType: g.text(expr)}, nil
+		}
+		elem, err := g.resolve(expr.Elt)
+		if err != nil || elem.pointer || !elem.isScalar() {
+			return nil, unsupported
+		}
+		return &valueType{kind: kindList, goType: g.text(expr), elem: elem}, nil
+	case *ast.MapType:
+		if key, ok := expr.Key.(*ast.Ident); !ok || key.Name != "string" {
+			return nil, unsupported
+		}
+		elem, err := g.resolve(expr.Value)
+		if err != nil || elem.pointer || !elem.isScalar() {
+			return nil, unsupported
+		}
+		return &valueType{kind: kindMap, goType: g.text(expr), elem: elem}, nil
+	}
+	return nil, unsupported
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.out, format, args...)
+}
+
+func (g *generator) use(path string) {
+	g.imports[path] = true
+}
+
+func (g *generator) emitType(name string, fields []*recordField) {
+	g.use("github.com/aws/aws-sdk-go-v2/service/dynamodb/types")
+	g.printf("// Attribute names of %v.\nconst (\n", name)
+	for _, field := range fields {
+		g.printf("%v = %q\n", field.constName, field.attr)
+	}
+	g.printf(")\n\n")
+
+	g.printf("// MarshalDdbItem encodes %v as ddbrepo.Marshal does.\n", name)
+	g.printf("func (r *%v) MarshalDdbItem() (map[string]types.AttributeValue, error) {\n", name)
+	g.printf("item := make(map[string]types.AttributeValue, %v)\n", len(fields))
+	for _, field := range fields {
+		g.emitField(field)
+	}
+	g.printf("return item, nil\n}\n\n")
+
+	g.printf("// MarshalDdbKey encodes the key of %v as ddbrepo.MarshalKey does.\n", name)
+	g.printf("func (r *%v) MarshalDdbKey() (map[string]types.AttributeValue, error) {\n", name)
+	g.printf("item := make(map[string]types.AttributeValue, 2)\n")
+	for _, field := range fields {
+		if field.isKey() {
+			g.emitEncode("item["+field.constName+"]", "r."+field.goName, field)
+		}
+	}
+	g.printf("return item, nil\n}\n\n")
+
+	g.printf("// UnmarshalDdbItem fills %v from the item as ddbrepo.Unmarshal does.\n", name)
+	g.printf("func (r *%v) UnmarshalDdbItem(item map[string]types.AttributeValue) error {\n", name)
+	for _, field := range fields {
+		g.printf("if av, found := item[%v]; found {\n", field.constName)
+		g.emitDecode(field)
+		g.printf("}\n")
+	}
+	g.printf("return nil\n}\n\n")
+
+	g.emitKeySchema(name, fields)
+}
+
+func (g *generator) emitField(field *recordField) {
+	dest, source := "item["+field.constName+"]", "r."+field.goName
+	switch {
+	case field.isKey():
+		g.emitEncode(dest, source, field)
+	case field.null:
+		g.printf("if %v {\n%v = &types.AttributeValueMemberNULL{Value: true}\n} else {\n", g.emptyCondition(source, field.value, true), dest)
+		g.emitEncode(dest, source, field)
+		g.printf("}\n")
+	case field.omitEmpty || field.value.pointer:
+		g.printf("if %v {\n", g.emptyCondition(source, field.value, false))
+		g.emitEncode(dest, source, field)
+		g.printf("}\n")
+	default:
+		g.emitEncode(dest, source, field)
+	}
+}
+
+func (g *generator) emptyCondition(source string, value *valueType, empty bool) string {
+	condition := func(whenEmpty string, whenNot string) string {
+		if empty {
+			return whenEmpty
+		}
+		return whenNot
+	}
+	switch {
+	case value.pointer:
+		return source + condition(" == nil", " != nil")
+	case value.kind == kindString || value.kind == kindBytes || value.kind == kindList || value.kind == kindMap:
+		return "len(" + source + ")" + condition(" == 0", " > 0")
+	case value.kind == kindBool:
+		return condition("!", "") + source
+	case value.kind == kindTime:
+		g.use("time")
+		return source + condition(" == ", " != ") + "(time.Time{})"
+	}
+	return source + condition(" == 0", " != 0")
+}
+
+func (g *generator) emitEncode(dest string, source string, field *recordField) {
+	value := field.value
+	if value.pointer {
+		source = "*" + source
+	}
+	switch value.kind {
+	case kindTime:
+		g.printf("if av, err := %vMarshalTime(%v, %v); err != nil {\nreturn nil, err\n} else {\n%v = av\n}\n", g.qualifier, source, g.timeEncoding(field), dest)
+	case kindBytes:
+		g.printf("if %v == nil {\n%v = &types.AttributeValueMemberNULL{Value: true}\n} else {\n", source, dest)
+		g.printf("%v = &types.AttributeValueMemberB{Value: append([]byte{}, %v...)}\n}\n", dest, source)
+	case kindList:
+		g.printf("if %v == nil {\n%v = &types.AttributeValueMemberNULL{Value: true}\n} else {\n", source, dest)
+		g.printf("list := make([]types.AttributeValue, len(%v))\nfor i, v := range %v {\nlist[i] = %v\n}\n", source, source, g.scalar("v", value.elem))
+		g.printf("%v = &types.AttributeValueMemberL{Value: list}\n}\n", dest)
+	case kindMap:
+		g.printf("if %v == nil {\n%v = &types.AttributeValueMemberNULL{Value: true}\n} else {\n", source, dest)
+		g.printf("m := make(map[string]types.AttributeValue, len(%v))\nfor k, v := range %v {\nm[k] = %v\n}\n", source, source, g.scalar("v", value.elem))
+		g.printf("%v = &types.AttributeValueMemberM{Value: m}\n}\n", dest)
+	default:
+		g.printf("%v = %v\n", dest, g.scalar(source, value))
+	}
+}
+
+func convert(from string, source string, to string) string {
+	if from == to {
+		return source
+	}
+	return to + "(" + source + ")"
+}
+
+func (g *generator) scalar(source string, value *valueType) string {
+	switch value.kind {
+	case kindString:
+		return fmt.Sprintf("&types.AttributeValueMemberS{Value: %v}", convert(value.goType, source, "string"))
+	case kindBool:
+		return fmt.Sprintf("&types.AttributeValueMemberBOOL{Value: %v}", convert(value.goType, source, "bool"))
+	}
+	g.use("strconv")
+	switch value.kind {
+	case kindInt:
+		return fmt.Sprintf("&types.AttributeValueMemberN{Value: strconv.FormatInt(%v, 10)}", convert(value.goType, source, "int64"))
+	case kindUint:
+		return fmt.Sprintf("&types.AttributeValueMemberN{Value: strconv.FormatUint(%v, 10)}", convert(value.goType, source, "uint64"))
+	}
+	return fmt.Sprintf("&types.AttributeValueMemberN{Value: strconv.FormatFloat(%v, 'f', -1, %v)}", convert(value.goType, source, "float64"), value.bits)
+}
+
+func (g *generator) timeEncoding(field *recordField) string {
+	encoding := field.timeEncoding
+	if encoding == "" && (field.isKey() || field.ttl || field.gsi != nil || field.lsi != nil) {
+		encoding = ddbrepo.TimeUnix
+	} else if encoding == "" {
+		encoding = ddbrepo.TimeRFC3339Nano
+	}
+	return g.qualifier + timeEncodingNames[encoding]
+}
+
+func (g *generator) decoder(field string, av string, value *valueType) (string, string) {
+	switch value.kind {
+	case kindString:
+		return fmt.Sprintf("%vUnmarshalString(%q, %v)", g.qualifier, field, av), "string"
+	case kindBool:
+		return fmt.Sprintf("%vUnmarshalBool(%q, %v)", g.qualifier, field, av), "bool"
+	case kindInt:
+		return fmt.Sprintf("%vUnmarshalInt(%q, %v, %v)", g.qualifier, field, av, value.bits), "int64"
+	case kindUint:
+		return fmt.Sprintf("%vUnmarshalUint(%q, %v, %v)", g.qualifier, field, av, value.bits), "uint64"
+	case kindFloat:
+		return fmt.Sprintf("%vUnmarshalFloat(%q, %v, %v)", g.qualifier, field, av, value.bits), "float64"
+	case kindBytes:
+		return fmt.Sprintf("%vUnmarshalBytes(%q, %v)", g.qualifier, field, av), "[]byte"
+	}
+	return "", ""
+}
+
+func (g *generator) emitDecode(field *recordField) {
+	value, target := field.value, "r."+field.goName
+	switch value.kind {
+	case kindList:
+		g.printf("list, found, err := %vUnmarshalList(%q, av)\nif err != nil {\nreturn err\n} else if !found {\n%v = nil\n} else {\n", g.qualifier, field.goName, target)
+		call, basic := g.decoder(field.goName, "elem", value.elem)
+		g.printf("%v = make(%v, len(list))\nfor i, elem := range list {\n", target, value.goType)
+		g.printf("v, err := %v\nif err != nil {\nreturn err\n}\n%v[i] = %v\n}\n}\n", call, target, convert(basic, "v", value.elem.goType))
+	case kindMap:
+		g.printf("m, found, err := %vUnmarshalMap(%q, av)\nif err != nil {\nreturn err\n} else if !found {\n%v = nil\n} else {\n", g.qualifier, field.goName, target)
+		call, basic := g.decoder(field.goName, "elem", value.elem)
+		g.printf("%v = make(%v, len(m))\nfor k, elem := range m {\n", target, value.goType)
+		g.printf("v, err := %v\nif err != nil {\nreturn err\n}\n%v[k] = %v\n}\n}\n", call, target, convert(basic, "v", value.elem.goType))
+	default:
+		call, basic := g.decoder(field.goName, "av", value)
+		if value.kind == kindTime {
+			call, basic = fmt.Sprintf("%vUnmarshalTime(%q, av, %v)", g.qualifier, field.goName, g.timeEncoding(field)), "time.Time"
+		}
+		if value.pointer {
+			g.printf("if _, null := av.(*types.AttributeValueMemberNULL); null {\n%v = nil\n} else ", target)
+		}
+		g.printf("if v, err := %v; err != nil {\nreturn err\n} else {\n", call)
+		if value.pointer {
+			g.printf("pv := %v\n%v = &pv\n}\n", convert(basic, "v", value.goType), target)
+		} else {
+			g.printf("%v = %v\n}\n", target, convert(basic, "v", value.goType))
+		}
+	}
+}
+
+func (g *generator) emitKeySchema(name string, fields []*recordField) {
+	g.use("github.com/aws/aws-sdk-go-v2/aws")
+	element := func(field *recordField, hash bool) {
+		keyType := "types.KeyTypeRange"
+		if hash {
+			keyType = "types.KeyTypeHash"
+		}
+		g.printf("{AttributeName: aws.String(%v), KeyType: %v},\n", field.constName, keyType)
+	}
+	g.printf("// DdbKeySchema describes the keys of %v for ddbrepo.New.\n", name)
+	g.printf("func (*%v) DdbKeySchema() %vKeySchema {\nreturn %vKeySchema{\nTable: []types.KeySchemaElement{\n", name, g.qualifier, g.qualifier)
+	for _, field := range fields {
+		if field.isKey() {
+			element(field, field.hashKey)
+		}
+	}
+	g.printf("},\n")
+	var indexes []string
+	for _, field := range fields {
+		for index := range field.gsi {
+			if !contains(indexes, index) {
+				indexes = append(indexes, index)
+			}
+		}
+		for index := range field.lsi {
+			if !contains(indexes, index) {
+				indexes = append(indexes, index)
+			}
+		}
+	}
+	sort.Strings(indexes)
+	if len(indexes) > 0 {
+		g.printf("Indexes: map[string][]types.KeySchemaElement{\n")
+		for _, index := range indexes {
+			g.printf("%q: {\n", index)
+			for _, field := range fields {
+				if hash, found := field.gsi[index]; found {
+					element(field, hash)
+				}
+			}
+			if isLocalIndex(fields, index) {
+				for _, field := range fields {
+					if field.hashKey {
+						element(field, true)
+					}
+				}
+				for _, field := range fields {
+					if hash, found := field.lsi[index]; found && !hash {
+						element(field, false)
+					}
+				}
+			}
+			g.printf("},\n")
+		}
+		g.printf("},\n")
+	}
+	g.printf("}\n}\n\n")
+}
+
+func isLocalIndex(fields []*recordField, index string) bool {
+	for _, field := range fields {
+		if _, found := field.lsi[index]; found {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *generator) source() ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by ddbrepo-gen. DO NOT EDIT.\n\npackage %v\n\nimport (\n", g.pkg)
+	if g.qualifier != "" {
+		g.use("github.com/rotmistrk/ddbrepo")
+	}
+	paths := make([]string, 0, len(g.imports))
+	for path := range g.imports {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		fmt.Fprintf(&buf, "%q\n", path)
+	}
+	buf.WriteString(")\n\n")
+	buf.Write(g.out.Bytes())
+	return format.Source(buf.Bytes())
+}
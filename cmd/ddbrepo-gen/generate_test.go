@@ -0,0 +1,62 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestGenerate_Fixture(t *testing.T) {
+	fset := token.NewFileSet()
+	files, err := parsePackage(fset, "../..", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := generate(fset, files, []string{"genRecord"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile("../../genrecord_ddbgen_test.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("generated code differs from genrecord_ddbgen_test.go, run go generate:\n%s", got)
+	}
+}
+
+func TestGenerate_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		wantErr string
+	}{
+		{"not a struct", "type record string", "not a struct type"},
+		{"embedded", "type base struct{}\ntype record struct {\n\tbase\n}", "embedded field base"},
+		{"nested", "type address struct{}\ntype record struct {\n\tHome address\n}", "type address is not supported"},
+		{"marshaler", "type shade int\nfunc (s shade) MarshalDynamoDBAttributeValue() {}\ntype record struct {\n\tShade shade\n}", "type shade is not supported"},
+		{"pointer key", "type record struct {\n\tID *string `ddb:\"id,hash-key\"`\n}", "not supported as key"},
+		{"private tagged", "type record struct {\n\tid string `ddb:\"id\"`\n}", "private field id"},
+		{"string expiration", "type record struct {\n\tTTL time.Time `ddb:\"ttl,expire,time=rfc3339\"`\n}", "must be stored as a number"},
+		{"unknown annotation", "type record struct {\n\tID string `ddb:\"id,hashkey\"`\n}", "unknown annotation"},
+		{"unknown projection", "type record struct {\n\tID string `ddb:\"id,hash-key\" ddb-gsi:\"byId hash-key sideways\"`\n}", "unknown projection"},
+		{"rule without value", "type record struct {\n\tID string `ddb:\"id,hash-key,min\"`\n}", "unknown annotation"},
+		{"duplicate name", "type record struct {\n\tA string `ddb:\"x\"`\n\tB string `ddb:\"x\"`\n}", "both stored as x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fset := token.NewFileSet()
+			file, err := parser.ParseFile(fset, "record.go", "package records\n\nimport \"time\"\n\nvar _ time.Time\n\n"+tt.source, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = generate(fset, []*ast.File{file}, []string{"record"})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("generate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
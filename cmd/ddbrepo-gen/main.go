@@ -0,0 +1,86 @@
+// Command ddbrepo-gen generates reflection-free marshalling for records
+// stored with ddbrepo. Run it with go generate from the package of the
+// records:
+//
+//	//go:generate go run github.com/rotmistrk/ddbrepo/cmd/ddbrepo-gen -type Order,Customer
+//
+// For every type it emits constants with the attribute names, the
+// MarshalDdbItem, MarshalDdbKey and UnmarshalDdbItem methods that DdbRepo
+// uses instead of reflection, and a DdbKeySchema descriptor that New checks
+// against the tags. Nested and embedded structs, converters and custom
+// attributevalue marshalers are left to the reflective path and rejected.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	typeNames := flag.String("type", "", "comma-separated list of the struct types to generate code for")
+	output := flag.String("output", "", "output file name; default <type>_ddbgen.go in the package directory")
+	tests := flag.Bool("tests", false, "read the _test.go files of the package too")
+	flag.Parse()
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+	if err := run(dir, *typeNames, *output, *tests); err != nil {
+		fmt.Fprintln(os.Stderr, "ddbrepo-gen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(dir string, typeNames string, output string, tests bool) error {
+	if typeNames == "" {
+		return errors.New("-type is required")
+	}
+	names := strings.Split(typeNames, ",")
+	fset := token.NewFileSet()
+	files, err := parsePackage(fset, dir, tests)
+	if err != nil {
+		return err
+	}
+	source, err := generate(fset, files, names)
+	if err != nil {
+		return err
+	}
+	if output == "" {
+		output = strings.ToLower(names[0]) + "_ddbgen.go"
+		if tests {
+			output = strings.ToLower(names[0]) + "_ddbgen_test.go"
+		}
+	}
+	if !filepath.IsAbs(output) {
+		output = filepath.Join(dir, output)
+	}
+	return os.WriteFile(output, source, 0644)
+}
+
+func parsePackage(fset *token.FileSet, dir string, tests bool) ([]*ast.File, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	var result []*ast.File
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.go") && !tests {
+			continue
+		}
+		file, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		} else if ast.IsGenerated(file) || strings.HasSuffix(file.Name.Name, "_test") {
+			continue
+		}
+		result = append(result, file)
+	}
+	return result, nil
+}
@@ -0,0 +1,224 @@
+package ddbrepo
+
+import (
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type fieldEncoder func(value reflect.Value, encoding TimeEncoding) (types.AttributeValue, error)
+
+type fieldDecoder func(av types.AttributeValue, destination reflect.Value, encoding TimeEncoding) (bool, error)
+
+type codecField struct {
+	index     []int
+	field     reflect.StructField
+	spec      *fieldSpec
+	encode    fieldEncoder
+	decode    fieldDecoder
+	converted bool
+}
+
+type structCodec struct {
+	fields    []codecField
+	byName    map[string]*codecField
+	checked   bool
+	converted bool
+}
+
+type codecKey struct {
+	structType              reflect.Type
+	allowUntaggedFields     bool
+	lowercaseUntaggedFields bool
+	registry                uint64
+	converters              uint64
+}
+
+var codecCache sync.Map
+
+func codecOf(props parseProps, structType reflect.Type) (*structCodec, error) {
+	key := codecKey{structType, props.AllowUntaggedFields(), props.LowercaseUntaggedFields(), registryGeneration.Load(), 0}
+	if props, ok := props.(converterProps); ok {
+		key.converters = props.converterSetID()
+	}
+	if cached, found := codecCache.Load(key); found {
+		return cached.(*structCodec), nil
+	}
+	codec := &structCodec{byName: make(map[string]*codecField)}
+	if err := codec.addFields(props, structType, nil, map[reflect.Type]bool{structType: true}); err != nil {
+		return nil, err
+	}
+	for i := range codec.fields {
+		field := &codec.fields[i]
+		codec.byName[field.field.Name] = field
+		codec.byName[field.spec.name] = field
+		field.encode, field.decode = compileEncoder(field.field.Type), compileDecoder(field.field.Type)
+		field.converted = reachesConverter(props, field.field.Type, map[reflect.Type]bool{structType: true})
+		codec.checked = codec.checked || field.spec.IsRequired() || len(field.spec.rules) > 0
+		codec.converted = codec.converted || field.converted
+	}
+	cached, _ := codecCache.LoadOrStore(key, codec)
+	return cached.(*structCodec), nil
+}
+
+func (c *structCodec) addFields(props parseProps, structType reflect.Type, index []int, embedding map[reflect.Type]bool) error {
+	for i, I := 0, structType.NumField(); i < I; i++ {
+		field := structType.Field(i)
+		fieldIndex := append(append(make([]int, 0, len(index)+1), index...), i)
+		if embedded, flatten := embeddedType(&field); flatten {
+			if embedded != nil && !embedding[embedded] {
+				embedding[embedded] = true
+				if err := c.addFields(props, embedded, fieldIndex, embedding); err != nil {
+					return err
+				}
+				delete(embedding, embedded)
+			}
+			continue
+		}
+		spec, err := newFieldSpec(props, &field)
+		if err != nil {
+			return err
+		} else if spec != nil {
+			c.fields = append(c.fields, codecField{index: fieldIndex, field: field, spec: spec})
+		}
+	}
+	return nil
+}
+
+func (f *codecField) value(structValue reflect.Value, alloc bool) (reflect.Value, bool) {
+	value := structValue
+	for i, x := range f.index {
+		if i > 0 && value.Kind() == reflect.Pointer {
+			if value.IsNil() {
+				if !alloc || !value.CanSet() {
+					return reflect.Value{}, false
+				}
+				value.Set(reflect.New(value.Type().Elem()))
+			}
+			value = value.Elem()
+		}
+		value = value.Field(x)
+	}
+	return value, true
+}
+
+var numberType = reflect.TypeOf((*interface {
+	Float64() (float64, error)
+	Int64() (int64, error)
+	String() string
+})(nil)).Elem()
+
+func isPlainScalar(t reflect.Type) bool {
+	if t.Implements(avMarshalerType) || reflect.PointerTo(t).Implements(avMarshalerType) || reflect.PointerTo(t).Implements(avUnmarshalerType) {
+		return false
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return !t.Implements(numberType)
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func compileEncoder(t reflect.Type) fieldEncoder {
+	if t == timeType {
+		return func(value reflect.Value, encoding TimeEncoding) (types.AttributeValue, error) {
+			return encodeTime(value.Interface().(time.Time), encoding)
+		}
+	} else if !isPlainScalar(t) {
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return func(value reflect.Value, _ TimeEncoding) (types.AttributeValue, error) {
+			return &types.AttributeValueMemberS{Value: value.String()}, nil
+		}
+	case reflect.Bool:
+		return func(value reflect.Value, _ TimeEncoding) (types.AttributeValue, error) {
+			return &types.AttributeValueMemberBOOL{Value: value.Bool()}, nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(value reflect.Value, _ TimeEncoding) (types.AttributeValue, error) {
+			return &types.AttributeValueMemberN{Value: strconv.FormatInt(value.Int(), 10)}, nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return func(value reflect.Value, _ TimeEncoding) (types.AttributeValue, error) {
+			return &types.AttributeValueMemberN{Value: strconv.FormatUint(value.Uint(), 10)}, nil
+		}
+	}
+	bits := t.Bits()
+	return func(value reflect.Value, _ TimeEncoding) (types.AttributeValue, error) {
+		return &types.AttributeValueMemberN{Value: strconv.FormatFloat(value.Float(), 'f', -1, bits)}, nil
+	}
+}
+
+// Scalars of the wrong attribute type or out of range are left to
+// attributevalue for its error.
+func compileDecoder(t reflect.Type) fieldDecoder {
+	if t == timeType {
+		return func(av types.AttributeValue, destination reflect.Value, encoding TimeEncoding) (bool, error) {
+			if _, null := av.(*types.AttributeValueMemberNULL); null {
+				destination.SetZero()
+				return true, nil
+			}
+			decoded, err := decodeTime(av, encoding)
+			if err == nil {
+				destination.Set(reflect.ValueOf(decoded))
+			}
+			return true, err
+		}
+	} else if !isPlainScalar(t) {
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return func(av types.AttributeValue, destination reflect.Value, _ TimeEncoding) (bool, error) {
+			s, ok := av.(*types.AttributeValueMemberS)
+			if ok {
+				destination.SetString(s.Value)
+			}
+			return ok, nil
+		}
+	case reflect.Bool:
+		return func(av types.AttributeValue, destination reflect.Value, _ TimeEncoding) (bool, error) {
+			b, ok := av.(*types.AttributeValueMemberBOOL)
+			if ok {
+				destination.SetBool(b.Value)
+			}
+			return ok, nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(av types.AttributeValue, destination reflect.Value, _ TimeEncoding) (bool, error) {
+			if n, ok := av.(*types.AttributeValueMemberN); ok {
+				if i, err := strconv.ParseInt(n.Value, 10, 64); err == nil && !destination.OverflowInt(i) {
+					destination.SetInt(i)
+					return true, nil
+				}
+			}
+			return false, nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return func(av types.AttributeValue, destination reflect.Value, _ TimeEncoding) (bool, error) {
+			if n, ok := av.(*types.AttributeValueMemberN); ok {
+				if u, err := strconv.ParseUint(n.Value, 10, 64); err == nil && !destination.OverflowUint(u) {
+					destination.SetUint(u)
+					return true, nil
+				}
+			}
+			return false, nil
+		}
+	}
+	return func(av types.AttributeValue, destination reflect.Value, _ TimeEncoding) (bool, error) {
+		if n, ok := av.(*types.AttributeValueMemberN); ok {
+			if f, err := strconv.ParseFloat(n.Value, 64); err == nil && !destination.OverflowFloat(f) {
+				destination.SetFloat(f)
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+}
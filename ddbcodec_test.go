@@ -0,0 +1,233 @@
+package ddbrepo
+
+import (
+	"encoding/json"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/rotmistrk/must"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type benchAddress struct {
+	Street string `ddb:"street"`
+	City   string `ddb:"city,required"`
+}
+
+type benchRecord struct {
+	ID      string            `ddb:"id,hash-key"`
+	Created time.Time         `ddb:"created,range-key"`
+	Version int               `ddb:"version,version"`
+	Name    string            `ddb:"name,min=1,max=64"`
+	Email   string            `ddb:"email,omitempty"`
+	Score   float64           `ddb:"score"`
+	Tags    []string          `ddb:"tags"`
+	Attrs   map[string]string `ddb:"attrs"`
+	Home    benchAddress      `ddb:"home"`
+	Expires int64             `ddb:"expires,expire"`
+}
+
+func newBenchRecord() *benchRecord {
+	return &benchRecord{
+		ID:      "user-42",
+		Created: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Version: 7,
+		Name:    "Ann",
+		Email:   "ann@example.com",
+		Score:   98.5,
+		Tags:    []string{"a", "b", "c"},
+		Attrs:   map[string]string{"plan": "pro"},
+		Home:    benchAddress{Street: "Main 1", City: "Oslo"},
+		Expires: 1700000000,
+	}
+}
+
+func TestCodecOf(t *testing.T) {
+	repo := must.Must(New[benchRecord]())
+	codec := must.Must(codecOf(repo, reflect.TypeOf(benchRecord{})))
+	if again := must.Must(codecOf(repo, reflect.TypeOf(benchRecord{}))); again != codec {
+		t.Errorf("codecOf() built the codec twice")
+	}
+	if other := must.Must(codecOf(&props{true, false}, reflect.TypeOf(benchRecord{}))); other == codec {
+		t.Errorf("codecOf() shared the codec across tag settings")
+	}
+	if field := codec.byName["Home"]; field == nil || field.spec.name != "home" || codec.byName["home"] != field {
+		t.Errorf("codecOf() field Home = %+v", field)
+	}
+	type embedding struct {
+		ID string `ddb:"id,hash-key"`
+		*benchAddress
+	}
+	fields := must.Must(codecOf(repo, reflect.TypeOf(embedding{}))).fields
+	if len(fields) != 3 || !reflect.DeepEqual(fields[2].index, []int{1, 1}) {
+		t.Errorf("codecOf() embedded fields = %+v", fields)
+	}
+	type malformed struct {
+		ID string `ddb:"id,hash-key,bad-tag"`
+	}
+	if _, err := Marshal(repo, &malformed{}); err == nil {
+		t.Errorf("Marshal() accepted a malformed tag")
+	}
+	if _, err := Marshal(repo, benchRecord{}); err == nil {
+		t.Errorf("Marshal() accepted a struct value")
+	}
+}
+
+type compiledScalars struct {
+	Text    string
+	Flag    bool
+	Small   int8
+	Count   uint32
+	Ratio   float32
+	Amount  float64
+	Number  json.Number
+	Named   KeyType
+	Created time.Time
+}
+
+func TestCodecOf_CompiledFields(t *testing.T) {
+	codec := must.Must(codecOf(&props{true, true}, reflect.TypeOf(compiledScalars{})))
+	source := reflect.ValueOf(compiledScalars{
+		Text: "text", Flag: true, Small: -8, Count: 32, Ratio: 0.1, Amount: 1e21, Number: "12.5", Named: "key",
+		Created: time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC),
+	})
+	for _, field := range codec.fields {
+		value := source.FieldByIndex(field.index)
+		if field.field.Name == "Number" {
+			if field.encode != nil || field.decode != nil {
+				t.Errorf("compiled a codec for %v, which attributevalue stores as a number", field.field.Type)
+			}
+			continue
+		} else if field.encode == nil || field.decode == nil {
+			t.Errorf("no compiled codec for %v", field.field.Name)
+			continue
+		}
+		got := must.Must(field.encode(value, TimeRFC3339Nano))
+		if want := getAv(value.Interface()); !reflect.DeepEqual(got, want) {
+			t.Errorf("encode() of %v = %v, want %v", field.field.Name, JsonLine(&got), JsonLine(&want))
+		}
+		restored := reflect.New(value.Type()).Elem()
+		if decoded, err := field.decode(got, restored, TimeRFC3339Nano); !decoded || err != nil || !reflect.DeepEqual(restored.Interface(), value.Interface()) {
+			t.Errorf("decode() of %v = %v, %v, %v", field.field.Name, restored, decoded, err)
+		}
+	}
+	small := reflect.New(reflect.TypeOf(int8(0))).Elem()
+	if decoded, _ := codec.byName["Small"].decode(&types.AttributeValueMemberN{Value: "300"}, small, ""); decoded {
+		t.Errorf("decode() accepted an overflowing number")
+	}
+	if decoded, _ := codec.byName["Text"].decode(&types.AttributeValueMemberN{Value: "1"}, small, ""); decoded {
+		t.Errorf("decode() accepted a number for a string")
+	}
+}
+
+func TestCodecOf_Converters(t *testing.T) {
+	converted := func(props parseProps) []string {
+		var result []string
+		for _, field := range must.Must(codecOf(props, reflect.TypeOf(hostRecord{}))).fields {
+			if field.converted {
+				result = append(result, field.field.Name)
+			}
+		}
+		return result
+	}
+	plain := &props{true, true}
+	if got := converted(plain); got != nil {
+		t.Errorf("converted fields without converters = %v", got)
+	}
+	RegisterConverter(addrConverter)
+	defer func() {
+		defaultConvertersMutex.Lock()
+		defer defaultConvertersMutex.Unlock()
+		delete(defaultConverters, addrConverter.goType)
+		registryGeneration.Add(1)
+	}()
+	if got, want := converted(plain), []string{"Addr", "Backups"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("converted fields after RegisterConverter() = %v, want %v", got, want)
+	}
+	repo := must.Must(New[hostRecord]()).WithConverters(centsConverter)
+	if got, want := converted(repo), []string{"Addr", "Price", "Backups", "Fees", "Refund"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("converted fields of the repo = %v, want %v", got, want)
+	}
+}
+
+func baselineMarshal(props parseProps, source interface{}) (map[string]types.AttributeValue, error) {
+	result := make(map[string]types.AttributeValue)
+	err := listStructFieldsCbk(source, func(fldNum int, field *reflect.StructField, value *reflect.Value) error {
+		spec, err := newFieldSpec(props, field)
+		if err == nil && spec != nil {
+			result[spec.name], err = attributevalue.Marshal(value.Interface())
+		}
+		return err
+	})
+	return result, err
+}
+
+func baselineUnmarshal(props parseProps, target interface{}, item map[string]types.AttributeValue) error {
+	return listStructFieldsCbk(target, func(fldNum int, field *reflect.StructField, value *reflect.Value) error {
+		spec, err := newFieldSpec(props, field)
+		if err != nil || spec == nil {
+			return err
+		} else if av, found := item[spec.name]; found {
+			return attributevalue.Unmarshal(av, value.Addr().Interface())
+		}
+		return nil
+	})
+}
+
+func BenchmarkMarshal(b *testing.B) {
+	repo := must.Must(New[benchRecord]())
+	record := newBenchRecord()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := Marshal(repo, record); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMarshal_Baseline(b *testing.B) {
+	repo := must.Must(New[benchRecord]())
+	record := newBenchRecord()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := baselineMarshal(repo, record); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnmarshal(b *testing.B) {
+	repo := must.Must(New[benchRecord]())
+	item := must.Must(Marshal(repo, newBenchRecord()))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var record benchRecord
+		if err := Unmarshal(repo, &record, item); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnmarshal_Baseline(b *testing.B) {
+	repo := must.Must(New[benchRecord]())
+	item := must.Must(baselineMarshal(repo, newBenchRecord()))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var record benchRecord
+		if err := baselineUnmarshal(repo, &record, item); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMarshalKey(b *testing.B) {
+	repo := must.Must(New[benchRecord]())
+	record := newBenchRecord()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := MarshalKey(repo, record, ""); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
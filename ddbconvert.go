@@ -0,0 +1,158 @@
+package ddbrepo
+
+import (
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"maps"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// Converter encodes the values of one Go type; the scalar type is declared
+// for key attributes of the type.
+type Converter struct {
+	goType     reflect.Type
+	scalarType types.ScalarAttributeType
+	encode     func(value reflect.Value) (types.AttributeValue, error)
+	decode     func(av types.AttributeValue, target reflect.Value) error
+}
+
+func NewConverter[V any](scalarType types.ScalarAttributeType, encode func(value V) (types.AttributeValue, error), decode func(av types.AttributeValue) (V, error)) Converter {
+	return Converter{
+		goType:     reflect.TypeOf((*V)(nil)).Elem(),
+		scalarType: scalarType,
+		encode: func(value reflect.Value) (types.AttributeValue, error) {
+			return encode(value.Interface().(V))
+		},
+		decode: func(av types.AttributeValue, target reflect.Value) error {
+			value, err := decode(av)
+			if err == nil {
+				target.Set(reflect.ValueOf(&value).Elem())
+			}
+			return err
+		},
+	}
+}
+
+// NewStringConverter stores the values of a type as strings.
+func NewStringConverter[V any](format func(value V) string, parse func(s string) (V, error)) Converter {
+	return NewConverter(types.ScalarAttributeTypeS,
+		func(value V) (types.AttributeValue, error) {
+			return &types.AttributeValueMemberS{Value: format(value)}, nil
+		},
+		func(av types.AttributeValue) (V, error) {
+			if s, ok := av.(*types.AttributeValueMemberS); ok {
+				return parse(s.Value)
+			}
+			var zero V
+			return zero, fmt.Errorf("can't unmarshal %T into %T", av, zero)
+		})
+}
+
+var (
+	defaultConverters      = make(map[reflect.Type]Converter)
+	defaultConvertersMutex sync.RWMutex
+	// tell the codecs cached for other converters apart
+	registryGeneration atomic.Uint64
+	converterSets      atomic.Uint64
+)
+
+// RegisterConverter adds converters used by every repo that has no converter
+// of its own for the type.
+func RegisterConverter(converters ...Converter) {
+	defaultConvertersMutex.Lock()
+	defer defaultConvertersMutex.Unlock()
+	for _, converter := range converters {
+		defaultConverters[converter.goType] = converter
+	}
+	registryGeneration.Add(1)
+}
+
+// WithConverters adds converters that take precedence over the registered
+// ones.
+func (repo DdbRepo[T]) WithConverters(converters ...Converter) *DdbRepo[T] {
+	repo.converters = maps.Clone(repo.converters)
+	if repo.converters == nil {
+		repo.converters = make(map[reflect.Type]Converter)
+	}
+	for _, converter := range converters {
+		repo.converters[converter.goType] = converter
+	}
+	repo.convertersID = converterSets.Add(1)
+	repo.redefineAttributes()
+	return &repo
+}
+
+func (repo *DdbRepo[T]) converter(t reflect.Type) (Converter, bool) {
+	converter, found := repo.converters[t]
+	return converter, found
+}
+
+func (repo *DdbRepo[T]) converterSetID() uint64 {
+	return repo.convertersID
+}
+
+type converterProps interface {
+	converter(t reflect.Type) (Converter, bool)
+	converterSetID() uint64
+}
+
+func reachesConverter(props parseProps, t reflect.Type, visited map[reflect.Type]bool) bool {
+	for {
+		if _, found := converterFor(props, t); found {
+			return true
+		}
+		switch t.Kind() {
+		case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map:
+			t = t.Elem()
+		case reflect.Struct:
+			if visited[t] {
+				return false
+			}
+			visited[t] = true
+			for i := 0; i < t.NumField(); i++ {
+				if reachesConverter(props, t.Field(i).Type, visited) {
+					return true
+				}
+			}
+			return false
+		default:
+			return false
+		}
+	}
+}
+
+func converterFor(props parseProps, t reflect.Type) (Converter, bool) {
+	if props, ok := props.(converterProps); ok {
+		if converter, found := props.converter(t); found {
+			return converter, true
+		}
+	}
+	defaultConvertersMutex.RLock()
+	defer defaultConvertersMutex.RUnlock()
+	converter, found := defaultConverters[t]
+	return converter, found
+}
+
+func marshalerScalarType(t reflect.Type) (types.ScalarAttributeType, error) {
+	value := reflect.New(t)
+	if t.Implements(avMarshalerType) {
+		value = value.Elem()
+	}
+	av, err := value.Interface().(attributevalue.Marshaler).MarshalDynamoDBAttributeValue()
+	if err != nil {
+		return "", err
+	}
+	switch av.(type) {
+	case *types.AttributeValueMemberS:
+		return types.ScalarAttributeTypeS, nil
+	case *types.AttributeValueMemberN:
+		return types.ScalarAttributeTypeN, nil
+	case *types.AttributeValueMemberB:
+		return types.ScalarAttributeTypeB, nil
+	}
+	return "", errors.New(fmt.Sprintf("%v is marshalled as %T which can't be a key", t, av))
+}
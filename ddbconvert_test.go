@@ -0,0 +1,124 @@
+package ddbrepo
+
+import (
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/rotmistrk/ddbrepo/ddbrepotest"
+	"github.com/rotmistrk/must"
+	"net/netip"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type cents int64
+
+type shade int
+
+const (
+	shadeRed shade = iota + 1
+	shadeBlue
+)
+
+func (s shade) MarshalDynamoDBAttributeValue() (types.AttributeValue, error) {
+	return &types.AttributeValueMemberS{Value: [...]string{"none", "red", "blue"}[s]}, nil
+}
+
+func (s *shade) UnmarshalDynamoDBAttributeValue(av types.AttributeValue) error {
+	if v, ok := av.(*types.AttributeValueMemberS); ok {
+		*s = shade(map[string]int{"red": 1, "blue": 2}[v.Value])
+		return nil
+	}
+	return errors.New("shade must be a string")
+}
+
+var centsConverter = NewConverter(types.ScalarAttributeTypeN,
+	func(value cents) (types.AttributeValue, error) {
+		return &types.AttributeValueMemberN{Value: strconv.FormatFloat(float64(value)/100, 'f', 2, 64)}, nil
+	},
+	func(av types.AttributeValue) (cents, error) {
+		n, ok := av.(*types.AttributeValueMemberN)
+		if !ok {
+			return 0, errors.New("cents must be a number")
+		}
+		units, fraction, _ := strings.Cut(n.Value, ".")
+		value, err := strconv.ParseInt(units+(fraction + "00")[:2], 10, 64)
+		return cents(value), err
+	})
+
+var addrConverter = NewStringConverter(netip.Addr.String, netip.ParseAddr)
+
+type hostRecord struct {
+	Addr    netip.Addr       `ddb:"addr,hash-key"`
+	Price   cents            `ddb:"price,range-key"`
+	Shade   shade            `ddb:"shade" ddb-gsi:"byShade hash-key"`
+	Backups []netip.Addr     `ddb:"backups"`
+	Fees    map[string]cents `ddb:"fees"`
+	Refund  *cents           `ddb:"refund"`
+}
+
+func TestConverters(t *testing.T) {
+	RegisterConverter(addrConverter)
+	defer func() {
+		defaultConvertersMutex.Lock()
+		defer defaultConvertersMutex.Unlock()
+		delete(defaultConverters, addrConverter.goType)
+		registryGeneration.Add(1)
+	}()
+	plain := must.Must(New[hostRecord]()).WithTableName("hosts").WithDynamoDbApi(ddbrepotest.NewMemoryDynamoDb())
+	repo := plain.WithConverters(centsConverter)
+	definitions := make(map[string]types.ScalarAttributeType)
+	for _, def := range repo.attributeDefinitions {
+		definitions[aws.ToString(def.AttributeName)] = def.AttributeType
+	}
+	wantDefinitions := map[string]types.ScalarAttributeType{"addr": "S", "price": "N", "shade": "S"}
+	if !reflect.DeepEqual(definitions, wantDefinitions) {
+		t.Errorf("attribute definitions = %v, want %v", definitions, wantDefinitions)
+	}
+
+	record := &hostRecord{
+		Addr:    netip.MustParseAddr("10.0.0.1"),
+		Price:   1234,
+		Shade:   shadeBlue,
+		Backups: []netip.Addr{netip.MustParseAddr("10.0.0.2")},
+		Fees:    map[string]cents{"setup": 505},
+	}
+	item := must.Must(Marshal(repo, record))
+	want := map[string]types.AttributeValue{
+		"addr":    &types.AttributeValueMemberS{Value: "10.0.0.1"},
+		"price":   &types.AttributeValueMemberN{Value: "12.34"},
+		"shade":   &types.AttributeValueMemberS{Value: "blue"},
+		"backups": &types.AttributeValueMemberL{Value: []types.AttributeValue{&types.AttributeValueMemberS{Value: "10.0.0.2"}}},
+		"fees":    &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{"setup": &types.AttributeValueMemberN{Value: "5.05"}}},
+	}
+	if !reflect.DeepEqual(item, want) {
+		t.Errorf("Marshal() = %v, want %v", JsonLine(&item), JsonLine(&want))
+	}
+	var restored hostRecord
+	if err := Unmarshal(repo, &restored, item); err != nil || !reflect.DeepEqual(&restored, record) {
+		t.Errorf("Unmarshal() = %+v, %v, want %+v", restored, err, record)
+	}
+	if price := must.Must(Marshal(plain, record))["price"]; !reflect.DeepEqual(price, &types.AttributeValueMemberN{Value: "1234"}) {
+		t.Errorf("repo converters leaked to the original repo: price = %v", price)
+	}
+
+	if err := repo.TableCreate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.PutItem(record); err != nil {
+		t.Fatal(err)
+	}
+	var found []*hostRecord
+	collect := func(r *hostRecord) error {
+		found = append(found, r)
+		return nil
+	}
+	if err := QueryHkCbk(repo, collect, &hostRecord{Addr: record.Addr}, RangeKeyGreaterThan(cents(1000))); err != nil || len(found) != 1 {
+		t.Errorf("QueryHkCbk() on converted keys found %v, %v", len(found), err)
+	}
+	if err := QueryHkCbk(repo, collect, &hostRecord{Shade: shadeBlue}, OnIndex("byShade")); err != nil || len(found) != 2 || found[1].Price != 1234 {
+		t.Errorf("QueryHkCbk() on a marshaler index key found %v, %v", len(found), err)
+	}
+}
@@ -0,0 +1,107 @@
+package ddbrepo
+
+import (
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrNotFound        = errors.New("item not found")
+	ErrConditionFailed = errors.New("condition failed")
+	ErrVersionConflict = errors.New("version conflict")
+	ErrThrottled       = errors.New("request throttled")
+	ErrValidation      = errors.New("validation failed")
+	ErrTableNotReady   = errors.New("table not ready")
+	ErrSchemaDrift     = errors.New("schema drift")
+)
+
+// OpError is returned by item operations; Kind is one of the Err* sentinels
+// when the failure is classified and Err is the underlying cause.
+type OpError struct {
+	Op    string
+	Table string
+	Key   map[string]types.AttributeValue
+	Kind  error
+	Err   error
+}
+
+func (e *OpError) Error() string {
+	var sb strings.Builder
+	sb.WriteString(e.Op + " on " + e.Table)
+	if len(e.Key) > 0 {
+		names := make([]string, 0, len(e.Key))
+		for name := range e.Key {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		parts := make([]string, 0, len(names))
+		for _, name := range names {
+			parts = append(parts, name+"="+keyValueString(e.Key[name]))
+		}
+		sb.WriteString(" [" + strings.Join(parts, ", ") + "]")
+	}
+	if e.Kind != nil {
+		sb.WriteString(": " + e.Kind.Error())
+	}
+	if e.Err != nil {
+		sb.WriteString(": " + e.Err.Error())
+	}
+	return sb.String()
+}
+
+func (e *OpError) Unwrap() []error {
+	result := make([]error, 0, 2)
+	for _, err := range []error{e.Kind, e.Err} {
+		if err != nil {
+			result = append(result, err)
+		}
+	}
+	return result
+}
+
+var throttlingCodes = map[string]bool{
+	"ProvisionedThroughputExceededException": true,
+	"RequestLimitExceeded":                   true,
+	"ThrottlingException":                    true,
+	"Throttling":                             true,
+}
+
+func classifyError(err error) error {
+	var apiErr smithy.APIError
+	switch {
+	case errors.Is(err, ErrVersionConflict):
+		return ErrVersionConflict
+	case errors.As(err, new(*types.ConditionalCheckFailedException)):
+		return ErrConditionFailed
+	case errors.As(err, new(*types.ResourceNotFoundException)):
+		return ErrTableNotReady
+	case errors.As(err, &apiErr):
+		if throttlingCodes[apiErr.ErrorCode()] {
+			return ErrThrottled
+		}
+	}
+	return nil
+}
+
+func isAccessDenied(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "AccessDeniedException"
+}
+
+func opError(op string, table string, key map[string]types.AttributeValue, err error) error {
+	if err == nil {
+		return nil
+	}
+	var existing *OpError
+	if errors.As(err, &existing) {
+		return err
+	}
+	return &OpError{Op: op, Table: table, Key: key, Kind: classifyError(err), Err: err}
+}
+
+func notFoundError(op string, table string, key map[string]types.AttributeValue) error {
+	return &OpError{Op: op, Table: table, Key: key, Kind: ErrNotFound}
+}
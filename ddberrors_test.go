@@ -0,0 +1,94 @@
+package ddbrepo
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+	"github.com/rotmistrk/ddbrepo/ddbrepotest"
+	"github.com/rotmistrk/must"
+	"testing"
+)
+
+type throttledApi struct {
+	*ddbrepotest.MemoryDynamoDb
+}
+
+func (api *throttledApi) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	return nil, &types.ProvisionedThroughputExceededException{Message: aws.String("slow down")}
+}
+
+func (api *throttledApi) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	return nil, &smithy.GenericAPIError{Code: "ThrottlingException", Message: "rate exceeded"}
+}
+
+func TestOpError_Classification(t *testing.T) {
+	db := ddbrepotest.NewMemoryDynamoDb()
+	repo := must.Must(New[sampleRecord]()).WithTableName("errors").WithDynamoDbApi(db)
+	if err := repo.TableCreate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.PutItem(&sampleRecord{ID: "stored", Name: "stored"}); err != nil {
+		t.Fatal(err)
+	}
+	throttled := repo.WithDynamoDbApi(&throttledApi{db})
+	missingTable := repo.WithTableName("missing")
+	noop := func(r *sampleRecord) error { return nil }
+	tests := []struct {
+		name    string
+		call    func() error
+		wantOp  string
+		wantErr error
+	}{
+		{"get not found", func() error { return repo.GetItem(&sampleRecord{ID: "absent"}) }, "GetItem", ErrNotFound},
+		{"get throttled", func() error { return throttled.GetItem(&sampleRecord{ID: "stored"}) }, "GetItem", ErrThrottled},
+		{"put condition", func() error { return repo.PutItemOp(&sampleRecord{ID: "stored"}, Insert) }, "PutItem", ErrConditionFailed},
+		{"put conditional", func() error {
+			return repo.PutConditional(&sampleRecord{ID: "new"}, "attribute_exists(id)", nil)
+		}, "PutItem", ErrConditionFailed},
+		{"delete missing table", func() error { return missingTable.DelItemOp(&sampleRecord{ID: "stored"}) }, "DeleteItem", ErrTableNotReady},
+		{"query missing table", func() error { return QueryHkCbk(missingTable, noop, &sampleRecord{ID: "stored"}) }, "Query", ErrTableNotReady},
+		{"scan throttled", func() error { return throttled.ScanCbk(noop) }, "Scan", ErrThrottled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+			var opErr *OpError
+			if !errors.Is(err, tt.wantErr) || !errors.As(err, &opErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if opErr.Op != tt.wantOp || opErr.Table == "" {
+				t.Errorf("error = %+v, want operation %v with table", opErr, tt.wantOp)
+			}
+			if tt.wantErr != ErrNotFound && opErr.Err == nil {
+				t.Errorf("error %v does not wrap the cause", err)
+			}
+		})
+	}
+	var opErr *OpError
+	err := repo.PutConditional(&sampleRecord{ID: "new"}, "attribute_exists(", nil)
+	if !errors.As(err, &opErr) || opErr.Kind != nil || errors.Is(err, ErrValidation) {
+		t.Errorf("PutConditional() of a malformed condition error = %v, want it unclassified", err)
+	}
+	var cause *types.ConditionalCheckFailedException
+	if err := repo.PutItemOp(&sampleRecord{ID: "stored"}, Insert); !errors.As(err, &cause) {
+		t.Errorf("PutItemOp() error = %v, want it to wrap the SDK exception", err)
+	}
+	callbackErr := errors.New("callback")
+	if err := repo.ScanCbk(func(r *sampleRecord) error { return callbackErr }); err != callbackErr {
+		t.Errorf("ScanCbk() error = %v, want the callback error unwrapped", err)
+	}
+}
+
+func TestClassifyError_Unclassified(t *testing.T) {
+	for _, err := range []error{
+		&types.ResourceInUseException{Message: aws.String("table exists")},
+		&smithy.GenericAPIError{Code: "ValidationException", Message: "item size has exceeded the maximum allowed size"},
+	} {
+		if kind := classifyError(err); kind != nil {
+			t.Errorf("classifyError(%v) = %v, want it unclassified", err, kind)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package ddbrepo
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// ItemMarshaler is implemented by records with code generated by
+// cmd/ddbrepo-gen; the generated methods encode the record as the reflective
+// path does with the default repo settings.
+type ItemMarshaler interface {
+	MarshalDdbItem() (map[string]types.AttributeValue, error)
+	MarshalDdbKey() (map[string]types.AttributeValue, error)
+}
+
+// ItemUnmarshaler is the decoding counterpart of ItemMarshaler.
+type ItemUnmarshaler interface {
+	UnmarshalDdbItem(item map[string]types.AttributeValue) error
+}
+
+// KeySchemaDescriber is implemented by records with generated code; New
+// compares the described keys with the tags to detect stale generated code.
+type KeySchemaDescriber interface {
+	DdbKeySchema() KeySchema
+}
+
+// KeySchema lists the key attributes of the table and of its global and
+// local secondary indexes.
+type KeySchema struct {
+	Table   []types.KeySchemaElement
+	Indexes map[string][]types.KeySchemaElement
+}
+
+func useGenerated(props parseProps, source interface{}) bool {
+	if !props.AllowUntaggedFields() || !props.LowercaseUntaggedFields() {
+		return false
+	} else if omit, ok := props.(omitProps); ok && omit.OmitEmptyFields() {
+		return false
+	} else if timeProps, ok := props.(timeProps); ok && timeProps.TimeEncoding() != DefaultTimeEncoding {
+		return false
+	}
+	sourceType := reflect.TypeOf(source)
+	if sourceType.Kind() != reflect.Pointer || sourceType.Elem().Kind() != reflect.Struct {
+		return false
+	}
+	codec, err := codecOf(props, sourceType.Elem())
+	return err == nil && !codec.converted
+}
+
+func validateFields(props parseProps, source interface{}, filter func(spec fieldSpec) bool, invalid *fieldErrors) error {
+	value, err := getValidMarshallingTargetValue(source)
+	if err != nil {
+		return err
+	}
+	codec, err := codecOf(props, value.Type())
+	if err != nil || !codec.checked {
+		return err
+	}
+	for i := range codec.fields {
+		field := &codec.fields[i]
+		if filter != nil && !filter(*field.spec) {
+			continue
+		}
+		fieldValue, found := field.value(value, false)
+		if !found {
+			continue
+		}
+		if field.spec.IsRequired() && fieldValue.IsZero() {
+			invalid.add(field.field.Name, field.spec, TagItemRequired, "is required")
+		}
+		field.spec.validateValue(field.field.Name, fieldValue, invalid)
+	}
+	return nil
+}
+
+func checkRequiredAttributes(props parseProps, target interface{}, item map[string]types.AttributeValue, invalid *fieldErrors) error {
+	codec, err := codecOf(props, reflect.TypeOf(target).Elem())
+	if err != nil {
+		return err
+	}
+	for i := range codec.fields {
+		field := &codec.fields[i]
+		if field.spec.IsRequired() && isZeroValue(item[field.spec.name]) {
+			invalid.add(field.field.Name, field.spec, TagItemRequired, "is missing from the item")
+		}
+	}
+	return nil
+}
+
+func sameKeySchema(a, b []types.KeySchemaElement) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, x := range a {
+		found := false
+		for _, y := range b {
+			found = found || aws.ToString(x.AttributeName) == aws.ToString(y.AttributeName) && x.KeyType == y.KeyType
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (repo *DdbRepo[T]) checkGeneratedKeySchema(schema KeySchema) error {
+	stale := fmt.Errorf("generated code of %v doesn't match its tags, run go generate", reflect.TypeOf((*T)(nil)).Elem())
+	if !sameKeySchema(schema.Table, repo.keySchema) || len(schema.Indexes) != len(repo.gsi)+len(repo.lsi) {
+		return stale
+	}
+	for name, keys := range schema.Indexes {
+		if indexKeys, found := repo.indexKeySchema(name); !found || !sameKeySchema(keys, indexKeys) {
+			return stale
+		}
+	}
+	return nil
+}
+
+// MarshalTime encodes a time for generated code.
+func MarshalTime(t time.Time, encoding TimeEncoding) (types.AttributeValue, error) {
+	return encodeTime(t, encoding)
+}
+
+// UnmarshalTime decodes a time for generated code; NULL is the zero time.
+func UnmarshalTime(field string, av types.AttributeValue, encoding TimeEncoding) (time.Time, error) {
+	if _, null := av.(*types.AttributeValueMemberNULL); null {
+		return time.Time{}, nil
+	}
+	t, err := decodeTime(av, encoding)
+	if err != nil {
+		return t, fmt.Errorf("%v: %w", field, err)
+	}
+	return t, nil
+}
+
+func mismatchError(field string, av types.AttributeValue, kind string) error {
+	return fmt.Errorf("%v: can't unmarshal %T into %v", field, av, kind)
+}
+
+// UnmarshalString decodes a string for generated code; NULL is the empty
+// string.
+func UnmarshalString(field string, av types.AttributeValue) (string, error) {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberS:
+		return v.Value, nil
+	case *types.AttributeValueMemberNULL:
+		return "", nil
+	}
+	return "", mismatchError(field, av, "string")
+}
+
+// UnmarshalBool decodes a bool for generated code; NULL is false.
+func UnmarshalBool(field string, av types.AttributeValue) (bool, error) {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberBOOL:
+		return v.Value, nil
+	case *types.AttributeValueMemberNULL:
+		return false, nil
+	}
+	return false, mismatchError(field, av, "bool")
+}
+
+func numberOfAttribute(field string, av types.AttributeValue, kind string) (string, bool, error) {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberN:
+		return v.Value, true, nil
+	case *types.AttributeValueMemberNULL:
+		return "", false, nil
+	}
+	return "", false, mismatchError(field, av, kind)
+}
+
+// UnmarshalInt decodes a signed integer of the bit size for generated code;
+// NULL is zero.
+func UnmarshalInt(field string, av types.AttributeValue, bitSize int) (int64, error) {
+	n, found, err := numberOfAttribute(field, av, "int")
+	if !found {
+		return 0, err
+	}
+	result, err := strconv.ParseInt(n, 10, bitSize)
+	if err != nil {
+		return 0, fmt.Errorf("%v: %w", field, err)
+	}
+	return result, nil
+}
+
+// UnmarshalUint decodes an unsigned integer of the bit size for generated
+// code; NULL is zero.
+func UnmarshalUint(field string, av types.AttributeValue, bitSize int) (uint64, error) {
+	n, found, err := numberOfAttribute(field, av, "uint")
+	if !found {
+		return 0, err
+	}
+	result, err := strconv.ParseUint(n, 10, bitSize)
+	if err != nil {
+		return 0, fmt.Errorf("%v: %w", field, err)
+	}
+	return result, nil
+}
+
+// UnmarshalFloat decodes a float of the bit size for generated code; NULL is
+// zero.
+func UnmarshalFloat(field string, av types.AttributeValue, bitSize int) (float64, error) {
+	n, found, err := numberOfAttribute(field, av, "float")
+	if !found {
+		return 0, err
+	}
+	result, err := strconv.ParseFloat(n, bitSize)
+	if err != nil {
+		return 0, fmt.Errorf("%v: %w", field, err)
+	}
+	return result, nil
+}
+
+// UnmarshalBytes decodes binary data for generated code; NULL is nil.
+func UnmarshalBytes(field string, av types.AttributeValue) ([]byte, error) {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberB:
+		return append([]byte{}, v.Value...), nil
+	case *types.AttributeValueMemberNULL:
+		return nil, nil
+	}
+	return nil, mismatchError(field, av, "[]byte")
+}
+
+// UnmarshalList returns the elements of a list or of a string or number set
+// for generated code; NULL is a nil list.
+func UnmarshalList(field string, av types.AttributeValue) ([]types.AttributeValue, bool, error) {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberL:
+		return v.Value, true, nil
+	case *types.AttributeValueMemberSS:
+		result := make([]types.AttributeValue, len(v.Value))
+		for i, s := range v.Value {
+			result[i] = &types.AttributeValueMemberS{Value: s}
+		}
+		return result, true, nil
+	case *types.AttributeValueMemberNS:
+		result := make([]types.AttributeValue, len(v.Value))
+		for i, n := range v.Value {
+			result[i] = &types.AttributeValueMemberN{Value: n}
+		}
+		return result, true, nil
+	case *types.AttributeValueMemberNULL:
+		return nil, false, nil
+	}
+	return nil, false, mismatchError(field, av, "list")
+}
+
+// UnmarshalMap returns the attributes of a map for generated code; NULL is a
+// nil map.
+func UnmarshalMap(field string, av types.AttributeValue) (map[string]types.AttributeValue, bool, error) {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberM:
+		return v.Value, true, nil
+	case *types.AttributeValueMemberNULL:
+		return nil, false, nil
+	}
+	return nil, false, mismatchError(field, av, "map")
+}
@@ -0,0 +1,36 @@
+package ddbrepo
+
+import (
+	"time"
+)
+
+//go:generate go run ./cmd/ddbrepo-gen -type genRecord -tests
+
+type genStatus string
+
+type genScores []float32
+
+type genRecord struct {
+	Tenant   string            `ddb:"tenant,hash-key"`
+	Created  time.Time         `ddb:"created,range-key,time=unixmilli"`
+	Owner    string            `ddb:"owner,required,min=2" ddb-gsi:"byOwner hash-key"`
+	Status   genStatus         `ddb:"status,oneof=new done" ddb-gsi:"byOwner range-key"`
+	Version  int64             `ddb:"version,version"`
+	Title    string            `ddb:"title,omitempty"`
+	Note     *string           `ddb:"note,null"`
+	Count    *int              `ddb:"count"`
+	Active   bool              `ddb:"active"`
+	Priority int               `ddb:"priority" ddb-lsi:"byPriority range-key"`
+	Ratio    float64           `ddb:"ratio"`
+	Small    uint8             `ddb:"small"`
+	Data     []byte            `ddb:"data"`
+	Tags     []string          `ddb:"tags"`
+	Scores   genScores         `ddb:"scores,omitempty"`
+	Labels   map[string]string `ddb:"labels"`
+	Updated  time.Time         `ddb:"updated,null"`
+	Seen     *time.Time        `ddb:"seen,time=rfc3339"`
+	Expires  time.Time         `ddb:"expires,expire"`
+	Comment  string
+	Internal string `ddb:",ignore"`
+	hidden   int
+}
@@ -0,0 +1,180 @@
+package ddbrepo
+
+import (
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/rotmistrk/ddbrepo/ddbrepotest"
+	"github.com/rotmistrk/must"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newGenRecord() *genRecord {
+	note, count := "n", 0
+	seen := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	return &genRecord{
+		Tenant:   "t1",
+		Created:  time.Date(2024, 1, 2, 3, 4, 5, 6000000, time.UTC),
+		Owner:    "ann",
+		Status:   "new",
+		Version:  3,
+		Title:    "title",
+		Note:     &note,
+		Count:    &count,
+		Active:   true,
+		Ratio:    0.25,
+		Small:    7,
+		Data:     []byte{1, 2},
+		Tags:     []string{"a", ""},
+		Scores:   genScores{1.5, 2},
+		Labels:   map[string]string{"k": "v"},
+		Updated:  time.Date(2024, 3, 4, 5, 6, 7, 8, time.UTC),
+		Seen:     &seen,
+		Expires:  time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+		Comment:  "c",
+		Internal: "i",
+	}
+}
+
+func TestGenerated_MatchesReflection(t *testing.T) {
+	repo := must.Must(New[genRecord]())
+	tests := []struct {
+		name   string
+		record *genRecord
+	}{
+		{"full", newGenRecord()},
+		{"empty", &genRecord{Tenant: "t1", Owner: "ann"}},
+		{"empty collections", &genRecord{Tenant: "t1", Owner: "ann", Data: []byte{}, Tags: []string{}, Scores: genScores{}, Labels: map[string]string{}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			generated := must.Must(Marshal(repo, tt.record))
+			var invalid fieldErrors
+			reflected := must.Must(marshalFiltered(repo, tt.record, IncludeAll, "", &invalid))
+			if !reflect.DeepEqual(generated, reflected) {
+				t.Errorf("Marshal() = %v, reflection gives %v", JsonLine(&generated), JsonLine(&reflected))
+			}
+			generatedKey := must.Must(MarshalKey(repo, tt.record, "pk_"))
+			reflectedKey := must.Must(MarshalTagFilter(repo, tt.record, IncludeKey, "pk_"))
+			if !reflect.DeepEqual(generatedKey, reflectedKey) {
+				t.Errorf("MarshalKey() = %v, reflection gives %v", JsonLine(&generatedKey), JsonLine(&reflectedKey))
+			}
+			var decoded, reflectDecoded genRecord
+			if err := Unmarshal(repo, &decoded, generated); err != nil {
+				t.Fatal(err)
+			}
+			if err := unmarshalFields(repo, "", generated, reflect.ValueOf(&reflectDecoded).Elem(), &invalid); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(decoded, reflectDecoded) {
+				t.Errorf("Unmarshal() = %+v, reflection gives %+v", decoded, reflectDecoded)
+			}
+		})
+	}
+}
+
+func TestGenerated_Settings(t *testing.T) {
+	record := newGenRecord()
+	tests := []struct {
+		name      string
+		props     parseProps
+		generated bool
+	}{
+		{"defaults", must.Must(New[genRecord]()), true},
+		{"time encoding", must.Must(New[genRecord]()).WithTimeEncoding(TimeUnix), false},
+		{"omit empty", must.Must(New[genRecord]()).WithOmitEmptyFields(true), false},
+		{"converters", must.Must(New[genRecord]()).WithConverters(NewStringConverter(func(v genStatus) string { return "x" }, func(s string) (genStatus, error) { return genStatus(s), nil })), false},
+		{"element converter", must.Must(New[genRecord]()).WithConverters(NewConverter(types.ScalarAttributeTypeN, func(v float32) (types.AttributeValue, error) {
+			return &types.AttributeValueMemberN{Value: "0"}, nil
+		}, func(av types.AttributeValue) (float32, error) { return 0, nil })), false},
+		{"unrelated converter", must.Must(New[genRecord]()).WithConverters(NewStringConverter(func(v int8) string { return "x" }, func(s string) (int8, error) { return 0, nil })), true},
+		{"tagged only", &props{allowUntagged: false}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := useGenerated(tt.props, record); got != tt.generated {
+				t.Errorf("useGenerated() = %v, want %v", got, tt.generated)
+			}
+			if _, err := Marshal(tt.props, record); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestGenerated_Validation(t *testing.T) {
+	repo := must.Must(New[genRecord]())
+	_, err := Marshal(repo, &genRecord{Tenant: "t1", Status: "gone"})
+	if got, want := validationFields(err), []string{"Owner", "Status"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Marshal() invalid fields = %v, want %v", got, want)
+	}
+	strict := repo.WithStrictUnmarshal(true)
+	var record genRecord
+	err = Unmarshal(strict, &record, map[string]types.AttributeValue{"tenant": &types.AttributeValueMemberS{Value: "t1"}})
+	if !errors.Is(err, ErrValidation) || record.Tenant != "t1" {
+		t.Errorf("strict Unmarshal() = %v with %+v", err, record)
+	}
+}
+
+func TestGenerated_PutGet(t *testing.T) {
+	db := ddbrepotest.NewMemoryDynamoDb()
+	repo := must.Must(New[genRecord]()).WithTableName("generated").WithDynamoDbApi(db)
+	if err := repo.TableCreate(); err != nil {
+		t.Fatal(err)
+	}
+	record := newGenRecord()
+	if err := repo.PutItem(record); err != nil {
+		t.Fatal(err)
+	}
+	got := &genRecord{Tenant: record.Tenant, Created: record.Created}
+	if err := repo.GetItem(got); err != nil {
+		t.Fatal(err)
+	}
+	record.Internal = ""
+	if !reflect.DeepEqual(got, record) {
+		t.Errorf("GetItem() = %+v, want %+v", got, record)
+	}
+}
+
+type staleRecord struct {
+	ID   string `ddb:"id,hash-key"`
+	Name string `ddb:"name"`
+}
+
+func (*staleRecord) DdbKeySchema() KeySchema {
+	return KeySchema{Table: []types.KeySchemaElement{{AttributeName: aws.String("name"), KeyType: types.KeyTypeHash}}}
+}
+
+func TestGenerated_StaleKeySchema(t *testing.T) {
+	if _, err := New[staleRecord](); err == nil {
+		t.Error("New() accepted a key schema that doesn't match the tags")
+	}
+	if _, err := New[genRecord](); err != nil {
+		t.Error(err)
+	}
+}
+
+func BenchmarkMarshal_Generated(b *testing.B) {
+	repo := must.Must(New[genRecord]())
+	record := newGenRecord()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := Marshal(repo, record); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMarshal_GeneratedReflection(b *testing.B) {
+	repo := must.Must(New[genRecord]())
+	record := newGenRecord()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var invalid fieldErrors
+		if _, err := marshalFiltered(repo, record, IncludeAll, "", &invalid); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
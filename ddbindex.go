@@ -0,0 +1,102 @@
+package ddbrepo
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"sort"
+)
+
+// MaxLocalSecondaryIndexes is the number of local secondary indexes DynamoDB
+// allows on a table.
+const MaxLocalSecondaryIndexes = 5
+
+func (repo *DdbRepo[T]) completeLocalIndexes() error {
+	if len(repo.lsi) > MaxLocalSecondaryIndexes {
+		return fmt.Errorf("%v local secondary indexes declared, at most %v are allowed", len(repo.lsi), MaxLocalSecondaryIndexes)
+	}
+	hashKey, rangeKey := keyNames(repo.keySchema)
+	for name, lsi := range repo.lsi {
+		var indexRange *types.KeySchemaElement
+		for i, key := range lsi.KeySchema {
+			switch {
+			case key.KeyType == types.KeyTypeHash && aws.ToString(key.AttributeName) != hashKey:
+				return fmt.Errorf("local secondary index %v must share the hash key %v of the table, not %v", name, hashKey, aws.ToString(key.AttributeName))
+			case key.KeyType == types.KeyTypeRange && indexRange != nil:
+				return fmt.Errorf("local secondary index %v has more than one range key", name)
+			case key.KeyType == types.KeyTypeRange:
+				indexRange = &lsi.KeySchema[i]
+			}
+		}
+		if indexRange == nil {
+			return fmt.Errorf("local secondary index %v has no range key", name)
+		} else if rangeKey == "" {
+			return fmt.Errorf("local secondary index %v needs a table with a range key", name)
+		} else if aws.ToString(indexRange.AttributeName) == rangeKey {
+			return fmt.Errorf("local secondary index %v must not use the range key %v of the table", name, rangeKey)
+		}
+		lsi.KeySchema = []types.KeySchemaElement{indexKeyElement(hashKey, true), *indexRange}
+		repo.lsi[name] = lsi
+	}
+	return nil
+}
+
+func (repo *DdbRepo[T]) projectAttributes(projected map[string][]string) error {
+	for index, attributes := range projected {
+		projection := repo.indexProjection(index)
+		if projection == nil {
+			return fmt.Errorf("%v of %v refers to unknown index %v", TagDdbProject, attributes[0], index)
+		} else if projection.ProjectionType != types.ProjectionTypeInclude {
+			return fmt.Errorf("index %v projects %v, %v needs the %v projection", index, projection.ProjectionType, attributes, TagProjectionInclude)
+		}
+		projection.NonKeyAttributes = attributes
+	}
+	for _, name := range repo.indexNames() {
+		if projection := repo.indexProjection(name); projection.ProjectionType == types.ProjectionTypeInclude && len(projection.NonKeyAttributes) == 0 {
+			return fmt.Errorf("index %v has the %v projection but no field tagged %v", name, TagProjectionInclude, TagDdbProject)
+		}
+	}
+	return nil
+}
+
+func (repo *DdbRepo[T]) indexProjection(index string) *types.Projection {
+	if gsi, found := repo.gsi[index]; found {
+		return gsi.Projection
+	} else if lsi, found := repo.lsi[index]; found {
+		return lsi.Projection
+	}
+	return nil
+}
+
+func (repo *DdbRepo[T]) indexNames() []string {
+	names := make([]string, 0, len(repo.gsi)+len(repo.lsi))
+	for name := range repo.gsi {
+		names = append(names, name)
+	}
+	for name := range repo.lsi {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (repo *DdbRepo[T]) indexKeySchema(index string) ([]types.KeySchemaElement, bool) {
+	if gsi, found := repo.gsi[index]; found {
+		return gsi.KeySchema, true
+	} else if lsi, found := repo.lsi[index]; found {
+		return lsi.KeySchema, true
+	}
+	return nil, false
+}
+
+func keyNames(schema []types.KeySchemaElement) (hashKey string, rangeKey string) {
+	for _, key := range schema {
+		switch key.KeyType {
+		case types.KeyTypeHash:
+			hashKey = aws.ToString(key.AttributeName)
+		case types.KeyTypeRange:
+			rangeKey = aws.ToString(key.AttributeName)
+		}
+	}
+	return
+}
@@ -0,0 +1,240 @@
+package ddbrepo
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/rotmistrk/ddbrepo/ddbrepotest"
+	"github.com/rotmistrk/must"
+	"reflect"
+	"testing"
+)
+
+type listingLsiRecord struct {
+	Seller string `ddb:"seller,hash-key"`
+	SKU    string `ddb:"sku,range-key"`
+	Price  int    `ddb:"price" ddb-lsi:"byPrice range-key"`
+	Listed string `ddb:"listed" ddb-lsi:"byListed range-key include"`
+	Title  string `ddb:"title" ddb-project:"byListed"`
+	Body   string `ddb:"body"`
+}
+
+func TestNew_LocalIndexes(t *testing.T) {
+	repo := must.Must(New[listingLsiRecord]())
+	want := map[string]types.LocalSecondaryIndex{
+		"byPrice": {
+			IndexName: aws.String("byPrice"),
+			KeySchema: []types.KeySchemaElement{
+				{AttributeName: aws.String("seller"), KeyType: types.KeyTypeHash},
+				{AttributeName: aws.String("price"), KeyType: types.KeyTypeRange},
+			},
+			Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+		},
+		"byListed": {
+			IndexName: aws.String("byListed"),
+			KeySchema: []types.KeySchemaElement{
+				{AttributeName: aws.String("seller"), KeyType: types.KeyTypeHash},
+				{AttributeName: aws.String("listed"), KeyType: types.KeyTypeRange},
+			},
+			Projection: &types.Projection{ProjectionType: types.ProjectionTypeInclude, NonKeyAttributes: []string{"title"}},
+		},
+	}
+	if !reflect.DeepEqual(repo.lsi, want) {
+		t.Errorf("New() lsi = %v, want %v", JsonLine(&repo.lsi), JsonLine(&want))
+	}
+	definitions := make(map[string]types.ScalarAttributeType)
+	for _, def := range repo.attributeDefinitions {
+		definitions[aws.ToString(def.AttributeName)] = def.AttributeType
+	}
+	wantDefinitions := map[string]types.ScalarAttributeType{"seller": "S", "sku": "S", "price": "N", "listed": "S"}
+	if !reflect.DeepEqual(definitions, wantDefinitions) {
+		t.Errorf("New() attribute definitions = %v, want %v", definitions, wantDefinitions)
+	}
+}
+
+type lsiNoRangeRecord struct {
+	ID    string `ddb:"id,hash-key"`
+	Price int    `ddb:"price" ddb-lsi:"byPrice range-key"`
+}
+
+type lsiOtherHashRecord struct {
+	ID    string `ddb:"id,hash-key"`
+	SKU   string `ddb:"sku,range-key"`
+	Shop  string `ddb:"shop" ddb-lsi:"byPrice hash-key"`
+	Price int    `ddb:"price" ddb-lsi:"byPrice range-key"`
+}
+
+type lsiTwoRangesRecord struct {
+	ID    string `ddb:"id,hash-key"`
+	SKU   string `ddb:"sku,range-key"`
+	Price int    `ddb:"price" ddb-lsi:"byPrice range-key"`
+	Cost  int    `ddb:"cost" ddb-lsi:"byPrice range-key"`
+}
+
+type lsiTableRangeRecord struct {
+	ID  string `ddb:"id,hash-key"`
+	SKU string `ddb:"sku,range-key" ddb-lsi:"bySku range-key"`
+}
+
+type lsiEmptyIncludeRecord struct {
+	ID    string `ddb:"id,hash-key"`
+	SKU   string `ddb:"sku,range-key"`
+	Price int    `ddb:"price" ddb-lsi:"byPrice range-key include"`
+}
+
+type lsiProjectKeysOnlyRecord struct {
+	ID    string `ddb:"id,hash-key"`
+	SKU   string `ddb:"sku,range-key"`
+	Price int    `ddb:"price" ddb-lsi:"byPrice range-key keys-only"`
+	Title string `ddb:"title" ddb-project:"byPrice"`
+}
+
+type lsiProjectUnknownRecord struct {
+	ID    string `ddb:"id,hash-key"`
+	SKU   string `ddb:"sku,range-key"`
+	Title string `ddb:"title" ddb-project:"byPrice"`
+}
+
+type lsiBadProjectionRecord struct {
+	ID    string `ddb:"id,hash-key"`
+	SKU   string `ddb:"sku,range-key"`
+	Price int    `ddb:"price" ddb-lsi:"byPrice range-key some"`
+}
+
+type lsiTooManyRecord struct {
+	ID  string `ddb:"id,hash-key"`
+	SKU string `ddb:"sku,range-key"`
+	A   string `ddb-lsi:"a range-key"`
+	B   string `ddb-lsi:"b range-key"`
+	C   string `ddb-lsi:"c range-key"`
+	D   string `ddb-lsi:"d range-key"`
+	E   string `ddb-lsi:"e range-key"`
+	F   string `ddb-lsi:"f range-key"`
+}
+
+func TestNew_LocalIndexErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		new  func() error
+	}{
+		{"table without range key", func() error { _, err := New[lsiNoRangeRecord](); return err }},
+		{"other hash key", func() error { _, err := New[lsiOtherHashRecord](); return err }},
+		{"two range keys", func() error { _, err := New[lsiTwoRangesRecord](); return err }},
+		{"table range key", func() error { _, err := New[lsiTableRangeRecord](); return err }},
+		{"include without attributes", func() error { _, err := New[lsiEmptyIncludeRecord](); return err }},
+		{"project into keys only", func() error { _, err := New[lsiProjectKeysOnlyRecord](); return err }},
+		{"project into unknown index", func() error { _, err := New[lsiProjectUnknownRecord](); return err }},
+		{"unknown projection", func() error { _, err := New[lsiBadProjectionRecord](); return err }},
+		{"too many", func() error { _, err := New[lsiTooManyRecord](); return err }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.new(); err == nil {
+				t.Error("New() succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestQuery_LocalIndex(t *testing.T) {
+	db := ddbrepotest.NewMemoryDynamoDb()
+	repo := must.Must(New[listingLsiRecord]()).WithTableName("listings").WithDynamoDbApi(db)
+	if err := repo.TableCreate(); err != nil {
+		t.Fatal(err)
+	}
+	described := must.Must(db.DescribeTable(context.TODO(), repo.getDescribeTableInput()))
+	if got := len(described.Table.LocalSecondaryIndexes); got != 2 {
+		t.Errorf("TableCreate() created %v local secondary indexes, want 2", got)
+	}
+	for i, sku := range []string{"a", "b", "c", "d"} {
+		record := &listingLsiRecord{Seller: "s1", SKU: sku, Price: 40 - i*10, Listed: "2024-0" + sku, Title: "t-" + sku, Body: "long"}
+		if err := repo.PutItem(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var got []listingLsiRecord
+	err := QueryHkCbk(repo, func(r *listingLsiRecord) error {
+		got = append(got, *r)
+		return nil
+	}, &listingLsiRecord{Seller: "s1"}, OnIndex("byPrice"), RangeKeyLessThan(35), ConsistentRead())
+	if err != nil {
+		t.Fatal(err)
+	}
+	skus := make([]string, 0, len(got))
+	for _, r := range got {
+		skus = append(skus, r.SKU)
+	}
+	if !reflect.DeepEqual(skus, []string{"d", "c", "b"}) {
+		t.Errorf("query on byPrice = %v", JsonLine(&got))
+	}
+	got = nil
+	err = QueryHkCbk(repo, func(r *listingLsiRecord) error {
+		got = append(got, *r)
+		return nil
+	}, &listingLsiRecord{Seller: "s1"}, OnIndex("byListed"), RangeKeyEquals("2024-0b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []listingLsiRecord{{Seller: "s1", SKU: "b", Listed: "2024-0b", Title: "t-b"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("query on byListed = %v, want %v", JsonLine(&got), JsonLine(&want))
+	}
+}
+
+type orderGsiRecord struct {
+	Customer string `ddb:"customer,hash-key"`
+	OrderID  string `ddb:"orderId,range-key"`
+	Status   string `ddb:"status" ddb-gsi:"byStatus hash-key keys-only"`
+	Placed   string `ddb:"placed" ddb-gsi:"byStatus range-key, byShop range-key include"`
+	Shop     string `ddb:"shop" ddb-gsi:"byShop hash-key"`
+	Total    int    `ddb:"total" ddb-project:"byShop"`
+	Notes    string `ddb:"notes"`
+}
+
+func TestNew_GlobalIndexProjections(t *testing.T) {
+	repo := must.Must(New[orderGsiRecord]())
+	want := map[string]*types.Projection{
+		"byStatus": {ProjectionType: types.ProjectionTypeKeysOnly},
+		"byShop":   {ProjectionType: types.ProjectionTypeInclude, NonKeyAttributes: []string{"total"}},
+	}
+	for name, projection := range want {
+		if got := repo.gsi[name].Projection; !reflect.DeepEqual(got, projection) {
+			t.Errorf("New() projection of %v = %v, want %v", name, JsonLine(got), JsonLine(projection))
+		}
+	}
+}
+
+type gsiConflictingProjectionRecord struct {
+	ID    string `ddb:"id,hash-key"`
+	Shop  string `ddb:"shop" ddb-gsi:"byShop hash-key keys-only"`
+	Price int    `ddb:"price" ddb-gsi:"byShop range-key all"`
+}
+
+type gsiEmptyIncludeRecord struct {
+	ID   string `ddb:"id,hash-key"`
+	Shop string `ddb:"shop" ddb-gsi:"byShop hash-key include"`
+}
+
+type gsiProjectKeysOnlyRecord struct {
+	ID    string `ddb:"id,hash-key"`
+	Shop  string `ddb:"shop" ddb-gsi:"byShop hash-key keys-only"`
+	Title string `ddb:"title" ddb-project:"byShop"`
+}
+
+func TestNew_GlobalIndexProjectionErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		new  func() error
+	}{
+		{"conflicting projections", func() error { _, err := New[gsiConflictingProjectionRecord](); return err }},
+		{"include without attributes", func() error { _, err := New[gsiEmptyIncludeRecord](); return err }},
+		{"project into keys only", func() error { _, err := New[gsiProjectKeysOnlyRecord](); return err }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.new(); err == nil {
+				t.Error("New() succeeded, want an error")
+			}
+		})
+	}
+}
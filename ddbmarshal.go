@@ -1,27 +1,177 @@
 package ddbrepo
 
 import (
+	"fmt"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"reflect"
+	"time"
 )
 
+// Marshal encodes the whole record after checking the validation rules of its
+// fields and its Validate hook; generated code is preferred to reflection.
 func Marshal(props parseProps, source interface{}) (result map[string]types.AttributeValue, err error) {
-	return MarshalTagFilter(props, source, IncludeAll, "")
+	var invalid fieldErrors
+	if generated, ok := source.(ItemMarshaler); ok && useGenerated(props, source) {
+		if err = validateFields(props, source, nil, &invalid); err != nil {
+			return nil, err
+		} else if result, err = generated.MarshalDdbItem(); err != nil {
+			return nil, err
+		}
+	} else if result, err = marshalFiltered(props, source, IncludeAll, "", &invalid); err != nil {
+		return nil, err
+	}
+	validateRecord(source, &invalid)
+	if err = invalid.err(); err != nil {
+		return nil, err
+	}
+	return result, nil
 }
 
 func MarshalKey(props parseProps, source interface{}, prefix string) (result map[string]types.AttributeValue, err error) {
-	return MarshalTagFilter(props, source, IncludeKey, prefix)
+	generated, ok := source.(ItemMarshaler)
+	if !ok || !useGenerated(props, source) {
+		return MarshalTagFilter(props, source, IncludeKey, prefix)
+	}
+	var invalid fieldErrors
+	if err = validateFields(props, source, IncludeKey, &invalid); err == nil {
+		err = invalid.err()
+	}
+	if err != nil {
+		return nil, err
+	}
+	key, err := generated.MarshalDdbKey()
+	if err != nil {
+		return nil, err
+	} else if prefix == "" {
+		return key, nil
+	}
+	result = make(map[string]types.AttributeValue, len(key))
+	for name, av := range key {
+		result[prefix+name] = av
+	}
+	return result, nil
 }
 
-func makeListStructFieldsCbk(props parseProps, attrValueMap map[string]types.AttributeValue, filter func(spec fieldSpec) bool, prefix string) listStructFieldsCallback {
-	return func(fldNum int, field *reflect.StructField, value *reflect.Value) error {
-		spec, err := newFieldSpec(props, field)
-		if err == nil && spec != nil && filter(*spec) {
-			attrValueMap[prefix+spec.name], err = attributevalue.Marshal(value.Interface())
-		}
+func marshalFields(props parseProps, path string, value reflect.Value, filter func(spec fieldSpec) bool, prefix string, item map[string]types.AttributeValue, invalid *fieldErrors) error {
+	codec, err := codecOf(props, value.Type())
+	if err != nil {
 		return err
 	}
+	for i := range codec.fields {
+		field := &codec.fields[i]
+		spec := field.spec
+		if filter != nil && !filter(*spec) {
+			continue
+		}
+		fieldValue, found := field.value(value, false)
+		if !found {
+			continue
+		}
+		fieldPath := path + field.field.Name
+		if spec.IsRequired() && fieldValue.IsZero() {
+			invalid.add(fieldPath, spec, TagItemRequired, "is required")
+		}
+		spec.validateValue(fieldPath, fieldValue, invalid)
+		if omit, null := fieldPresence(props, spec, fieldValue); null {
+			item[prefix+spec.name] = &types.AttributeValueMemberNULL{Value: true}
+		} else if !omit && !field.converted && field.encode != nil {
+			if item[prefix+spec.name], err = field.encode(fieldValue, timeEncodingOf(props, spec)); err != nil {
+				return fmt.Errorf("%v: %w", fieldPath, err)
+			}
+		} else if !omit {
+			if item[prefix+spec.name], err = marshalValue(props, fieldPath, timeEncodingOf(props, spec), fieldValue, invalid); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+type omitProps interface {
+	OmitEmptyFields() bool
+}
+
+func isEmptyValue(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return value.Len() == 0
+	case reflect.Interface, reflect.Pointer:
+		return value.IsNil()
+	}
+	return value.IsZero()
+}
+
+func fieldPresence(props parseProps, spec *fieldSpec, value reflect.Value) (omit bool, null bool) {
+	if spec.IsKey() || !isEmptyValue(value) {
+		return false, false
+	} else if spec.IsNullable() {
+		return false, true
+	} else if value.Kind() == reflect.Pointer || spec.IsOmitEmpty() {
+		return true, false
+	}
+	omitEmpty, ok := props.(omitProps)
+	return ok && omitEmpty.OmitEmptyFields(), false
+}
+
+func marshalFieldValue(props parseProps, spec *fieldSpec, value interface{}) (types.AttributeValue, error) {
+	return marshalValue(props, spec.name, timeEncodingOf(props, spec), reflect.ValueOf(value), &fieldErrors{})
+}
+
+func marshalValue(props parseProps, path string, encoding TimeEncoding, value reflect.Value, invalid *fieldErrors) (types.AttributeValue, error) {
+	if !value.IsValid() {
+		return attributevalue.Marshal(nil)
+	} else if converter, found := converterFor(props, value.Type()); found {
+		av, err := converter.encode(value)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", path, err)
+		}
+		return av, nil
+	} else if value.Type() == timeType {
+		return encodeTime(value.Interface().(time.Time), encoding)
+	} else if !needsCodec(props, value.Type()) {
+		return attributevalue.Marshal(value.Interface())
+	}
+	null := &types.AttributeValueMemberNULL{Value: true}
+	switch value.Kind() {
+	case reflect.Pointer:
+		if value.IsNil() {
+			return null, nil
+		}
+		return marshalValue(props, path, encoding, value.Elem(), invalid)
+	case reflect.Struct:
+		item := make(map[string]types.AttributeValue)
+		err := marshalFields(props, path+".", value, nil, "", item, invalid)
+		return &types.AttributeValueMemberM{Value: item}, err
+	case reflect.Slice, reflect.Array:
+		if value.Kind() == reflect.Slice && value.IsNil() {
+			return null, nil
+		}
+		list := make([]types.AttributeValue, value.Len())
+		for i := range list {
+			var err error
+			if list[i], err = marshalValue(props, fmt.Sprintf("%v[%v]", path, i), encoding, value.Index(i), invalid); err != nil {
+				return nil, err
+			}
+		}
+		return &types.AttributeValueMemberL{Value: list}, nil
+	case reflect.Map:
+		if value.IsNil() {
+			return null, nil
+		} else if value.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("%v: map keys must be strings, not %v", path, value.Type().Key())
+		}
+		item := make(map[string]types.AttributeValue, value.Len())
+		for entries := value.MapRange(); entries.Next(); {
+			key := entries.Key().String()
+			var err error
+			if item[key], err = marshalValue(props, fmt.Sprintf("%v[%v]", path, key), encoding, entries.Value(), invalid); err != nil {
+				return nil, err
+			}
+		}
+		return &types.AttributeValueMemberM{Value: item}, nil
+	}
+	return attributevalue.Marshal(value.Interface())
 }
 
 func IncludeAll(spec fieldSpec) bool {
@@ -63,11 +213,23 @@ func AllOfFilters(filters ...func(spec fieldSpec) bool) func(spec fieldSpec) boo
 }
 
 func MarshalTagFilter(props parseProps, source interface{}, filter func(spec fieldSpec) bool, prefix string) (result map[string]types.AttributeValue, err error) {
-	result = make(map[string]types.AttributeValue)
-	err = listStructFieldsCbk(source, makeListStructFieldsCbk(props, result, filter, prefix))
+	var invalid fieldErrors
+	if result, err = marshalFiltered(props, source, filter, prefix, &invalid); err == nil {
+		err = invalid.err()
+	}
 	if err != nil {
 		return nil, err
 	} else {
 		return result, err
 	}
 }
+
+func marshalFiltered(props parseProps, source interface{}, filter func(spec fieldSpec) bool, prefix string, invalid *fieldErrors) (map[string]types.AttributeValue, error) {
+	value, err := getValidMarshallingTargetValue(source)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]types.AttributeValue)
+	err = marshalFields(props, "", value, filter, prefix, result, invalid)
+	return result, err
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/rotmistrk/must"
 	"reflect"
+	"regexp"
 	"testing"
 	"time"
 )
@@ -83,7 +84,7 @@ func TestMarshalTagFilter(t *testing.T) {
 				"rkey":   getAv("range"),
 				"value":  getAv("value"),
 				"array":  getAv([]string(nil)),
-				"expire": getAv(when),
+				"expire": getAv(when.Unix()),
 			},
 			wantErr: false,
 		},
@@ -105,7 +106,7 @@ func TestMarshalTagFilter(t *testing.T) {
 				"rkey":   getAv("range"),
 				"value":  getAv("value"),
 				"array":  getAv([]string{"one", "two", "three"}),
-				"expire": getAv(when),
+				"expire": getAv(when.Unix()),
 			},
 			wantErr: false,
 		},
@@ -127,3 +128,208 @@ func TestMarshalTagFilter(t *testing.T) {
 		})
 	}
 }
+
+type auditFields struct {
+	CreatedBy string `ddb:"createdBy"`
+	Revision  int
+}
+
+type geoPoint struct {
+	Lat float64 `ddb:"lat"`
+	Lng float64 `ddb:"lng"`
+}
+
+type addressPart struct {
+	City    string    `ddb:"city,required"`
+	Zip     string    `ddb:"zip,len=5"`
+	Point   *geoPoint `ddb:"point"`
+	Comment string    `ddb:",ignore"`
+}
+
+type Placement struct {
+	Note string
+}
+
+type siteRecord struct {
+	ID string `ddb:"id,hash-key"`
+	auditFields
+	*Placement
+	Hidden   auditFields            `ddb:",ignore"`
+	Owner    auditFields            `ddb:"owner"`
+	Address  addressPart            `ddb:"address"`
+	Branches []addressPart          `ddb:"branches"`
+	ByName   map[string]addressPart `ddb:"byName"`
+	Previous *addressPart           `ddb:"previous"`
+	Names    []string               `ddb:"names"`
+}
+
+func TestMarshal_Nested(t *testing.T) {
+	repo := must.Must(New[siteRecord]())
+	s := func(v string) types.AttributeValue { return &types.AttributeValueMemberS{Value: v} }
+	n := func(v string) types.AttributeValue { return &types.AttributeValueMemberN{Value: v} }
+	m := func(v map[string]types.AttributeValue) types.AttributeValue {
+		return &types.AttributeValueMemberM{Value: v}
+	}
+	null := &types.AttributeValueMemberNULL{Value: true}
+	record := &siteRecord{
+		ID:          "s1",
+		auditFields: auditFields{CreatedBy: "ann", Revision: 3},
+		Placement:   &Placement{Note: "corner"},
+		Hidden:      auditFields{CreatedBy: "hidden"},
+		Owner:       auditFields{CreatedBy: "bob"},
+		Address:     addressPart{City: "Oslo", Zip: "01500", Point: &geoPoint{Lat: 59.9, Lng: 10.7}, Comment: "dropped"},
+		Branches:    []addressPart{{City: "Bergen"}},
+		ByName:      map[string]addressPart{"main": {City: "Lund"}},
+	}
+	want := map[string]types.AttributeValue{
+		"id":        s("s1"),
+		"createdBy": s("ann"),
+		"revision":  n("3"),
+		"note":      s("corner"),
+		"owner":     m(map[string]types.AttributeValue{"createdBy": s("bob"), "revision": n("0")}),
+		"address": m(map[string]types.AttributeValue{
+			"city":  s("Oslo"),
+			"zip":   s("01500"),
+			"point": m(map[string]types.AttributeValue{"lat": n("59.9"), "lng": n("10.7")}),
+		}),
+		"branches": &types.AttributeValueMemberL{Value: []types.AttributeValue{
+			m(map[string]types.AttributeValue{"city": s("Bergen"), "zip": s("")}),
+		}},
+		"byName": m(map[string]types.AttributeValue{
+			"main": m(map[string]types.AttributeValue{"city": s("Lund"), "zip": s("")}),
+		}),
+		"names": null,
+	}
+	item, err := Marshal(repo, record)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if !reflect.DeepEqual(item, want) {
+		t.Errorf("Marshal() = %v, want %v", JsonLine(&item), JsonLine(&want))
+	}
+
+	var restored siteRecord
+	if err := Unmarshal(repo, &restored, item); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	record.Hidden, record.Address.Comment = auditFields{}, ""
+	if !reflect.DeepEqual(&restored, record) {
+		t.Errorf("Unmarshal() = %+v, want %+v", restored, record)
+	}
+
+	record.Address.City, record.Branches[0].Zip = "", "123"
+	_, err = Marshal(repo, record)
+	if got := validationFields(err); !reflect.DeepEqual(got, []string{"Address.City", "Branches[0].Zip"}) {
+		t.Errorf("Marshal() invalid fields = %v, error %v", got, err)
+	}
+	if err := Unmarshal(repo, &restored, map[string]types.AttributeValue{"address": s("Oslo")}); err == nil {
+		t.Errorf("Unmarshal() of a string into a nested struct succeeded")
+	}
+
+	update := repo.NewUpdate().Set("Address.Point.Lat", 1.5).Set("Branches[0].City", "Bergen").Set("ByName.main.Zip", "01500").Set("Owner", auditFields{CreatedBy: "eve"})
+	expr, _, err := update.Expression()
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0)
+	for _, placeholder := range regexp.MustCompile(`#f\d+`).FindAllString(expr, -1) {
+		names = append(names, update.names[placeholder])
+	}
+	wantNames := []string{"address", "point", "lat", "branches", "city", "byName", "main", "zip", "owner"}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Errorf("Expression() = %v with names %v, want %v", expr, names, wantNames)
+	}
+	if owner := update.values[":v3"]; !reflect.DeepEqual(owner, m(map[string]types.AttributeValue{"createdBy": s("eve"), "revision": n("0")})) {
+		t.Errorf("Set() of a nested struct = %v", JsonLine(&owner))
+	}
+}
+
+func TestNew_DuplicateAttribute(t *testing.T) {
+	type shadowed struct {
+		ID        string `ddb:"id,hash-key"`
+		CreatedBy string `ddb:"createdBy"`
+		auditFields
+	}
+	if _, err := New[shadowed](); err == nil {
+		t.Errorf("New() accepted two fields stored as createdBy")
+	}
+}
+
+type sparseRecord struct {
+	ID     string            `ddb:"id,hash-key"`
+	Title  string            `ddb:"title,omitempty"`
+	Owner  string            `ddb-gsi:"byOwner hash-key" ddb:"owner,omitempty"`
+	Note   string            `ddb:"note,null"`
+	Count  *int              `ddb:"count"`
+	Parent *int              `ddb:"parent,null"`
+	Tags   []string          `ddb:"tags,omitempty"`
+	Labels map[string]string `ddb:"labels"`
+	Plain  string
+}
+
+func TestMarshal_OmitEmpty(t *testing.T) {
+	repo := must.Must(New[sparseRecord]())
+	null := &types.AttributeValueMemberNULL{Value: true}
+	empty := &types.AttributeValueMemberS{Value: ""}
+	id := &types.AttributeValueMemberS{Value: "r1"}
+	five := 5
+	tests := []struct {
+		name   string
+		repo   *DdbRepo[sparseRecord]
+		record sparseRecord
+		want   map[string]types.AttributeValue
+	}{
+		{
+			name:   "tags only",
+			repo:   repo,
+			record: sparseRecord{ID: "r1", Tags: []string{}},
+			want:   map[string]types.AttributeValue{"id": id, "note": null, "parent": null, "labels": null, "plain": empty},
+		},
+		{
+			name:   "repo default",
+			repo:   repo.WithOmitEmptyFields(true),
+			record: sparseRecord{ID: "r1", Labels: map[string]string{}},
+			want:   map[string]types.AttributeValue{"id": id, "note": null, "parent": null},
+		},
+		{
+			name:   "values are stored",
+			repo:   repo.WithOmitEmptyFields(true),
+			record: sparseRecord{ID: "r1", Owner: "ann", Note: "n", Count: &five, Parent: &five},
+			want: map[string]types.AttributeValue{
+				"id":     id,
+				"owner":  &types.AttributeValueMemberS{Value: "ann"},
+				"note":   &types.AttributeValueMemberS{Value: "n"},
+				"count":  &types.AttributeValueMemberN{Value: "5"},
+				"parent": &types.AttributeValueMemberN{Value: "5"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Marshal(tt.repo, &tt.record)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Marshal() = %v, want %v", JsonLine(&got), JsonLine(&tt.want))
+			}
+		})
+	}
+
+	record := sparseRecord{Parent: &five}
+	err := Unmarshal(repo, &record, map[string]types.AttributeValue{
+		"count":  &types.AttributeValueMemberN{Value: "7"},
+		"parent": null,
+	})
+	if err != nil || record.Count == nil || *record.Count != 7 || record.Parent != nil {
+		t.Errorf("Unmarshal() = %+v, %v, want count allocated and parent cleared", record, err)
+	}
+
+	type conflicting struct {
+		ID   string `ddb:"id,hash-key"`
+		Note string `ddb:"note,omitempty,null"`
+	}
+	if _, err := New[conflicting](); err == nil {
+		t.Errorf("New() accepted both omitempty and null")
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"reflect"
+	"slices"
 	"strings"
 	"time"
 )
@@ -15,9 +16,13 @@ const (
 	DefaultWaitDuration            = 5 * time.Minute
 	DefaultAllowUntaggedFields     = true
 	DefaultLowercaseUntaggedFields = true
+	DefaultOmitEmptyFields         = false
+	DefaultTimeEncoding            = TimeDefault
 	DefaultBillingMode             = types.BillingModeProvisioned
 	DefaultReadCapacityUnits       = 1
 	DefaultWriteCapacityUnits      = 1
+	DefaultBatchMaxAttempts        = 8
+	DefaultBatchBaseDelay          = 50 * time.Millisecond
 )
 
 func Must[arg any](action func() (arg, error)) arg {
@@ -35,13 +40,34 @@ type DdbRepo[RecordType any] struct {
 	versionColumn            string
 	waitDuration             time.Duration
 	billingMode              types.BillingMode
+	billingModeConfigured    bool
 	keySchema                []types.KeySchemaElement
 	attributeDefinitions     []types.AttributeDefinition
 	allowUntaggedFields      bool
 	lowercaseUntaggedFields  bool
+	omitEmptyFields          bool
+	timeEncoding             TimeEncoding
+	ttlTimeSpec              *fieldSpec
+	converters               map[reflect.Type]Converter
+	convertersID             uint64
 	readCapacityUnitsConfig  int64
 	writeCapacityUnitsConfig int64
+	capacityConfigured       bool
+	indexCapacity            map[string]types.ProvisionedThroughput
 	gsi                      map[string]types.GlobalSecondaryIndex
+	lsi                      map[string]types.LocalSecondaryIndex
+	batchMaxAttempts         int
+	batchBaseDelay           time.Duration
+	optimisticLocking        bool
+	cursorSecret             []byte
+	strictUnmarshal          bool
+	tags                     map[string]string
+	kmsEncryption            bool
+	kmsKeyId                 string
+	streamViewType           types.StreamViewType
+	pointInTimeRecovery      bool
+	tableClass               types.TableClass
+	deletionProtection       bool
 }
 
 func (repo DdbRepo[RecordType]) ExpirationFieldName() (string, bool) {
@@ -76,93 +102,122 @@ func New[T any]() (repo *DdbRepo[T], err error) {
 		billingMode:              DefaultBillingMode,
 		allowUntaggedFields:      DefaultAllowUntaggedFields,
 		lowercaseUntaggedFields:  DefaultLowercaseUntaggedFields,
+		omitEmptyFields:          DefaultOmitEmptyFields,
+		timeEncoding:             DefaultTimeEncoding,
 		attributeDefinitions:     make([]types.AttributeDefinition, 0, 2),
 		keySchema:                make([]types.KeySchemaElement, 0, 2),
 		readCapacityUnitsConfig:  DefaultReadCapacityUnits,
 		writeCapacityUnitsConfig: DefaultWriteCapacityUnits,
+		batchMaxAttempts:         DefaultBatchMaxAttempts,
+		batchBaseDelay:           DefaultBatchBaseDelay,
 	}
 
 	var sample T
 	value := reflect.ValueOf(&sample).Elem()
-	target := reflect.TypeOf(&sample).Elem()
 	if value.Kind() != reflect.Struct {
-		err = errors.New(fmt.Sprintf("ddb repo can't store %v as a record", sample))
+		return nil, errors.New(fmt.Sprintf("ddb repo can't store %v as a record", sample))
 	}
 	hashKeys, rangeKeys, keys := 0, 0, 0
-	for i, I := 0, target.NumField(); i < I; i++ {
-		fieldType := target.Field(i)
-		fieldValue := value.Field(i)
-		if spec, err := newFieldSpec(repo, &fieldType); err != nil {
-			return nil, err
-		} else if spec == nil {
-			// ignore
-		} else {
-			if spec.IsKey() {
-				if def, err := attributeDefinition(spec, fieldValue); err != nil {
-					return nil, err
-				} else {
-					repo.attributeDefinitions = append(repo.attributeDefinitions, def)
-				}
-				if key, err := keySchemaElement(spec); err != nil {
-					return nil, err
-				} else {
-					repo.keySchema = append(repo.keySchema, key)
+	names := make(map[string]string)
+	projected := make(map[string][]string)
+	projections := make(map[string]types.ProjectionType)
+	err = allocStructFieldsCbk(&sample, func(fldNum int, fieldType *reflect.StructField, fieldValue *reflect.Value) error {
+		spec, err := newFieldSpec(repo, fieldType)
+		if err != nil || spec == nil {
+			return err
+		}
+		if other, found := names[spec.name]; found {
+			return fmt.Errorf("fields %v and %v are both stored as %v", other, fieldType.Name, spec.name)
+		}
+		names[spec.name] = fieldType.Name
+		if spec.IsKey() {
+			if err := repo.defineAttribute(spec, *fieldValue); err != nil {
+				return err
+			}
+			if key, err := keySchemaElement(spec); err != nil {
+				return err
+			} else {
+				repo.keySchema = append(repo.keySchema, key)
+			}
+			if spec.IsHashKey() {
+				hashKeys++
+			}
+			if spec.IsRangeKey() {
+				rangeKeys++
+			}
+			keys++
+		}
+		if spec.IsTtlField() {
+			repo.ttlColumn = repo.mangleName(spec.name)
+			if fieldValue.Type() == timeType {
+				repo.ttlTimeSpec = spec
+			}
+		}
+		if spec.IsVersionField() {
+			repo.versionColumn = repo.mangleName(spec.name)
+		}
+		if spec.gsiHash != nil {
+			if repo.gsi == nil {
+				repo.gsi = make(map[string]types.GlobalSecondaryIndex)
+			}
+			for k, v := range spec.gsiHash {
+				gsi := repo.gsi[k]
+				if gsi.IndexName == nil {
+					gsi.IndexName = aws.String(k)
+					gsi.KeySchema = make([]types.KeySchemaElement, 0)
+					gsi.Projection = &types.Projection{ProjectionType: types.ProjectionTypeAll}
 				}
-				if spec.IsHashKey() {
-					hashKeys++
+				if err := declareProjection(projections, k, spec, gsi.Projection); err != nil {
+					return err
 				}
-				if spec.IsRangeKey() {
-					rangeKeys++
+				gsi.KeySchema = append(gsi.KeySchema, indexKeyElement(spec.name, v))
+				if err := repo.defineAttribute(spec, *fieldValue); err != nil {
+					return err
 				}
-				keys++
+				repo.gsi[k] = gsi
 			}
-			if spec.IsTtlField() {
-				repo.ttlColumn = repo.mangleName(spec.name)
-			}
-			if spec.IsVersionField() {
-				repo.versionColumn = repo.mangleName(spec.name)
+		}
+		if spec.lsiHash != nil {
+			if repo.lsi == nil {
+				repo.lsi = make(map[string]types.LocalSecondaryIndex)
 			}
-			if spec.gsiHash != nil {
-				if repo.gsi == nil {
-					repo.gsi = make(map[string]types.GlobalSecondaryIndex)
+			for k, v := range spec.lsiHash {
+				lsi := repo.lsi[k]
+				if lsi.IndexName == nil {
+					lsi.IndexName = aws.String(k)
+					lsi.Projection = &types.Projection{ProjectionType: types.ProjectionTypeAll}
+				}
+				if err := declareProjection(projections, k, spec, lsi.Projection); err != nil {
+					return err
 				}
-				for k, v := range spec.gsiHash {
-					gsi := repo.gsi[k]
-					if gsi.IndexName == nil {
-						gsi.IndexName = aws.String(k)
-						gsi.KeySchema = make([]types.KeySchemaElement, 0)
-						gsi.Projection = &types.Projection{ProjectionType: types.ProjectionTypeAll}
-					}
-					keyType := types.KeyTypeHash
-					if !v {
-						keyType = types.KeyTypeRange
-					}
-					gsi.KeySchema = append(gsi.KeySchema, types.KeySchemaElement{
-						AttributeName: aws.String(spec.name),
-						KeyType:       keyType,
-					})
-					hasAd := false
-					for _, ad := range repo.attributeDefinitions {
-						if *ad.AttributeName == spec.name {
-							hasAd = true
-							break
-						}
-					}
-					if !hasAd {
-						if def, err := attributeDefinition(spec, fieldValue); err != nil {
-							return nil, err
-						} else {
-							repo.attributeDefinitions = append(repo.attributeDefinitions, def)
-						}
-					}
-					repo.gsi[k] = gsi
+				lsi.KeySchema = append(lsi.KeySchema, indexKeyElement(spec.name, v))
+				if err := repo.defineAttribute(spec, *fieldValue); err != nil {
+					return err
 				}
+				repo.lsi[k] = lsi
 			}
 		}
+		for _, index := range spec.projected {
+			projected[index] = append(projected[index], spec.name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 	if keys > 2 || keys != hashKeys+rangeKeys || hashKeys != 1 || rangeKeys > 1 {
 		return repo, errors.New(fmt.Sprintf("invalid keys configuration: %v hash, %v range, %v total", hashKeys, rangeKeys, keys))
 	}
+	if err := repo.completeLocalIndexes(); err != nil {
+		return nil, err
+	} else if err := repo.projectAttributes(projected); err != nil {
+		return nil, err
+	}
+	if describer, ok := interface{}(&sample).(KeySchemaDescriber); ok {
+		if err := repo.checkGeneratedKeySchema(describer.DdbKeySchema()); err != nil {
+			return nil, err
+		}
+	}
 	return repo, nil
 }
 
@@ -170,6 +225,43 @@ func (repo *DdbRepo[RecordType]) TableName() string {
 	return repo.tableName
 }
 
+func declareProjection(declared map[string]types.ProjectionType, index string, spec *fieldSpec, projection *types.Projection) error {
+	value, found := spec.projection[index]
+	if !found {
+		return nil
+	} else if other, found := declared[index]; found && other != value {
+		return fmt.Errorf("index %v is declared with both %v and %v projections", index, other, value)
+	}
+	declared[index] = value
+	projection.ProjectionType = value
+	return nil
+}
+
+func (repo *DdbRepo[T]) defineAttribute(spec *fieldSpec, fieldValue reflect.Value) error {
+	for _, ad := range repo.attributeDefinitions {
+		if *ad.AttributeName == spec.name {
+			return nil
+		}
+	}
+	def, err := attributeDefinition(repo, spec, fieldValue, timeEncodingOf(repo, spec))
+	if err != nil {
+		return err
+	}
+	repo.attributeDefinitions = append(repo.attributeDefinitions, def)
+	return nil
+}
+
+func indexKeyElement(name string, hash bool) types.KeySchemaElement {
+	keyType := types.KeyTypeHash
+	if !hash {
+		keyType = types.KeyTypeRange
+	}
+	return types.KeySchemaElement{
+		AttributeName: aws.String(name),
+		KeyType:       keyType,
+	}
+}
+
 func keySchemaElement(spec *fieldSpec) (types.KeySchemaElement, error) {
 	result := types.KeySchemaElement{
 		AttributeName: aws.String(spec.name),
@@ -184,16 +276,52 @@ func keySchemaElement(spec *fieldSpec) (types.KeySchemaElement, error) {
 	return result, nil
 }
 
-func attributeDefinition(spec *fieldSpec, fieldValue reflect.Value) (types.AttributeDefinition, error) {
+func (repo *DdbRepo[T]) redefineAttributes() {
+	repo.attributeDefinitions = slices.Clone(repo.attributeDefinitions)
+	var sample T
+	_ = allocStructFieldsCbk(&sample, func(fldNum int, field *reflect.StructField, value *reflect.Value) error {
+		spec, err := newFieldSpec(repo, field)
+		if err != nil || spec == nil {
+			return nil
+		}
+		for i, def := range repo.attributeDefinitions {
+			if aws.ToString(def.AttributeName) != spec.name {
+				continue
+			} else if def, err := attributeDefinition(repo, spec, *value, timeEncodingOf(repo, spec)); err == nil {
+				repo.attributeDefinitions[i] = def
+			}
+		}
+		return nil
+	})
+}
+
+func attributeDefinition(props parseProps, spec *fieldSpec, fieldValue reflect.Value, encoding TimeEncoding) (types.AttributeDefinition, error) {
 	ret := types.AttributeDefinition{
 		AttributeName: aws.String(spec.name),
 	}
+	fieldType := fieldValue.Type()
+	if converter, found := converterFor(props, fieldType); found {
+		ret.AttributeType = converter.scalarType
+		if ret.AttributeType == "" {
+			return ret, errors.New(fmt.Sprintf("converter for %v declares no key type for field %v", fieldType, spec.name))
+		}
+		return ret, nil
+	} else if fieldType.Implements(avMarshalerType) || reflect.PointerTo(fieldType).Implements(avMarshalerType) {
+		var err error
+		ret.AttributeType, err = marshalerScalarType(fieldType)
+		return ret, err
+	}
 	switch fieldValue.Interface().(type) {
 	case string:
 		ret.AttributeType = types.ScalarAttributeTypeS
+	case time.Time:
+		ret.AttributeType = types.ScalarAttributeTypeS
+		if encoding.isNumeric() {
+			ret.AttributeType = types.ScalarAttributeTypeN
+		}
 	case int, int8, int16, int32, int64,
 		uint, uint8, uint16, uint32, uint64,
-		float32, float64, time.Time:
+		float32, float64:
 		ret.AttributeType = types.ScalarAttributeTypeN
 	case []byte:
 		ret.AttributeType = types.ScalarAttributeTypeB
@@ -261,9 +389,13 @@ func (repo *DdbRepo[T]) LowercaseUntaggedFields() bool {
 	return repo.lowercaseUntaggedFields
 }
 
-func (repo *DdbRepo[T]) getProvisionedThroughput() *types.ProvisionedThroughput {
-	return &types.ProvisionedThroughput{
-		ReadCapacityUnits:  aws.Int64(repo.readCapacityUnitsConfig),
-		WriteCapacityUnits: aws.Int64(repo.writeCapacityUnitsConfig),
-	}
+// WithOmitEmptyFields makes every non-key field behave as tagged omitempty
+// unless it is tagged null.
+func (repo DdbRepo[T]) WithOmitEmptyFields(omit bool) *DdbRepo[T] {
+	repo.omitEmptyFields = omit
+	return &repo
+}
+
+func (repo *DdbRepo[T]) OmitEmptyFields() bool {
+	return repo.omitEmptyFields
 }
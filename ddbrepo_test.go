@@ -3,6 +3,7 @@ package ddbrepo
 import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/rotmistrk/must"
 	"reflect"
 	"testing"
 	"time"
@@ -26,6 +27,20 @@ type mockDdbApi struct {
 	DynamoDbApi
 }
 
+func newTestRepo[R any](t *testing.T, api DynamoDbApi, table string, records ...*R) *DdbRepo[R] {
+	t.Helper()
+	repo := must.Must(New[R]()).WithTableName(table).WithDynamoDbApi(api)
+	if err := repo.TableCreate(); err != nil {
+		t.Fatal(err)
+	}
+	for _, record := range records {
+		if err := repo.PutItem(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return repo
+}
+
 func TestDdbRepo_mangleName(t *testing.T) {
 	type fields struct {
 		ddbClient               DynamoDbApi
@@ -149,6 +164,9 @@ func TestNew(t *testing.T) {
 				lowercaseUntaggedFields:  DefaultLowercaseUntaggedFields,
 				readCapacityUnitsConfig:  DefaultReadCapacityUnits,
 				writeCapacityUnitsConfig: DefaultWriteCapacityUnits,
+				batchMaxAttempts:         DefaultBatchMaxAttempts,
+				batchBaseDelay:           DefaultBatchBaseDelay,
+				timeEncoding:             DefaultTimeEncoding,
 				ttlColumn:                "expireOn",
 				versionColumn:            "version",
 				keySchema: []types.KeySchemaElement{
@@ -222,6 +240,7 @@ func Test_attributeDefinition(t *testing.T) {
 	type args struct {
 		spec       *fieldSpec
 		fieldValue reflect.Value
+		encoding   TimeEncoding
 	}
 	tests := []struct {
 		name    string
@@ -267,6 +286,7 @@ func Test_attributeDefinition(t *testing.T) {
 					isRangeKey: true,
 				},
 				fieldValue: reflect.ValueOf(time.Now()),
+				encoding:   TimeUnix,
 			},
 			want: types.AttributeDefinition{
 				AttributeName: aws.String("timeattr"),
@@ -274,6 +294,22 @@ func Test_attributeDefinition(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "time as string ok",
+			args: args{
+				spec: &fieldSpec{
+					name:       "timeattr",
+					isRangeKey: true,
+				},
+				fieldValue: reflect.ValueOf(time.Now()),
+				encoding:   TimeRFC3339Nano,
+			},
+			want: types.AttributeDefinition{
+				AttributeName: aws.String("timeattr"),
+				AttributeType: types.ScalarAttributeTypeS,
+			},
+			wantErr: false,
+		},
 		{
 			name: "bytes ok",
 			args: args{
@@ -320,7 +356,7 @@ func Test_attributeDefinition(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := attributeDefinition(tt.args.spec, tt.args.fieldValue)
+			got, err := attributeDefinition(&props{true, true}, tt.args.spec, tt.args.fieldValue, tt.args.encoding)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("attributeDefinition() error = %v, wantErr %v", err, tt.wantErr)
 				return
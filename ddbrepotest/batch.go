@@ -0,0 +1,122 @@
+package ddbrepotest
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	maxBatchGetKeys      = 100
+	maxBatchWriteRequest = 25
+)
+
+func (db *MemoryDynamoDb) BatchGetItem(ctx context.Context, params *dynamodb.BatchGetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	if len(params.RequestItems) == 0 {
+		return nil, validationError("RequestItems must not be empty")
+	}
+	total := 0
+	output := &dynamodb.BatchGetItemOutput{
+		Responses:       make(map[string][]map[string]types.AttributeValue),
+		UnprocessedKeys: make(map[string]types.KeysAndAttributes),
+	}
+	for tableName, request := range params.RequestItems {
+		table, err := db.table(&tableName)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkPlaceholders(request.ExpressionAttributeNames, nil); err != nil {
+			return nil, err
+		}
+		exprCtx := newExprContext(request.ExpressionAttributeNames, nil)
+		var projection []documentPath
+		if request.ProjectionExpression != nil {
+			if projection, err = parseProjection(*request.ProjectionExpression, exprCtx); err != nil {
+				return nil, validationError("invalid ProjectionExpression: %v", err)
+			}
+		}
+		if err := exprCtx.checkUnused(); err != nil {
+			return nil, validationError("%v", err)
+		}
+		seen := make(map[string]bool)
+		items := make([]map[string]types.AttributeValue, 0, len(request.Keys))
+		for _, key := range request.Keys {
+			if err := table.checkKey(key, true); err != nil {
+				return nil, err
+			}
+			primaryKey := table.primaryKey(key)
+			if seen[primaryKey] {
+				return nil, validationError("provided list of item keys contains duplicates")
+			}
+			seen[primaryKey] = true
+			if item, found := table.items[primaryKey]; found {
+				if projection != nil {
+					items = append(items, project(item, projection))
+				} else {
+					items = append(items, copyItem(item))
+				}
+			}
+		}
+		total += len(request.Keys)
+		output.Responses[tableName] = items
+	}
+	if total > maxBatchGetKeys {
+		return nil, validationError("too many items requested for the BatchGetItem call")
+	}
+	return output, nil
+}
+
+func (db *MemoryDynamoDb) BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	if len(params.RequestItems) == 0 {
+		return nil, validationError("RequestItems must not be empty")
+	}
+	total := 0
+	apply := make([]func(), 0)
+	for tableName, requests := range params.RequestItems {
+		table, err := db.table(&tableName)
+		if err != nil {
+			return nil, err
+		}
+		seen := make(map[string]bool)
+		for _, request := range requests {
+			var primaryKey string
+			switch {
+			case request.PutRequest != nil && request.DeleteRequest == nil:
+				item := request.PutRequest.Item
+				if err := table.checkItem(item); err != nil {
+					return nil, err
+				}
+				primaryKey = table.primaryKey(item)
+				stored := copyItem(item)
+				apply = append(apply, func() { table.items[primaryKey] = stored })
+			case request.DeleteRequest != nil && request.PutRequest == nil:
+				key := request.DeleteRequest.Key
+				if err := table.checkKey(key, true); err != nil {
+					return nil, err
+				}
+				primaryKey = table.primaryKey(key)
+				apply = append(apply, func() { delete(table.items, primaryKey) })
+			default:
+				return nil, validationError("each write request must contain exactly one of PutRequest or DeleteRequest")
+			}
+			if seen[primaryKey] {
+				return nil, validationError("provided list of item keys contains duplicates")
+			}
+			seen[primaryKey] = true
+		}
+		total += len(requests)
+	}
+	if total > maxBatchWriteRequest {
+		return nil, validationError("too many items requested for the BatchWriteItem call")
+	}
+	for _, f := range apply {
+		f()
+	}
+	return &dynamodb.BatchWriteItemOutput{
+		UnprocessedItems: make(map[string][]types.WriteRequest),
+	}, nil
+}
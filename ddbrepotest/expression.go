@@ -0,0 +1,699 @@
+package ddbrepotest
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokName
+	tokValue
+	tokNumber
+	tokOp
+	tokLParen
+	tokRParen
+	tokComma
+	tokDot
+	tokLBracket
+	tokRBracket
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+func tokenize(expression string) ([]token, error) {
+	result := make([]token, 0, 16)
+	runes := []rune(expression)
+	isIdent := func(r rune) bool {
+		return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+	}
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			result = append(result, token{tokLParen, "("})
+			i++
+		case r == ')':
+			result = append(result, token{tokRParen, ")"})
+			i++
+		case r == ',':
+			result = append(result, token{tokComma, ","})
+			i++
+		case r == '.':
+			result = append(result, token{tokDot, "."})
+			i++
+		case r == '[':
+			result = append(result, token{tokLBracket, "["})
+			i++
+		case r == ']':
+			result = append(result, token{tokRBracket, "]"})
+			i++
+		case r == '=' || r == '+' || r == '-':
+			result = append(result, token{tokOp, string(r)})
+			i++
+		case r == '<' || r == '>':
+			if i+1 < len(runes) && (runes[i+1] == '=' || (r == '<' && runes[i+1] == '>')) {
+				result = append(result, token{tokOp, string(runes[i : i+2])})
+				i += 2
+			} else {
+				result = append(result, token{tokOp, string(r)})
+				i++
+			}
+		case r == '#' || r == ':':
+			j := i + 1
+			for j < len(runes) && isIdent(runes[j]) {
+				j++
+			}
+			if j == i+1 {
+				return nil, fmt.Errorf("invalid placeholder at %v in %q", i, expression)
+			}
+			kind := tokName
+			if r == ':' {
+				kind = tokValue
+			}
+			result = append(result, token{kind, string(runes[i:j])})
+			i = j
+		case unicode.IsDigit(r):
+			j := i
+			for j < len(runes) && unicode.IsDigit(runes[j]) {
+				j++
+			}
+			result = append(result, token{tokNumber, string(runes[i:j])})
+			i = j
+		case isIdent(r):
+			j := i
+			for j < len(runes) && isIdent(runes[j]) {
+				j++
+			}
+			result = append(result, token{tokIdent, string(runes[i:j])})
+			i = j
+		default:
+			return nil, fmt.Errorf("unexpected character %q in %q", r, expression)
+		}
+	}
+	return append(result, token{tokEOF, ""}), nil
+}
+
+// exprContext tracks the used placeholders; DynamoDB rejects unused ones.
+type exprContext struct {
+	names      map[string]string
+	values     map[string]types.AttributeValue
+	usedNames  map[string]bool
+	usedValues map[string]bool
+}
+
+func newExprContext(names map[string]string, values map[string]types.AttributeValue) *exprContext {
+	return &exprContext{
+		names:      names,
+		values:     values,
+		usedNames:  make(map[string]bool),
+		usedValues: make(map[string]bool),
+	}
+}
+
+func (c *exprContext) name(placeholder string) (string, error) {
+	if name, found := c.names[placeholder]; found {
+		c.usedNames[placeholder] = true
+		return name, nil
+	}
+	return "", fmt.Errorf("expression attribute name %v is not defined", placeholder)
+}
+
+func (c *exprContext) value(placeholder string) (types.AttributeValue, error) {
+	if value, found := c.values[placeholder]; found {
+		c.usedValues[placeholder] = true
+		return value, nil
+	}
+	return nil, fmt.Errorf("expression attribute value %v is not defined", placeholder)
+}
+
+func (c *exprContext) checkUnused() error {
+	for k := range c.names {
+		if !c.usedNames[k] {
+			return fmt.Errorf("value provided in ExpressionAttributeNames unused in expressions: keys: {%v}", k)
+		}
+	}
+	for k := range c.values {
+		if !c.usedValues[k] {
+			return fmt.Errorf("value provided in ExpressionAttributeValues unused in expressions: keys: {%v}", k)
+		}
+	}
+	return nil
+}
+
+type pathElement struct {
+	name    string
+	index   int
+	isIndex bool
+}
+
+type documentPath []pathElement
+
+func (p documentPath) String() string {
+	var sb strings.Builder
+	for i, e := range p {
+		if e.isIndex {
+			sb.WriteString(fmt.Sprintf("[%v]", e.index))
+		} else {
+			if i > 0 {
+				sb.WriteString(".")
+			}
+			sb.WriteString(e.name)
+		}
+	}
+	return sb.String()
+}
+
+func (p documentPath) isTopLevel(name string) bool {
+	return len(p) == 1 && !p[0].isIndex && p[0].name == name
+}
+
+func (p documentPath) resolve(item map[string]types.AttributeValue) (types.AttributeValue, bool) {
+	var current types.AttributeValue = &types.AttributeValueMemberM{Value: item}
+	for _, e := range p {
+		switch v := current.(type) {
+		case *types.AttributeValueMemberM:
+			if e.isIndex {
+				return nil, false
+			} else if next, found := v.Value[e.name]; found {
+				current = next
+			} else {
+				return nil, false
+			}
+		case *types.AttributeValueMemberL:
+			if !e.isIndex || e.index >= len(v.Value) {
+				return nil, false
+			}
+			current = v.Value[e.index]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+type operand interface {
+	evaluate(item map[string]types.AttributeValue) (types.AttributeValue, bool)
+}
+
+type pathOperand struct {
+	path documentPath
+}
+
+func (o pathOperand) evaluate(item map[string]types.AttributeValue) (types.AttributeValue, bool) {
+	return o.path.resolve(item)
+}
+
+type valueOperand struct {
+	value types.AttributeValue
+}
+
+func (o valueOperand) evaluate(item map[string]types.AttributeValue) (types.AttributeValue, bool) {
+	return o.value, true
+}
+
+type sizeOperand struct {
+	path documentPath
+}
+
+func (o sizeOperand) evaluate(item map[string]types.AttributeValue) (types.AttributeValue, bool) {
+	if value, found := o.path.resolve(item); !found {
+		return nil, false
+	} else if size, ok := sizeOf(value); !ok {
+		return nil, false
+	} else {
+		return &types.AttributeValueMemberN{Value: strconv.Itoa(size)}, true
+	}
+}
+
+type condition interface {
+	matches(item map[string]types.AttributeValue) bool
+}
+
+type andCondition struct {
+	left, right condition
+}
+
+func (c andCondition) matches(item map[string]types.AttributeValue) bool {
+	return c.left.matches(item) && c.right.matches(item)
+}
+
+type orCondition struct {
+	left, right condition
+}
+
+func (c orCondition) matches(item map[string]types.AttributeValue) bool {
+	return c.left.matches(item) || c.right.matches(item)
+}
+
+type notCondition struct {
+	inner condition
+}
+
+func (c notCondition) matches(item map[string]types.AttributeValue) bool {
+	return !c.inner.matches(item)
+}
+
+type comparison struct {
+	op          string
+	left, right operand
+}
+
+func (c comparison) matches(item map[string]types.AttributeValue) bool {
+	left, leftFound := c.left.evaluate(item)
+	right, rightFound := c.right.evaluate(item)
+	if !leftFound || !rightFound {
+		return c.op == "<>"
+	}
+	switch c.op {
+	case "=":
+		return equalValues(left, right)
+	case "<>":
+		return !equalValues(left, right)
+	}
+	cmp, ok := compareValues(left, right)
+	if !ok {
+		return false
+	}
+	switch c.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return false
+}
+
+type betweenCondition struct {
+	subject, low, high operand
+}
+
+func (c betweenCondition) matches(item map[string]types.AttributeValue) bool {
+	subject, found := c.subject.evaluate(item)
+	low, lowFound := c.low.evaluate(item)
+	high, highFound := c.high.evaluate(item)
+	if !found || !lowFound || !highFound {
+		return false
+	}
+	lowCmp, lowOk := compareValues(subject, low)
+	highCmp, highOk := compareValues(subject, high)
+	return lowOk && highOk && lowCmp >= 0 && highCmp <= 0
+}
+
+type inCondition struct {
+	subject operand
+	options []operand
+}
+
+func (c inCondition) matches(item map[string]types.AttributeValue) bool {
+	subject, found := c.subject.evaluate(item)
+	if !found {
+		return false
+	}
+	for _, o := range c.options {
+		if value, ok := o.evaluate(item); ok && equalValues(subject, value) {
+			return true
+		}
+	}
+	return false
+}
+
+type functionCondition struct {
+	name     string
+	path     documentPath
+	argument operand
+}
+
+func (c functionCondition) matches(item map[string]types.AttributeValue) bool {
+	value, found := c.path.resolve(item)
+	switch c.name {
+	case "attribute_exists":
+		return found
+	case "attribute_not_exists":
+		return !found
+	}
+	if !found {
+		return false
+	}
+	argument, ok := c.argument.evaluate(item)
+	if !ok {
+		return false
+	}
+	switch c.name {
+	case "attribute_type":
+		if s, ok := argument.(*types.AttributeValueMemberS); ok {
+			return typeCode(value) == s.Value
+		}
+	case "begins_with":
+		return beginsWith(value, argument)
+	case "contains":
+		return contains(value, argument)
+	}
+	return false
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	ctx    *exprContext
+}
+
+func newParser(expression string, ctx *exprContext) (*parser, error) {
+	if tokens, err := tokenize(expression); err != nil {
+		return nil, err
+	} else {
+		return &parser{tokens: tokens, ctx: ctx}, nil
+	}
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isKeyword(word string) bool {
+	t := p.peek()
+	return t.kind == tokIdent && strings.EqualFold(t.text, word)
+}
+
+func (p *parser) expect(kind tokenKind, what string) (token, error) {
+	if t := p.next(); t.kind != kind {
+		return t, fmt.Errorf("syntax error: expected %v, got %q", what, t.text)
+	} else {
+		return t, nil
+	}
+}
+
+func parseCondition(expression string, ctx *exprContext) (condition, error) {
+	p, err := newParser(expression, ctx)
+	if err != nil {
+		return nil, err
+	}
+	result, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("syntax error: unexpected %q", t.text)
+	}
+	return result, nil
+}
+
+func (p *parser) parseOr() (condition, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("OR") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orCondition{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (condition, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("AND") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = andCondition{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (condition, error) {
+	if p.isKeyword("NOT") {
+		p.next()
+		inner, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notCondition{inner}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (condition, error) {
+	t := p.peek()
+	if t.kind == tokLParen {
+		p.next()
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokRParen, ")"); err != nil {
+			return nil, err
+		}
+		return inner, nil
+	}
+	if t.kind == tokIdent && p.tokens[p.pos+1].kind == tokLParen {
+		switch name := strings.ToLower(t.text); name {
+		case "attribute_exists", "attribute_not_exists", "attribute_type", "begins_with", "contains":
+			return p.parseFunction(name)
+		}
+	}
+	subject, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case p.isKeyword("BETWEEN"):
+		p.next()
+		low, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		if !p.isKeyword("AND") {
+			return nil, fmt.Errorf("syntax error: expected AND in BETWEEN")
+		}
+		p.next()
+		high, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return betweenCondition{subject, low, high}, nil
+	case p.isKeyword("IN"):
+		p.next()
+		if _, err := p.expect(tokLParen, "("); err != nil {
+			return nil, err
+		}
+		result := inCondition{subject: subject}
+		for {
+			option, err := p.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			result.options = append(result.options, option)
+			if p.peek().kind == tokComma {
+				p.next()
+				continue
+			}
+			if _, err := p.expect(tokRParen, ")"); err != nil {
+				return nil, err
+			}
+			return result, nil
+		}
+	}
+	op := p.next()
+	if op.kind != tokOp || op.text == "+" || op.text == "-" {
+		return nil, fmt.Errorf("syntax error: expected comparator, got %q", op.text)
+	}
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return comparison{op.text, subject, right}, nil
+}
+
+func (p *parser) parseFunction(name string) (condition, error) {
+	p.next()
+	p.next()
+	path, err := p.parsePath()
+	if err != nil {
+		return nil, err
+	}
+	result := functionCondition{name: name, path: path}
+	if name != "attribute_exists" && name != "attribute_not_exists" {
+		if _, err := p.expect(tokComma, ","); err != nil {
+			return nil, err
+		}
+		if result.argument, err = p.parseOperand(); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := p.expect(tokRParen, ")"); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (p *parser) parseOperand() (operand, error) {
+	t := p.peek()
+	switch {
+	case t.kind == tokValue:
+		p.next()
+		if value, err := p.ctx.value(t.text); err != nil {
+			return nil, err
+		} else {
+			return valueOperand{value}, nil
+		}
+	case t.kind == tokIdent && strings.EqualFold(t.text, "size") && p.tokens[p.pos+1].kind == tokLParen:
+		p.next()
+		p.next()
+		path, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokRParen, ")"); err != nil {
+			return nil, err
+		}
+		return sizeOperand{path}, nil
+	default:
+		if path, err := p.parsePath(); err != nil {
+			return nil, err
+		} else {
+			return pathOperand{path}, nil
+		}
+	}
+}
+
+func (p *parser) parsePath() (documentPath, error) {
+	result := make(documentPath, 0, 1)
+	element := func() error {
+		switch t := p.next(); t.kind {
+		case tokIdent:
+			result = append(result, pathElement{name: t.text})
+		case tokName:
+			if name, err := p.ctx.name(t.text); err != nil {
+				return err
+			} else {
+				result = append(result, pathElement{name: name})
+			}
+		default:
+			return fmt.Errorf("syntax error: expected attribute name, got %q", t.text)
+		}
+		return nil
+	}
+	if err := element(); err != nil {
+		return nil, err
+	}
+	for {
+		switch p.peek().kind {
+		case tokDot:
+			p.next()
+			if err := element(); err != nil {
+				return nil, err
+			}
+		case tokLBracket:
+			p.next()
+			t, err := p.expect(tokNumber, "list index")
+			if err != nil {
+				return nil, err
+			}
+			index, _ := strconv.Atoi(t.text)
+			result = append(result, pathElement{index: index, isIndex: true})
+			if _, err := p.expect(tokRBracket, "]"); err != nil {
+				return nil, err
+			}
+		default:
+			return result, nil
+		}
+	}
+}
+
+func parseProjection(expression string, ctx *exprContext) ([]documentPath, error) {
+	p, err := newParser(expression, ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]documentPath, 0)
+	for {
+		path, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, path)
+		if t := p.next(); t.kind == tokEOF {
+			return result, nil
+		} else if t.kind != tokComma {
+			return nil, fmt.Errorf("syntax error: unexpected %q in projection", t.text)
+		}
+	}
+}
+
+func project(item map[string]types.AttributeValue, paths []documentPath) map[string]types.AttributeValue {
+	result := make(map[string]types.AttributeValue)
+	for _, path := range paths {
+		if value, found := path.resolve(item); found {
+			setPath(result, path, copyValue(value))
+		}
+	}
+	return result
+}
+
+func setPath(item map[string]types.AttributeValue, path documentPath, value types.AttributeValue) {
+	var container types.AttributeValue = &types.AttributeValueMemberM{Value: item}
+	for i, e := range path {
+		last := i == len(path)-1
+		switch c := container.(type) {
+		case *types.AttributeValueMemberM:
+			if last {
+				c.Value[e.name] = value
+				return
+			}
+			next, found := c.Value[e.name]
+			if !found {
+				if path[i+1].isIndex {
+					next = &types.AttributeValueMemberL{}
+				} else {
+					next = &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{}}
+				}
+				c.Value[e.name] = next
+			}
+			container = next
+		case *types.AttributeValueMemberL:
+			for len(c.Value) <= e.index {
+				c.Value = append(c.Value, &types.AttributeValueMemberNULL{Value: true})
+			}
+			if last {
+				c.Value[e.index] = value
+				return
+			}
+			container = c.Value[e.index]
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,942 @@
+package ddbrepotest
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+	"hash/fnv"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	Region    = "local"
+	AccountId = "000000000000"
+)
+
+// MemoryDynamoDb is an in-memory implementation of ddbrepo.DynamoDbApi meant
+// for unit tests. It keeps items keyed by the declared key schema, evaluates
+// condition, filter, key condition and projection expressions, maintains
+// secondary indexes and pages results like DynamoDB does.
+type MemoryDynamoDb struct {
+	mutex  sync.Mutex
+	tables map[string]*memoryTable
+}
+
+type memoryIndex struct {
+	name       string
+	hashKey    string
+	rangeKey   string
+	projection *types.Projection
+	global     bool
+}
+
+type memoryTable struct {
+	description    *types.TableDescription
+	ttl            *types.TimeToLiveDescription
+	hashKey        string
+	rangeKey       string
+	attributeTypes map[string]types.ScalarAttributeType
+	indexes        map[string]*memoryIndex
+	items          map[string]map[string]types.AttributeValue
+	tags           []types.Tag
+	backups        *types.ContinuousBackupsDescription
+}
+
+func NewMemoryDynamoDb() *MemoryDynamoDb {
+	return &MemoryDynamoDb{
+		tables: make(map[string]*memoryTable),
+	}
+}
+
+// TableItems returns copies of all items stored in the table in key order.
+func (db *MemoryDynamoDb) TableItems(tableName string) []map[string]types.AttributeValue {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	if table, found := db.tables[tableName]; found {
+		items := table.sorted(nil)
+		for i, item := range items {
+			items[i] = copyItem(item)
+		}
+		return items
+	}
+	return nil
+}
+
+func validationError(format string, args ...any) error {
+	return &smithy.GenericAPIError{
+		Code:    "ValidationException",
+		Message: fmt.Sprintf(format, args...),
+		Fault:   smithy.FaultClient,
+	}
+}
+
+func tableNotFound(tableName string) error {
+	return &types.ResourceNotFoundException{
+		Message: aws.String("Requested resource not found: Table: " + tableName + " not found"),
+	}
+}
+
+func conditionFailed(old map[string]types.AttributeValue, returnOld types.ReturnValuesOnConditionCheckFailure) error {
+	result := &types.ConditionalCheckFailedException{
+		Message: aws.String("The conditional request failed"),
+	}
+	if returnOld == types.ReturnValuesOnConditionCheckFailureAllOld {
+		result.Item = copyItem(old)
+	}
+	return result
+}
+
+func keyNames(schema []types.KeySchemaElement) (hashKey string, rangeKey string) {
+	for _, k := range schema {
+		switch k.KeyType {
+		case types.KeyTypeHash:
+			hashKey = aws.ToString(k.AttributeName)
+		case types.KeyTypeRange:
+			rangeKey = aws.ToString(k.AttributeName)
+		}
+	}
+	return
+}
+
+func (db *MemoryDynamoDb) table(tableName *string) (*memoryTable, error) {
+	if tableName == nil || *tableName == "" {
+		return nil, validationError("TableName is required")
+	}
+	if table, found := db.tables[*tableName]; !found {
+		return nil, tableNotFound(*tableName)
+	} else {
+		return table, nil
+	}
+}
+
+func checkSchema(schema []types.KeySchemaElement, definitions map[string]types.ScalarAttributeType, used map[string]bool) (string, string, error) {
+	hashKey, rangeKey := keyNames(schema)
+	if hashKey == "" || len(schema) > 2 || (len(schema) == 2 && rangeKey == "") {
+		return "", "", validationError("invalid key schema %v", schema)
+	}
+	for _, k := range schema {
+		name := aws.ToString(k.AttributeName)
+		if _, found := definitions[name]; !found {
+			return "", "", validationError("key attribute %v is not defined in AttributeDefinitions", name)
+		}
+		used[name] = true
+	}
+	return hashKey, rangeKey, nil
+}
+
+func checkThroughput(mode types.BillingMode, throughput *types.ProvisionedThroughput, what string) error {
+	if mode == types.BillingModePayPerRequest {
+		if throughput != nil {
+			return validationError("%v: neither ReadCapacityUnits nor WriteCapacityUnits can be specified when BillingMode is PAY_PER_REQUEST", what)
+		}
+		return nil
+	}
+	if throughput == nil || aws.ToInt64(throughput.ReadCapacityUnits) < 1 || aws.ToInt64(throughput.WriteCapacityUnits) < 1 {
+		return validationError("%v: ProvisionedThroughput with positive capacity units is required when BillingMode is PROVISIONED", what)
+	}
+	return nil
+}
+
+func throughputDescription(throughput *types.ProvisionedThroughput) *types.ProvisionedThroughputDescription {
+	result := &types.ProvisionedThroughputDescription{
+		NumberOfDecreasesToday: aws.Int64(0),
+		ReadCapacityUnits:      aws.Int64(0),
+		WriteCapacityUnits:     aws.Int64(0),
+	}
+	if throughput != nil {
+		result.ReadCapacityUnits = aws.Int64(aws.ToInt64(throughput.ReadCapacityUnits))
+		result.WriteCapacityUnits = aws.Int64(aws.ToInt64(throughput.WriteCapacityUnits))
+	}
+	return result
+}
+
+func (db *MemoryDynamoDb) CreateTable(ctx context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	tableName := aws.ToString(params.TableName)
+	if tableName == "" {
+		return nil, validationError("TableName is required")
+	}
+	if _, found := db.tables[tableName]; found {
+		return nil, &types.ResourceInUseException{Message: aws.String("Table already exists: " + tableName)}
+	}
+	attributeTypes := make(map[string]types.ScalarAttributeType)
+	for _, ad := range params.AttributeDefinitions {
+		name := aws.ToString(ad.AttributeName)
+		if _, found := attributeTypes[name]; found {
+			return nil, validationError("duplicate attribute definition %v", name)
+		}
+		attributeTypes[name] = ad.AttributeType
+	}
+	used := make(map[string]bool)
+	hashKey, rangeKey, err := checkSchema(params.KeySchema, attributeTypes, used)
+	if err != nil {
+		return nil, err
+	}
+	mode := params.BillingMode
+	if mode == "" {
+		mode = types.BillingModeProvisioned
+	}
+	if err := checkThroughput(mode, params.ProvisionedThroughput, "table"); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	arn := fmt.Sprintf("arn:aws:dynamodb:%v:%v:table/%v", Region, AccountId, tableName)
+	description := &types.TableDescription{
+		TableName:             aws.String(tableName),
+		TableArn:              aws.String(arn),
+		TableId:               aws.String(fmt.Sprintf("%x", now.UnixNano())),
+		TableStatus:           types.TableStatusActive,
+		CreationDateTime:      aws.Time(now),
+		AttributeDefinitions:  append([]types.AttributeDefinition(nil), params.AttributeDefinitions...),
+		KeySchema:             append([]types.KeySchemaElement(nil), params.KeySchema...),
+		BillingModeSummary:    &types.BillingModeSummary{BillingMode: mode},
+		ProvisionedThroughput: throughputDescription(params.ProvisionedThroughput),
+		ItemCount:             aws.Int64(0),
+		TableSizeBytes:        aws.Int64(0),
+	}
+	if err := describeTableOptions(description, params, now); err != nil {
+		return nil, err
+	}
+	table := &memoryTable{
+		description:    description,
+		ttl:            &types.TimeToLiveDescription{TimeToLiveStatus: types.TimeToLiveStatusDisabled},
+		hashKey:        hashKey,
+		rangeKey:       rangeKey,
+		attributeTypes: attributeTypes,
+		indexes:        make(map[string]*memoryIndex),
+		items:          make(map[string]map[string]types.AttributeValue),
+		tags:           append([]types.Tag(nil), params.Tags...),
+		backups:        disabledBackups(),
+	}
+	for _, gsi := range params.GlobalSecondaryIndexes {
+		name := aws.ToString(gsi.IndexName)
+		if _, found := table.indexes[name]; found || name == "" {
+			return nil, validationError("invalid or duplicate index name %q", name)
+		}
+		indexHash, indexRange, err := checkSchema(gsi.KeySchema, attributeTypes, used)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkThroughput(mode, gsi.ProvisionedThroughput, "index "+name); err != nil {
+			return nil, err
+		}
+		if gsi.Projection == nil {
+			return nil, validationError("projection is required for index %v", name)
+		}
+		table.indexes[name] = &memoryIndex{name: name, hashKey: indexHash, rangeKey: indexRange, projection: gsi.Projection, global: true}
+		description.GlobalSecondaryIndexes = append(description.GlobalSecondaryIndexes, types.GlobalSecondaryIndexDescription{
+			IndexName:             aws.String(name),
+			IndexArn:              aws.String(arn + "/index/" + name),
+			IndexStatus:           types.IndexStatusActive,
+			KeySchema:             append([]types.KeySchemaElement(nil), gsi.KeySchema...),
+			Projection:            gsi.Projection,
+			ProvisionedThroughput: throughputDescription(gsi.ProvisionedThroughput),
+			ItemCount:             aws.Int64(0),
+			IndexSizeBytes:        aws.Int64(0),
+		})
+	}
+	for _, lsi := range params.LocalSecondaryIndexes {
+		name := aws.ToString(lsi.IndexName)
+		if _, found := table.indexes[name]; found || name == "" {
+			return nil, validationError("invalid or duplicate index name %q", name)
+		}
+		indexHash, indexRange, err := checkSchema(lsi.KeySchema, attributeTypes, used)
+		if err != nil {
+			return nil, err
+		}
+		if indexHash != hashKey || indexRange == "" || rangeKey == "" {
+			return nil, validationError("local secondary index %v must share the hash key %v of a table with a range key", name, hashKey)
+		}
+		if lsi.Projection == nil {
+			return nil, validationError("projection is required for index %v", name)
+		}
+		table.indexes[name] = &memoryIndex{name: name, hashKey: indexHash, rangeKey: indexRange, projection: lsi.Projection}
+		description.LocalSecondaryIndexes = append(description.LocalSecondaryIndexes, types.LocalSecondaryIndexDescription{
+			IndexName:      aws.String(name),
+			IndexArn:       aws.String(arn + "/index/" + name),
+			KeySchema:      append([]types.KeySchemaElement(nil), lsi.KeySchema...),
+			Projection:     lsi.Projection,
+			ItemCount:      aws.Int64(0),
+			IndexSizeBytes: aws.Int64(0),
+		})
+	}
+	for name := range attributeTypes {
+		if !used[name] {
+			return nil, validationError("attribute %v is defined in AttributeDefinitions but not used in any key schema", name)
+		}
+	}
+	db.tables[tableName] = table
+	return &dynamodb.CreateTableOutput{TableDescription: table.describe()}, nil
+}
+
+func (t *memoryTable) describe() *types.TableDescription {
+	result := *t.description
+	result.ItemCount = aws.Int64(int64(len(t.items)))
+	var size int64
+	for _, item := range t.items {
+		size += itemSize(item)
+	}
+	result.TableSizeBytes = aws.Int64(size)
+	result.GlobalSecondaryIndexes = append([]types.GlobalSecondaryIndexDescription(nil), t.description.GlobalSecondaryIndexes...)
+	for i, gsi := range result.GlobalSecondaryIndexes {
+		result.GlobalSecondaryIndexes[i].ItemCount = aws.Int64(int64(len(t.sorted(t.indexes[aws.ToString(gsi.IndexName)]))))
+	}
+	result.LocalSecondaryIndexes = append([]types.LocalSecondaryIndexDescription(nil), t.description.LocalSecondaryIndexes...)
+	for i, lsi := range result.LocalSecondaryIndexes {
+		result.LocalSecondaryIndexes[i].ItemCount = aws.Int64(int64(len(t.sorted(t.indexes[aws.ToString(lsi.IndexName)]))))
+	}
+	return &result
+}
+
+func (db *MemoryDynamoDb) DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	if table, err := db.table(params.TableName); err != nil {
+		return nil, err
+	} else {
+		return &dynamodb.DescribeTableOutput{Table: table.describe()}, nil
+	}
+}
+
+func (db *MemoryDynamoDb) DeleteTable(ctx context.Context, params *dynamodb.DeleteTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteTableOutput, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	table, err := db.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+	if aws.ToBool(table.description.DeletionProtectionEnabled) {
+		return nil, validationError("Resource cannot be deleted as it is currently protected against deletion: %v", *params.TableName)
+	}
+	delete(db.tables, *params.TableName)
+	description := table.describe()
+	description.TableStatus = types.TableStatusDeleting
+	return &dynamodb.DeleteTableOutput{TableDescription: description}, nil
+}
+
+func (db *MemoryDynamoDb) UpdateTimeToLive(ctx context.Context, params *dynamodb.UpdateTimeToLiveInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateTimeToLiveOutput, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	table, err := db.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+	spec := params.TimeToLiveSpecification
+	if spec == nil || spec.Enabled == nil {
+		return nil, validationError("TimeToLiveSpecification with Enabled is required")
+	}
+	enabled := table.ttl.TimeToLiveStatus == types.TimeToLiveStatusEnabled
+	if *spec.Enabled {
+		if aws.ToString(spec.AttributeName) == "" {
+			return nil, validationError("TimeToLiveSpecification requires AttributeName when enabled")
+		}
+		if enabled {
+			return nil, validationError("TimeToLive is already enabled")
+		}
+		table.ttl = &types.TimeToLiveDescription{
+			AttributeName:    aws.String(*spec.AttributeName),
+			TimeToLiveStatus: types.TimeToLiveStatusEnabled,
+		}
+	} else {
+		if !enabled {
+			return nil, validationError("TimeToLive is already disabled")
+		}
+		table.ttl = &types.TimeToLiveDescription{TimeToLiveStatus: types.TimeToLiveStatusDisabled}
+	}
+	return &dynamodb.UpdateTimeToLiveOutput{TimeToLiveSpecification: spec}, nil
+}
+
+func (db *MemoryDynamoDb) DescribeTimeToLive(ctx context.Context, params *dynamodb.DescribeTimeToLiveInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTimeToLiveOutput, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	if table, err := db.table(params.TableName); err != nil {
+		return nil, err
+	} else {
+		ttl := *table.ttl
+		return &dynamodb.DescribeTimeToLiveOutput{TimeToLiveDescription: &ttl}, nil
+	}
+}
+
+func (t *memoryTable) checkKeyAttribute(item map[string]types.AttributeValue, name string, required bool) error {
+	value, found := item[name]
+	if !found {
+		if required {
+			return validationError("missing the key %v in the item", name)
+		}
+		return nil
+	}
+	expected := string(t.attributeTypes[name])
+	if actual := typeCode(value); actual != expected {
+		return validationError("type mismatch for key %v expected: %v actual: %v", name, expected, actual)
+	}
+	if size, _ := sizeOf(value); size == 0 && expected != "N" {
+		return validationError("the AttributeValue for a key attribute %v cannot contain an empty value", name)
+	}
+	return nil
+}
+
+func (t *memoryTable) checkItem(item map[string]types.AttributeValue) error {
+	if err := t.checkKey(item, false); err != nil {
+		return err
+	}
+	for _, index := range t.indexes {
+		for _, name := range []string{index.hashKey, index.rangeKey} {
+			if name != "" {
+				if err := t.checkKeyAttribute(item, name, false); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	for name, value := range item {
+		if err := checkValue(name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkValue(name string, value types.AttributeValue) error {
+	switch v := value.(type) {
+	case *types.AttributeValueMemberSS:
+		if len(v.Value) == 0 {
+			return validationError("an string set for %v may not be empty", name)
+		}
+	case *types.AttributeValueMemberNS:
+		if len(v.Value) == 0 {
+			return validationError("a number set for %v may not be empty", name)
+		}
+	case *types.AttributeValueMemberBS:
+		if len(v.Value) == 0 {
+			return validationError("a binary set for %v may not be empty", name)
+		}
+	case *types.AttributeValueMemberN:
+		if _, ok := parseNumber(v.Value); !ok {
+			return validationError("the parameter %v cannot be converted to a numeric value: %v", name, v.Value)
+		}
+	case *types.AttributeValueMemberL:
+		for _, e := range v.Value {
+			if err := checkValue(name, e); err != nil {
+				return err
+			}
+		}
+	case *types.AttributeValueMemberM:
+		for k, e := range v.Value {
+			if err := checkValue(name+"."+k, e); err != nil {
+				return err
+			}
+		}
+	case nil:
+		return validationError("attribute %v has no value", name)
+	}
+	return nil
+}
+
+func (t *memoryTable) checkKey(key map[string]types.AttributeValue, exact bool) error {
+	if err := t.checkKeyAttribute(key, t.hashKey, true); err != nil {
+		return err
+	}
+	if t.rangeKey != "" {
+		if err := t.checkKeyAttribute(key, t.rangeKey, true); err != nil {
+			return err
+		}
+	}
+	if exact {
+		expected := 1
+		if t.rangeKey != "" {
+			expected = 2
+		}
+		if len(key) != expected {
+			return validationError("the provided key element does not match the schema")
+		}
+	}
+	return nil
+}
+
+func (t *memoryTable) primaryKey(item map[string]types.AttributeValue) string {
+	result := keyString(item[t.hashKey])
+	if t.rangeKey != "" {
+		result += "\x00" + keyString(item[t.rangeKey])
+	}
+	return result
+}
+
+func (t *memoryTable) keyOf(item map[string]types.AttributeValue) map[string]types.AttributeValue {
+	result := map[string]types.AttributeValue{
+		t.hashKey: copyValue(item[t.hashKey]),
+	}
+	if t.rangeKey != "" {
+		result[t.rangeKey] = copyValue(item[t.rangeKey])
+	}
+	return result
+}
+
+func (t *memoryTable) ordering(index *memoryIndex) []string {
+	result := make([]string, 0, 4)
+	add := func(name string) {
+		if name == "" {
+			return
+		}
+		for _, n := range result {
+			if n == name {
+				return
+			}
+		}
+		result = append(result, name)
+	}
+	if index != nil {
+		add(index.hashKey)
+		add(index.rangeKey)
+	}
+	add(t.hashKey)
+	add(t.rangeKey)
+	return result
+}
+
+func compareBy(ordering []string, a, b map[string]types.AttributeValue) int {
+	for _, name := range ordering {
+		if cmp, _ := compareValues(a[name], b[name]); cmp != 0 {
+			return cmp
+		}
+	}
+	return 0
+}
+
+func (t *memoryTable) sorted(index *memoryIndex) []map[string]types.AttributeValue {
+	result := make([]map[string]types.AttributeValue, 0, len(t.items))
+	for _, item := range t.items {
+		if index != nil {
+			if _, found := item[index.hashKey]; !found {
+				continue
+			}
+			if _, found := item[index.rangeKey]; index.rangeKey != "" && !found {
+				continue
+			}
+		}
+		result = append(result, item)
+	}
+	ordering := t.ordering(index)
+	sort.Slice(result, func(i, j int) bool {
+		return compareBy(ordering, result[i], result[j]) < 0
+	})
+	return result
+}
+
+func (t *memoryTable) index(name *string) (*memoryIndex, error) {
+	if name == nil {
+		return nil, nil
+	}
+	if index, found := t.indexes[*name]; !found {
+		return nil, validationError("the table does not have the specified index: %v", *name)
+	} else {
+		return index, nil
+	}
+}
+
+func (t *memoryTable) projectIndex(index *memoryIndex, item map[string]types.AttributeValue) map[string]types.AttributeValue {
+	if index == nil || index.projection.ProjectionType == types.ProjectionTypeAll {
+		return copyItem(item)
+	}
+	result := t.keyOf(item)
+	names := []string{index.hashKey, index.rangeKey}
+	if index.projection.ProjectionType == types.ProjectionTypeInclude {
+		names = append(names, index.projection.NonKeyAttributes...)
+	}
+	for _, name := range names {
+		if value, found := item[name]; found && name != "" {
+			result[name] = copyValue(value)
+		}
+	}
+	return result
+}
+
+type pageRequest struct {
+	index      *memoryIndex
+	key        condition
+	filter     condition
+	projection []documentPath
+	forward    bool
+	limit      int32
+	startKey   map[string]types.AttributeValue
+	segment    *int32
+	total      *int32
+	countOnly  bool
+}
+
+type pageResult struct {
+	items   []map[string]types.AttributeValue
+	scanned int32
+	lastKey map[string]types.AttributeValue
+}
+
+func segmentOf(value types.AttributeValue, total int32) int32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(keyString(value)))
+	return int32(h.Sum32() % uint32(total))
+}
+
+func (t *memoryTable) page(request *pageRequest) (*pageResult, error) {
+	ordering := t.ordering(request.index)
+	candidates := t.sorted(request.index)
+	if !request.forward {
+		for i, j := 0, len(candidates)-1; i < j; i, j = i+1, j-1 {
+			candidates[i], candidates[j] = candidates[j], candidates[i]
+		}
+	}
+	if request.startKey != nil {
+		for _, name := range ordering {
+			if _, found := request.startKey[name]; !found {
+				return nil, validationError("the provided starting key is invalid: missing %v", name)
+			}
+		}
+	}
+	eligible := func(item map[string]types.AttributeValue) bool {
+		if request.key != nil && !request.key.matches(item) {
+			return false
+		}
+		if request.total != nil && segmentOf(item[t.hashKey], *request.total) != *request.segment {
+			return false
+		}
+		if request.startKey != nil {
+			cmp := compareBy(ordering, item, request.startKey)
+			if (request.forward && cmp <= 0) || (!request.forward && cmp >= 0) {
+				return false
+			}
+		}
+		return true
+	}
+	result := &pageResult{items: make([]map[string]types.AttributeValue, 0)}
+	var last map[string]types.AttributeValue
+	for i, item := range candidates {
+		if !eligible(item) {
+			continue
+		}
+		if request.limit > 0 && result.scanned == request.limit {
+			for _, rest := range candidates[i:] {
+				if eligible(rest) {
+					result.lastKey = make(map[string]types.AttributeValue)
+					for _, name := range ordering {
+						result.lastKey[name] = copyValue(last[name])
+					}
+					break
+				}
+			}
+			break
+		}
+		result.scanned++
+		last = item
+		if request.filter != nil && !request.filter.matches(item) {
+			continue
+		}
+		if request.countOnly {
+			result.items = append(result.items, nil)
+		} else if projected := t.projectIndex(request.index, item); request.projection != nil {
+			result.items = append(result.items, project(projected, request.projection))
+		} else {
+			result.items = append(result.items, projected)
+		}
+	}
+	return result, nil
+}
+
+func checkPlaceholders(names map[string]string, values map[string]types.AttributeValue) error {
+	if names != nil && len(names) == 0 {
+		return validationError("ExpressionAttributeNames must not be empty")
+	}
+	if values != nil && len(values) == 0 {
+		return validationError("ExpressionAttributeValues must not be empty")
+	}
+	return nil
+}
+
+func (db *MemoryDynamoDb) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	table, err := db.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+	if err := table.checkItem(params.Item); err != nil {
+		return nil, err
+	}
+	cond, err := parseRequestCondition(params.ConditionExpression, params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+	if err != nil {
+		return nil, err
+	}
+	key := table.primaryKey(params.Item)
+	old := table.items[key]
+	if cond != nil && !cond.matches(orEmpty(old)) {
+		return nil, conditionFailed(old, params.ReturnValuesOnConditionCheckFailure)
+	}
+	table.items[key] = copyItem(params.Item)
+	output := &dynamodb.PutItemOutput{}
+	if params.ReturnValues == types.ReturnValueAllOld {
+		output.Attributes = copyItem(old)
+	}
+	return output, nil
+}
+
+func orEmpty(item map[string]types.AttributeValue) map[string]types.AttributeValue {
+	if item == nil {
+		return map[string]types.AttributeValue{}
+	}
+	return item
+}
+
+func parseRequestCondition(expression *string, names map[string]string, values map[string]types.AttributeValue) (condition, error) {
+	if err := checkPlaceholders(names, values); err != nil {
+		return nil, err
+	}
+	ctx := newExprContext(names, values)
+	var result condition
+	if expression != nil {
+		var err error
+		if result, err = parseCondition(*expression, ctx); err != nil {
+			return nil, validationError("invalid ConditionExpression: %v", err)
+		}
+	}
+	if err := ctx.checkUnused(); err != nil {
+		return nil, validationError("%v", err)
+	}
+	return result, nil
+}
+
+func (db *MemoryDynamoDb) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	table, err := db.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+	if err := table.checkKey(params.Key, true); err != nil {
+		return nil, err
+	}
+	if err := checkPlaceholders(params.ExpressionAttributeNames, nil); err != nil {
+		return nil, err
+	}
+	exprCtx := newExprContext(params.ExpressionAttributeNames, nil)
+	var projection []documentPath
+	if params.ProjectionExpression != nil {
+		if projection, err = parseProjection(*params.ProjectionExpression, exprCtx); err != nil {
+			return nil, validationError("invalid ProjectionExpression: %v", err)
+		}
+	}
+	if err := exprCtx.checkUnused(); err != nil {
+		return nil, validationError("%v", err)
+	}
+	output := &dynamodb.GetItemOutput{}
+	if item, found := table.items[table.primaryKey(params.Key)]; found {
+		if projection != nil {
+			output.Item = project(item, projection)
+		} else {
+			output.Item = copyItem(item)
+		}
+	}
+	return output, nil
+}
+
+func (db *MemoryDynamoDb) DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	table, err := db.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+	if err := table.checkKey(params.Key, true); err != nil {
+		return nil, err
+	}
+	cond, err := parseRequestCondition(params.ConditionExpression, params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+	if err != nil {
+		return nil, err
+	}
+	key := table.primaryKey(params.Key)
+	old := table.items[key]
+	if cond != nil && !cond.matches(orEmpty(old)) {
+		return nil, conditionFailed(old, params.ReturnValuesOnConditionCheckFailure)
+	}
+	delete(table.items, key)
+	output := &dynamodb.DeleteItemOutput{}
+	if params.ReturnValues == types.ReturnValueAllOld {
+		output.Attributes = copyItem(old)
+	}
+	return output, nil
+}
+
+func checkKeyCondition(cond condition, index *memoryIndex, table *memoryTable) error {
+	hashKey, rangeKey := table.hashKey, table.rangeKey
+	if index != nil {
+		hashKey, rangeKey = index.hashKey, index.rangeKey
+	}
+	isHashCondition := func(c condition) bool {
+		if cmp, ok := c.(comparison); ok && cmp.op == "=" {
+			left, leftPath := cmp.left.(pathOperand)
+			right, rightPath := cmp.right.(pathOperand)
+			_, leftValue := cmp.left.(valueOperand)
+			_, rightValue := cmp.right.(valueOperand)
+			return (leftPath && rightValue && left.path.isTopLevel(hashKey)) ||
+				(rightPath && leftValue && right.path.isTopLevel(hashKey))
+		}
+		return false
+	}
+	isRangeCondition := func(c condition) bool {
+		if rangeKey == "" {
+			return false
+		}
+		switch r := c.(type) {
+		case comparison:
+			if r.op == "<>" {
+				return false
+			}
+			left, leftPath := r.left.(pathOperand)
+			right, rightPath := r.right.(pathOperand)
+			_, leftValue := r.left.(valueOperand)
+			_, rightValue := r.right.(valueOperand)
+			return (leftPath && rightValue && left.path.isTopLevel(rangeKey)) ||
+				(rightPath && leftValue && right.path.isTopLevel(rangeKey))
+		case betweenCondition:
+			subject, isPath := r.subject.(pathOperand)
+			_, lowValue := r.low.(valueOperand)
+			_, highValue := r.high.(valueOperand)
+			return isPath && lowValue && highValue && subject.path.isTopLevel(rangeKey)
+		case functionCondition:
+			_, isValue := r.argument.(valueOperand)
+			return r.name == "begins_with" && isValue && r.path.isTopLevel(rangeKey)
+		}
+		return false
+	}
+	if isHashCondition(cond) {
+		return nil
+	}
+	if and, ok := cond.(andCondition); ok {
+		if (isHashCondition(and.left) && isRangeCondition(and.right)) ||
+			(isHashCondition(and.right) && isRangeCondition(and.left)) {
+			return nil
+		}
+	}
+	return validationError("query key condition not supported: it must be an equality on %v optionally combined with a condition on %v", hashKey, rangeKey)
+}
+
+func (db *MemoryDynamoDb) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	table, err := db.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+	index, err := table.index(params.IndexName)
+	if err != nil {
+		return nil, err
+	}
+	if index != nil && index.global && aws.ToBool(params.ConsistentRead) {
+		return nil, validationError("consistent reads are not supported on global secondary indexes")
+	}
+	if params.KeyConditionExpression == nil {
+		return nil, validationError("KeyConditionExpression is required")
+	}
+	if err := checkPlaceholders(params.ExpressionAttributeNames, params.ExpressionAttributeValues); err != nil {
+		return nil, err
+	}
+	exprCtx := newExprContext(params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+	request := &pageRequest{
+		index:    index,
+		forward:  params.ScanIndexForward == nil || *params.ScanIndexForward,
+		limit:    aws.ToInt32(params.Limit),
+		startKey: params.ExclusiveStartKey,
+	}
+	if request.key, err = parseCondition(*params.KeyConditionExpression, exprCtx); err != nil {
+		return nil, validationError("invalid KeyConditionExpression: %v", err)
+	}
+	if err := checkKeyCondition(request.key, index, table); err != nil {
+		return nil, err
+	}
+	if err := parseReadExpressions(request, params.FilterExpression, params.ProjectionExpression, params.Select, exprCtx); err != nil {
+		return nil, err
+	}
+	if result, err := table.page(request); err != nil {
+		return nil, err
+	} else {
+		output := &dynamodb.QueryOutput{
+			Count:            int32(len(result.items)),
+			ScannedCount:     result.scanned,
+			LastEvaluatedKey: result.lastKey,
+		}
+		if !request.countOnly {
+			output.Items = result.items
+		}
+		return output, nil
+	}
+}
+
+func parseReadExpressions(request *pageRequest, filter, projection *string, selection types.Select, exprCtx *exprContext) (err error) {
+	if filter != nil {
+		if request.filter, err = parseCondition(*filter, exprCtx); err != nil {
+			return validationError("invalid FilterExpression: %v", err)
+		}
+	}
+	if projection != nil {
+		if request.projection, err = parseProjection(*projection, exprCtx); err != nil {
+			return validationError("invalid ProjectionExpression: %v", err)
+		}
+	}
+	if request.limit < 0 {
+		return validationError("limit must be positive")
+	}
+	request.countOnly = selection == types.SelectCount
+	if err := exprCtx.checkUnused(); err != nil {
+		return validationError("%v", err)
+	}
+	return nil
+}
+
+func (db *MemoryDynamoDb) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	table, err := db.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+	index, err := table.index(params.IndexName)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPlaceholders(params.ExpressionAttributeNames, params.ExpressionAttributeValues); err != nil {
+		return nil, err
+	}
+	if (params.Segment == nil) != (params.TotalSegments == nil) {
+		return nil, validationError("Segment and TotalSegments must be specified together")
+	}
+	if params.TotalSegments != nil && (*params.TotalSegments < 1 || *params.Segment < 0 || *params.Segment >= *params.TotalSegments) {
+		return nil, validationError("invalid Segment %v of TotalSegments %v", *params.Segment, *params.TotalSegments)
+	}
+	request := &pageRequest{
+		index:    index,
+		forward:  true,
+		limit:    aws.ToInt32(params.Limit),
+		startKey: params.ExclusiveStartKey,
+		segment:  params.Segment,
+		total:    params.TotalSegments,
+	}
+	exprCtx := newExprContext(params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+	if err := parseReadExpressions(request, params.FilterExpression, params.ProjectionExpression, params.Select, exprCtx); err != nil {
+		return nil, err
+	}
+	if result, err := table.page(request); err != nil {
+		return nil, err
+	} else {
+		output := &dynamodb.ScanOutput{
+			Count:            int32(len(result.items)),
+			ScannedCount:     result.scanned,
+			LastEvaluatedKey: result.lastKey,
+		}
+		if !request.countOnly {
+			output.Items = result.items
+		}
+		return output, nil
+	}
+}
@@ -0,0 +1,290 @@
+package ddbrepotest
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+	"github.com/rotmistrk/ddbrepo"
+	"github.com/rotmistrk/must"
+	"testing"
+)
+
+var _ ddbrepo.DynamoDbApi = (*MemoryDynamoDb)(nil)
+
+type order struct {
+	Customer string `ddb:"customer,hash-key"`
+	Number   int    `ddb:"number,range-key"`
+	State    string `ddb:"state" ddb-gsi:"byState hash-key"`
+	Total    float64
+	ExpireOn int64 `ddb:"expireOn,expire"`
+}
+
+func newOrderRepo(t *testing.T) (*ddbrepo.DdbRepo[order], *MemoryDynamoDb) {
+	db := NewMemoryDynamoDb()
+	repo := must.Must(ddbrepo.New[order]()).WithTableName("orders").WithDynamoDbApi(db)
+	if err := repo.TableCreate(); err != nil {
+		t.Fatalf("TableCreate() error = %v", err)
+	}
+	return repo, db
+}
+
+func TestMemoryDynamoDb_TableLifecycle(t *testing.T) {
+	repo, db := newOrderRepo(t)
+	report, err := repo.TableReport()
+	if err != nil {
+		t.Fatalf("TableReport() error = %v", err)
+	}
+	if report.Table.TableStatus != types.TableStatusActive {
+		t.Errorf("table status = %v, want %v", report.Table.TableStatus, types.TableStatusActive)
+	}
+	if len(report.Table.GlobalSecondaryIndexes) != 1 || *report.Table.GlobalSecondaryIndexes[0].IndexName != "byState" {
+		t.Errorf("unexpected indexes %v", ddbrepo.JsonLine(&report.Table.GlobalSecondaryIndexes))
+	}
+	if report.Ttl.TimeToLiveStatus != types.TimeToLiveStatusEnabled || aws.ToString(report.Ttl.AttributeName) != "expireOn" {
+		t.Errorf("unexpected ttl %v", ddbrepo.JsonLine(report.Ttl))
+	}
+	if err := repo.TableCreate(); err == nil {
+		t.Errorf("second TableCreate() succeeded, want error")
+	}
+	if err := repo.TableDelete(); err != nil {
+		t.Errorf("TableDelete() error = %v", err)
+	}
+	var notFound *types.ResourceNotFoundException
+	if _, err := db.DescribeTable(context.TODO(), &dynamodb.DescribeTableInput{TableName: aws.String("orders")}); !errors.As(err, &notFound) {
+		t.Errorf("DescribeTable() after delete error = %v, want ResourceNotFoundException", err)
+	}
+}
+
+func TestMemoryDynamoDb_Conditions(t *testing.T) {
+	repo, db := newOrderRepo(t)
+	first := &order{Customer: "alice", Number: 1, State: "new", Total: 10}
+	if err := repo.PutItemOp(first, ddbrepo.Insert); err != nil {
+		t.Fatalf("PutItemOp(Insert) error = %v", err)
+	}
+	var failed *types.ConditionalCheckFailedException
+	if err := repo.PutItemOp(first, ddbrepo.Insert); !errors.As(err, &failed) {
+		t.Errorf("repeated PutItemOp(Insert) error = %v, want ConditionalCheckFailedException", err)
+	}
+	if err := repo.PutItemOp(&order{Customer: "bob", Number: 1, State: "new"}, ddbrepo.Update); !errors.As(err, &failed) {
+		t.Errorf("PutItemOp(Update) of missing item error = %v, want ConditionalCheckFailedException", err)
+	}
+	got := &order{Customer: "alice", Number: 1}
+	if err := repo.GetItem(got); err != nil || *got != *first {
+		t.Errorf("GetItem() = %v, %v, want %v", got, err, first)
+	}
+	tests := []struct {
+		name      string
+		condition string
+		values    map[string]types.AttributeValue
+		names     map[string]string
+		want      bool
+	}{
+		{"comparison", "total > :v", map[string]types.AttributeValue{":v": &types.AttributeValueMemberN{Value: "9.5"}}, nil, true},
+		{"between", "#t BETWEEN :a AND :b", map[string]types.AttributeValue{":a": &types.AttributeValueMemberN{Value: "1"}, ":b": &types.AttributeValueMemberN{Value: "5"}}, map[string]string{"#t": "total"}, false},
+		{"in", "state IN (:a, :b)", map[string]types.AttributeValue{":a": &types.AttributeValueMemberS{Value: "old"}, ":b": &types.AttributeValueMemberS{Value: "new"}}, nil, true},
+		{"functions", "begins_with(state, :p) AND size(customer) = :n AND attribute_not_exists(missing)", map[string]types.AttributeValue{":p": &types.AttributeValueMemberS{Value: "ne"}, ":n": &types.AttributeValueMemberN{Value: "5"}}, nil, true},
+		{"not and or", "NOT (state = :s OR contains(customer, :c))", map[string]types.AttributeValue{":s": &types.AttributeValueMemberS{Value: "old"}, ":c": &types.AttributeValueMemberS{Value: "lic"}}, nil, false},
+		{"attribute type", "attribute_type(total, :t)", map[string]types.AttributeValue{":t": &types.AttributeValueMemberS{Value: "N"}}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := db.DeleteItem(context.TODO(), &dynamodb.DeleteItemInput{
+				TableName:                 aws.String("orders"),
+				Key:                       must.Must(ddbrepo.MarshalKey(repo, first, "")),
+				ConditionExpression:       aws.String(tt.condition),
+				ExpressionAttributeNames:  tt.names,
+				ExpressionAttributeValues: tt.values,
+			})
+			if tt.want && err != nil {
+				t.Errorf("condition %q failed: %v", tt.condition, err)
+			} else if !tt.want && !errors.As(err, &failed) {
+				t.Errorf("condition %q error = %v, want ConditionalCheckFailedException", tt.condition, err)
+			}
+			if err == nil {
+				_ = repo.PutItem(first)
+			}
+		})
+	}
+}
+
+func TestMemoryDynamoDb_Validation(t *testing.T) {
+	_, db := newOrderRepo(t)
+	tests := []struct {
+		name  string
+		input *dynamodb.PutItemInput
+	}{
+		{
+			name: "missing key",
+			input: &dynamodb.PutItemInput{
+				Item: map[string]types.AttributeValue{"customer": &types.AttributeValueMemberS{Value: "x"}},
+			},
+		},
+		{
+			name: "wrong key type",
+			input: &dynamodb.PutItemInput{
+				Item: map[string]types.AttributeValue{
+					"customer": &types.AttributeValueMemberS{Value: "x"},
+					"number":   &types.AttributeValueMemberS{Value: "1"},
+				},
+			},
+		},
+		{
+			name: "unused value",
+			input: &dynamodb.PutItemInput{
+				Item: map[string]types.AttributeValue{
+					"customer": &types.AttributeValueMemberS{Value: "x"},
+					"number":   &types.AttributeValueMemberN{Value: "1"},
+				},
+				ConditionExpression:       aws.String("attribute_not_exists(customer)"),
+				ExpressionAttributeValues: map[string]types.AttributeValue{":x": &types.AttributeValueMemberN{Value: "1"}},
+			},
+		},
+		{
+			name: "syntax error",
+			input: &dynamodb.PutItemInput{
+				Item: map[string]types.AttributeValue{
+					"customer": &types.AttributeValueMemberS{Value: "x"},
+					"number":   &types.AttributeValueMemberN{Value: "1"},
+				},
+				ConditionExpression: aws.String("attribute_not_exists(customer"),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.input.TableName = aws.String("orders")
+			var apiErr smithy.APIError
+			if _, err := db.PutItem(context.TODO(), tt.input); !errors.As(err, &apiErr) || apiErr.ErrorCode() != "ValidationException" {
+				t.Errorf("PutItem() error = %v, want ValidationException", err)
+			}
+		})
+	}
+}
+
+func TestMemoryDynamoDb_QueryAndScan(t *testing.T) {
+	repo, db := newOrderRepo(t)
+	for i := 1; i <= 5; i++ {
+		state := "open"
+		if i%2 == 0 {
+			state = "closed"
+		}
+		must.Must(0, repo.PutItem(&order{Customer: "alice", Number: i, State: state}))
+		must.Must(0, repo.PutItem(&order{Customer: "bob", Number: i, State: "archived"}))
+	}
+	numbers := make([]int, 0)
+	err := ddbrepo.QueryHkCbk(repo, func(r *order) error {
+		numbers = append(numbers, r.Number)
+		return nil
+	}, &order{Customer: "alice"})
+	if err != nil || len(numbers) != 5 || numbers[0] != 1 || numbers[4] != 5 {
+		t.Errorf("QueryHkCbk() = %v, %v", numbers, err)
+	}
+
+	pages := 0
+	var startKey map[string]types.AttributeValue
+	seen := 0
+	for {
+		output, err := db.Query(context.TODO(), &dynamodb.QueryInput{
+			TableName:                 aws.String("orders"),
+			IndexName:                 aws.String("byState"),
+			KeyConditionExpression:    aws.String("#s = :s"),
+			ExpressionAttributeNames:  map[string]string{"#s": "state"},
+			ExpressionAttributeValues: map[string]types.AttributeValue{":s": &types.AttributeValueMemberS{Value: "open"}},
+			Limit:                     aws.Int32(2),
+			ExclusiveStartKey:         startKey,
+		})
+		if err != nil {
+			t.Fatalf("Query() error = %v", err)
+		}
+		pages++
+		seen += len(output.Items)
+		if startKey = output.LastEvaluatedKey; startKey == nil {
+			break
+		}
+		if _, found := startKey["state"]; !found {
+			t.Errorf("index LastEvaluatedKey %v lacks the index key", startKey)
+		}
+	}
+	if pages != 2 || seen != 3 {
+		t.Errorf("paged index query saw %v items in %v pages, want 3 in 2", seen, pages)
+	}
+
+	if _, err := db.Query(context.TODO(), &dynamodb.QueryInput{
+		TableName:                 aws.String("orders"),
+		KeyConditionExpression:    aws.String("total = :t"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{":t": &types.AttributeValueMemberN{Value: "0"}},
+	}); err == nil {
+		t.Errorf("Query() on non-key attribute succeeded, want error")
+	}
+
+	scanned := 0
+	err = repo.ScanCbk(func(r *order) error {
+		scanned++
+		return nil
+	}, ddbrepo.ScanCondition("#n > :n", map[string]types.AttributeValue{":n": &types.AttributeValueMemberN{Value: "3"}}), func(input *dynamodb.ScanInput) error {
+		input.ExpressionAttributeNames = map[string]string{"#n": "number"}
+		return nil
+	})
+	if err != nil || scanned != 4 {
+		t.Errorf("ScanCbk() visited %v items, %v, want 4", scanned, err)
+	}
+	if items := db.TableItems("orders"); len(items) != 10 {
+		t.Errorf("TableItems() returned %v items, want 10", len(items))
+	}
+}
+
+func TestMemoryDynamoDb_UpdateTable(t *testing.T) {
+	_, db := newOrderRepo(t)
+	create := func(name string) types.GlobalSecondaryIndexUpdate {
+		return types.GlobalSecondaryIndexUpdate{Create: &types.CreateGlobalSecondaryIndexAction{
+			IndexName:             aws.String(name),
+			KeySchema:             []types.KeySchemaElement{{AttributeName: aws.String("total"), KeyType: types.KeyTypeHash}},
+			Projection:            &types.Projection{ProjectionType: types.ProjectionTypeKeysOnly},
+			ProvisionedThroughput: &types.ProvisionedThroughput{ReadCapacityUnits: aws.Int64(1), WriteCapacityUnits: aws.Int64(1)},
+		}}
+	}
+	definitions := []types.AttributeDefinition{{AttributeName: aws.String("total"), AttributeType: types.ScalarAttributeTypeN}}
+	tests := []struct {
+		name  string
+		input *dynamodb.UpdateTableInput
+	}{
+		{"two creates", &dynamodb.UpdateTableInput{AttributeDefinitions: definitions, GlobalSecondaryIndexUpdates: []types.GlobalSecondaryIndexUpdate{create("a"), create("b")}}},
+		{"undefined attribute", &dynamodb.UpdateTableInput{GlobalSecondaryIndexUpdates: []types.GlobalSecondaryIndexUpdate{create("a")}}},
+		{"existing index", &dynamodb.UpdateTableInput{AttributeDefinitions: definitions, GlobalSecondaryIndexUpdates: []types.GlobalSecondaryIndexUpdate{create("byState")}}},
+		{"missing index", &dynamodb.UpdateTableInput{GlobalSecondaryIndexUpdates: []types.GlobalSecondaryIndexUpdate{{Update: &types.UpdateGlobalSecondaryIndexAction{IndexName: aws.String("byNothing")}}}}},
+		{"pay per request with capacity", &dynamodb.UpdateTableInput{BillingMode: types.BillingModePayPerRequest, ProvisionedThroughput: &types.ProvisionedThroughput{ReadCapacityUnits: aws.Int64(1), WriteCapacityUnits: aws.Int64(1)}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.input.TableName = aws.String("orders")
+			var apiErr smithy.APIError
+			if _, err := db.UpdateTable(context.TODO(), tt.input); !errors.As(err, &apiErr) {
+				t.Errorf("UpdateTable() error = %v, want an API error", err)
+			}
+		})
+	}
+
+	input := &dynamodb.UpdateTableInput{TableName: aws.String("orders"), AttributeDefinitions: definitions, GlobalSecondaryIndexUpdates: []types.GlobalSecondaryIndexUpdate{create("byTotal")}}
+	if _, err := db.UpdateTable(context.TODO(), input); err != nil {
+		t.Fatal(err)
+	}
+	input = &dynamodb.UpdateTableInput{TableName: aws.String("orders"), GlobalSecondaryIndexUpdates: []types.GlobalSecondaryIndexUpdate{{Delete: &types.DeleteGlobalSecondaryIndexAction{IndexName: aws.String("byState")}}}}
+	output, err := db.UpdateTable(context.TODO(), input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0)
+	for _, gsi := range output.TableDescription.GlobalSecondaryIndexes {
+		names = append(names, aws.ToString(gsi.IndexName))
+	}
+	attributes := make([]string, 0)
+	for _, ad := range output.TableDescription.AttributeDefinitions {
+		attributes = append(attributes, aws.ToString(ad.AttributeName))
+	}
+	if len(names) != 1 || names[0] != "byTotal" || len(attributes) != 3 {
+		t.Errorf("UpdateTable() indexes = %v, attributes = %v, want [byTotal] and 3 attributes", names, attributes)
+	}
+}
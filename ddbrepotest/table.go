@@ -0,0 +1,260 @@
+package ddbrepotest
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"sort"
+	"time"
+)
+
+// DefaultKmsKeyArn is reported as the key of tables encrypted with KMS when
+// no key is given.
+const DefaultKmsKeyArn = "arn:aws:kms:" + Region + ":" + AccountId + ":alias/aws/dynamodb"
+
+func describeTableOptions(description *types.TableDescription, params *dynamodb.CreateTableInput, now time.Time) error {
+	if sse := params.SSESpecification; sse != nil && aws.ToBool(sse.Enabled) {
+		if sse.SSEType != "" && sse.SSEType != types.SSETypeKms {
+			return validationError("unsupported SSEType %v", sse.SSEType)
+		}
+		key := aws.ToString(sse.KMSMasterKeyId)
+		if key == "" {
+			key = DefaultKmsKeyArn
+		}
+		description.SSEDescription = &types.SSEDescription{
+			Status:          types.SSEStatusEnabled,
+			SSEType:         types.SSETypeKms,
+			KMSMasterKeyArn: aws.String(key),
+		}
+	} else if sse != nil && sse.KMSMasterKeyId != nil {
+		return validationError("KMSMasterKeyId can only be specified when SSE is enabled")
+	}
+	if stream := params.StreamSpecification; stream != nil && aws.ToBool(stream.StreamEnabled) {
+		switch stream.StreamViewType {
+		case types.StreamViewTypeKeysOnly, types.StreamViewTypeNewImage, types.StreamViewTypeOldImage, types.StreamViewTypeNewAndOldImages:
+		default:
+			return validationError("invalid StreamViewType %q", stream.StreamViewType)
+		}
+		label := now.UTC().Format("2006-01-02T15:04:05.000")
+		description.StreamSpecification = &types.StreamSpecification{StreamEnabled: aws.Bool(true), StreamViewType: stream.StreamViewType}
+		description.LatestStreamLabel = aws.String(label)
+		description.LatestStreamArn = aws.String(fmt.Sprintf("%v/stream/%v", aws.ToString(description.TableArn), label))
+	}
+	switch params.TableClass {
+	case "", types.TableClassStandard:
+		description.TableClassSummary = &types.TableClassSummary{TableClass: types.TableClassStandard}
+	case types.TableClassStandardInfrequentAccess:
+		description.TableClassSummary = &types.TableClassSummary{TableClass: params.TableClass}
+	default:
+		return validationError("invalid TableClass %q", params.TableClass)
+	}
+	description.DeletionProtectionEnabled = aws.Bool(aws.ToBool(params.DeletionProtectionEnabled))
+	for _, tag := range params.Tags {
+		if aws.ToString(tag.Key) == "" {
+			return validationError("tag keys must not be empty")
+		}
+	}
+	return nil
+}
+
+func disabledBackups() *types.ContinuousBackupsDescription {
+	return &types.ContinuousBackupsDescription{
+		ContinuousBackupsStatus: types.ContinuousBackupsStatusEnabled,
+		PointInTimeRecoveryDescription: &types.PointInTimeRecoveryDescription{
+			PointInTimeRecoveryStatus: types.PointInTimeRecoveryStatusDisabled,
+		},
+	}
+}
+
+func (db *MemoryDynamoDb) UpdateContinuousBackups(ctx context.Context, params *dynamodb.UpdateContinuousBackupsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateContinuousBackupsOutput, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	table, err := db.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+	spec := params.PointInTimeRecoverySpecification
+	if spec == nil || spec.PointInTimeRecoveryEnabled == nil {
+		return nil, validationError("PointInTimeRecoverySpecification with PointInTimeRecoveryEnabled is required")
+	}
+	table.backups = disabledBackups()
+	if *spec.PointInTimeRecoveryEnabled {
+		now := time.Now()
+		table.backups.PointInTimeRecoveryDescription = &types.PointInTimeRecoveryDescription{
+			PointInTimeRecoveryStatus:  types.PointInTimeRecoveryStatusEnabled,
+			EarliestRestorableDateTime: aws.Time(now),
+			LatestRestorableDateTime:   aws.Time(now),
+		}
+	}
+	backups := *table.backups
+	return &dynamodb.UpdateContinuousBackupsOutput{ContinuousBackupsDescription: &backups}, nil
+}
+
+func (db *MemoryDynamoDb) DescribeContinuousBackups(ctx context.Context, params *dynamodb.DescribeContinuousBackupsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeContinuousBackupsOutput, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	if table, err := db.table(params.TableName); err != nil {
+		return nil, err
+	} else {
+		backups := *table.backups
+		return &dynamodb.DescribeContinuousBackupsOutput{ContinuousBackupsDescription: &backups}, nil
+	}
+}
+
+// ListTagsOfResource returns the tags of the table with the given ARN in a
+// single page.
+func (db *MemoryDynamoDb) ListTagsOfResource(ctx context.Context, params *dynamodb.ListTagsOfResourceInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ListTagsOfResourceOutput, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	arn := aws.ToString(params.ResourceArn)
+	for _, table := range db.tables {
+		if aws.ToString(table.description.TableArn) == arn {
+			return &dynamodb.ListTagsOfResourceOutput{Tags: append([]types.Tag(nil), table.tags...)}, nil
+		}
+	}
+	return nil, &types.ResourceNotFoundException{Message: aws.String("Requested resource not found: ResourceArn: " + arn + " not found")}
+}
+
+// UpdateTable changes the billing mode and throughput of a table and creates,
+// deletes or updates its global secondary indexes; like DynamoDB it creates
+// or deletes a single index per request, and new indexes are active at once.
+func (db *MemoryDynamoDb) UpdateTable(ctx context.Context, params *dynamodb.UpdateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateTableOutput, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	table, err := db.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+	description := table.description
+	mode := description.BillingModeSummary.BillingMode
+	if params.BillingMode != "" {
+		mode = params.BillingMode
+	}
+	switching := mode != description.BillingModeSummary.BillingMode
+	if params.ProvisionedThroughput != nil || (switching && mode == types.BillingModeProvisioned) {
+		if err := checkThroughput(mode, params.ProvisionedThroughput, "table"); err != nil {
+			return nil, err
+		}
+	}
+	attributeTypes := make(map[string]types.ScalarAttributeType, len(table.attributeTypes))
+	for name, attributeType := range table.attributeTypes {
+		attributeTypes[name] = attributeType
+	}
+	for _, ad := range params.AttributeDefinitions {
+		name := aws.ToString(ad.AttributeName)
+		if existing, found := attributeTypes[name]; found && existing != ad.AttributeType {
+			return nil, validationError("attribute %v is already defined as %v", name, existing)
+		}
+		attributeTypes[name] = ad.AttributeType
+	}
+	changes, updated := 0, make(map[string]*types.ProvisionedThroughput)
+	for _, update := range params.GlobalSecondaryIndexUpdates {
+		switch {
+		case update.Create != nil:
+			changes++
+			name := aws.ToString(update.Create.IndexName)
+			if _, found := table.indexes[name]; found || name == "" {
+				return nil, validationError("invalid or duplicate index name %q", name)
+			}
+			if _, _, err := checkSchema(update.Create.KeySchema, attributeTypes, make(map[string]bool)); err != nil {
+				return nil, err
+			}
+			if err := checkThroughput(mode, update.Create.ProvisionedThroughput, "index "+name); err != nil {
+				return nil, err
+			}
+			if update.Create.Projection == nil {
+				return nil, validationError("projection is required for index %v", name)
+			}
+		case update.Delete != nil:
+			changes++
+			name := aws.ToString(update.Delete.IndexName)
+			if index, found := table.indexes[name]; !found || !index.global {
+				return nil, &types.ResourceNotFoundException{Message: aws.String("Requested resource not found: index " + name)}
+			}
+		case update.Update != nil:
+			name := aws.ToString(update.Update.IndexName)
+			if index, found := table.indexes[name]; !found || !index.global {
+				return nil, &types.ResourceNotFoundException{Message: aws.String("Requested resource not found: index " + name)}
+			}
+			if err := checkThroughput(mode, update.Update.ProvisionedThroughput, "index "+name); err != nil {
+				return nil, err
+			}
+			updated[name] = update.Update.ProvisionedThroughput
+		}
+	}
+	if changes > 1 {
+		return nil, validationError("only one global secondary index can be created or deleted per UpdateTable request")
+	}
+	if switching && mode == types.BillingModeProvisioned {
+		for _, gsi := range description.GlobalSecondaryIndexes {
+			if updated[aws.ToString(gsi.IndexName)] == nil {
+				return nil, validationError("index %v: ProvisionedThroughput is required when switching to PROVISIONED", aws.ToString(gsi.IndexName))
+			}
+		}
+	}
+
+	table.attributeTypes = attributeTypes
+	description.BillingModeSummary = &types.BillingModeSummary{BillingMode: mode}
+	if params.ProvisionedThroughput != nil || mode == types.BillingModePayPerRequest {
+		description.ProvisionedThroughput = throughputDescription(params.ProvisionedThroughput)
+	}
+	indexes := make([]types.GlobalSecondaryIndexDescription, 0, len(description.GlobalSecondaryIndexes)+1)
+	for _, gsi := range description.GlobalSecondaryIndexes {
+		if throughput, found := updated[aws.ToString(gsi.IndexName)]; found {
+			gsi.ProvisionedThroughput = throughputDescription(throughput)
+		} else if mode == types.BillingModePayPerRequest {
+			gsi.ProvisionedThroughput = throughputDescription(nil)
+		}
+		indexes = append(indexes, gsi)
+	}
+	for _, update := range params.GlobalSecondaryIndexUpdates {
+		if create := update.Create; create != nil {
+			name := aws.ToString(create.IndexName)
+			indexHash, indexRange := keyNames(create.KeySchema)
+			table.indexes[name] = &memoryIndex{name: name, hashKey: indexHash, rangeKey: indexRange, projection: create.Projection, global: true}
+			indexes = append(indexes, types.GlobalSecondaryIndexDescription{
+				IndexName:             aws.String(name),
+				IndexArn:              aws.String(aws.ToString(description.TableArn) + "/index/" + name),
+				IndexStatus:           types.IndexStatusActive,
+				KeySchema:             append([]types.KeySchemaElement(nil), create.KeySchema...),
+				Projection:            create.Projection,
+				ProvisionedThroughput: throughputDescription(create.ProvisionedThroughput),
+				ItemCount:             aws.Int64(0),
+				IndexSizeBytes:        aws.Int64(0),
+			})
+		} else if update.Delete != nil {
+			name := aws.ToString(update.Delete.IndexName)
+			delete(table.indexes, name)
+			for i, gsi := range indexes {
+				if aws.ToString(gsi.IndexName) == name {
+					indexes = append(indexes[:i], indexes[i+1:]...)
+					break
+				}
+			}
+		}
+	}
+	description.GlobalSecondaryIndexes = indexes
+	table.dropUnusedAttributes()
+	return &dynamodb.UpdateTableOutput{TableDescription: table.describe()}, nil
+}
+
+func (t *memoryTable) dropUnusedAttributes() {
+	used := map[string]bool{t.hashKey: true, t.rangeKey: true}
+	for _, index := range t.indexes {
+		used[index.hashKey], used[index.rangeKey] = true, true
+	}
+	definitions := make([]types.AttributeDefinition, 0, len(used))
+	for name, attributeType := range t.attributeTypes {
+		if !used[name] {
+			delete(t.attributeTypes, name)
+		} else {
+			definitions = append(definitions, types.AttributeDefinition{AttributeName: aws.String(name), AttributeType: attributeType})
+		}
+	}
+	sort.Slice(definitions, func(i, j int) bool {
+		return aws.ToString(definitions[i].AttributeName) < aws.ToString(definitions[j].AttributeName)
+	})
+	t.description.AttributeDefinitions = definitions
+}
@@ -0,0 +1,201 @@
+package ddbrepotest
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"strings"
+)
+
+const maxTransactItems = 100
+
+type transactWrite struct {
+	table      *memoryTable
+	primaryKey string
+	cond       condition
+	returnOld  types.ReturnValuesOnConditionCheckFailure
+	apply      func()
+}
+
+func (db *MemoryDynamoDb) prepareTransactWrite(item types.TransactWriteItem) (*transactWrite, error) {
+	result := &transactWrite{}
+	var err error
+	count := 0
+	if c := item.ConditionCheck; c != nil {
+		count++
+		if result.table, err = db.table(c.TableName); err != nil {
+			return nil, err
+		}
+		if err := result.table.checkKey(c.Key, true); err != nil {
+			return nil, err
+		}
+		if c.ConditionExpression == nil {
+			return nil, validationError("ConditionExpression is required for ConditionCheck")
+		}
+		if result.cond, err = parseRequestCondition(c.ConditionExpression, c.ExpressionAttributeNames, c.ExpressionAttributeValues); err != nil {
+			return nil, err
+		}
+		result.primaryKey = result.table.primaryKey(c.Key)
+		result.returnOld = c.ReturnValuesOnConditionCheckFailure
+		result.apply = func() {}
+	}
+	if p := item.Put; p != nil {
+		count++
+		if result.table, err = db.table(p.TableName); err != nil {
+			return nil, err
+		}
+		if err := result.table.checkItem(p.Item); err != nil {
+			return nil, err
+		}
+		if result.cond, err = parseRequestCondition(p.ConditionExpression, p.ExpressionAttributeNames, p.ExpressionAttributeValues); err != nil {
+			return nil, err
+		}
+		table, primaryKey, stored := result.table, result.table.primaryKey(p.Item), copyItem(p.Item)
+		result.primaryKey = primaryKey
+		result.returnOld = p.ReturnValuesOnConditionCheckFailure
+		result.apply = func() { table.items[primaryKey] = stored }
+	}
+	if d := item.Delete; d != nil {
+		count++
+		if result.table, err = db.table(d.TableName); err != nil {
+			return nil, err
+		}
+		if err := result.table.checkKey(d.Key, true); err != nil {
+			return nil, err
+		}
+		if result.cond, err = parseRequestCondition(d.ConditionExpression, d.ExpressionAttributeNames, d.ExpressionAttributeValues); err != nil {
+			return nil, err
+		}
+		table, primaryKey := result.table, result.table.primaryKey(d.Key)
+		result.primaryKey = primaryKey
+		result.returnOld = d.ReturnValuesOnConditionCheckFailure
+		result.apply = func() { delete(table.items, primaryKey) }
+	}
+	if u := item.Update; u != nil {
+		count++
+		if result.table, err = db.table(u.TableName); err != nil {
+			return nil, err
+		}
+		if err := result.table.checkKey(u.Key, true); err != nil {
+			return nil, err
+		}
+		actions, cond, err := parseUpdateRequest(u.UpdateExpression, u.ConditionExpression, u.ExpressionAttributeNames, u.ExpressionAttributeValues)
+		if err != nil {
+			return nil, err
+		}
+		table, primaryKey := result.table, result.table.primaryKey(u.Key)
+		updated, err := table.update(u.Key, actions)
+		if err != nil {
+			return nil, err
+		}
+		result.cond = cond
+		result.primaryKey = primaryKey
+		result.returnOld = u.ReturnValuesOnConditionCheckFailure
+		result.apply = func() { table.items[primaryKey] = updated }
+	}
+	if count != 1 {
+		return nil, validationError("each transaction item must contain exactly one of ConditionCheck, Put, Delete or Update")
+	}
+	return result, nil
+}
+
+func (db *MemoryDynamoDb) TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	if len(params.TransactItems) == 0 || len(params.TransactItems) > maxTransactItems {
+		return nil, validationError("TransactItems must contain between 1 and %v items", maxTransactItems)
+	}
+	writes := make([]*transactWrite, 0, len(params.TransactItems))
+	seen := make(map[*memoryTable]map[string]bool)
+	for _, item := range params.TransactItems {
+		write, err := db.prepareTransactWrite(item)
+		if err != nil {
+			return nil, err
+		}
+		if seen[write.table] == nil {
+			seen[write.table] = make(map[string]bool)
+		}
+		if seen[write.table][write.primaryKey] {
+			return nil, validationError("transaction request cannot include multiple operations on one item")
+		}
+		seen[write.table][write.primaryKey] = true
+		writes = append(writes, write)
+	}
+	reasons := make([]types.CancellationReason, len(writes))
+	codes := make([]string, len(writes))
+	cancelled := false
+	for i, write := range writes {
+		old := write.table.items[write.primaryKey]
+		if write.cond != nil && !write.cond.matches(orEmpty(old)) {
+			cancelled = true
+			reasons[i] = types.CancellationReason{
+				Code:    aws.String("ConditionalCheckFailed"),
+				Message: aws.String("The conditional request failed"),
+			}
+			if write.returnOld == types.ReturnValuesOnConditionCheckFailureAllOld {
+				reasons[i].Item = copyItem(old)
+			}
+		} else {
+			reasons[i] = types.CancellationReason{Code: aws.String("None")}
+		}
+		codes[i] = aws.ToString(reasons[i].Code)
+	}
+	if cancelled {
+		return nil, &types.TransactionCanceledException{
+			Message:             aws.String("Transaction cancelled, please refer cancellation reasons for specific reasons [" + strings.Join(codes, ", ") + "]"),
+			CancellationReasons: reasons,
+		}
+	}
+	for _, write := range writes {
+		write.apply()
+	}
+	return &dynamodb.TransactWriteItemsOutput{}, nil
+}
+
+func (db *MemoryDynamoDb) TransactGetItems(ctx context.Context, params *dynamodb.TransactGetItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactGetItemsOutput, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	if len(params.TransactItems) == 0 || len(params.TransactItems) > maxTransactItems {
+		return nil, validationError("TransactItems must contain between 1 and %v items", maxTransactItems)
+	}
+	output := &dynamodb.TransactGetItemsOutput{
+		Responses: make([]types.ItemResponse, 0, len(params.TransactItems)),
+	}
+	for _, item := range params.TransactItems {
+		get := item.Get
+		if get == nil {
+			return nil, validationError("each transaction item must contain Get")
+		}
+		table, err := db.table(get.TableName)
+		if err != nil {
+			return nil, err
+		}
+		if err := table.checkKey(get.Key, true); err != nil {
+			return nil, err
+		}
+		if err := checkPlaceholders(get.ExpressionAttributeNames, nil); err != nil {
+			return nil, err
+		}
+		exprCtx := newExprContext(get.ExpressionAttributeNames, nil)
+		var projection []documentPath
+		if get.ProjectionExpression != nil {
+			if projection, err = parseProjection(*get.ProjectionExpression, exprCtx); err != nil {
+				return nil, validationError("invalid ProjectionExpression: %v", err)
+			}
+		}
+		if err := exprCtx.checkUnused(); err != nil {
+			return nil, validationError("%v", err)
+		}
+		response := types.ItemResponse{}
+		if stored, found := table.items[table.primaryKey(get.Key)]; found {
+			if projection != nil {
+				response.Item = project(stored, projection)
+			} else {
+				response.Item = copyItem(stored)
+			}
+		}
+		output.Responses = append(output.Responses, response)
+	}
+	return output, nil
+}
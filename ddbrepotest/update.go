@@ -0,0 +1,478 @@
+package ddbrepotest
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"math/big"
+	"strings"
+)
+
+type updateAction struct {
+	clause string
+	path   documentPath
+	value  valueExpression
+}
+
+type valueExpression interface {
+	compute(item map[string]types.AttributeValue) (types.AttributeValue, error)
+}
+
+type operandValue struct {
+	operand operand
+}
+
+func (v operandValue) compute(item map[string]types.AttributeValue) (types.AttributeValue, error) {
+	if value, found := v.operand.evaluate(item); !found {
+		return nil, fmt.Errorf("the provided expression refers to an attribute that does not exist in the item")
+	} else {
+		return value, nil
+	}
+}
+
+type arithmeticValue struct {
+	op          string
+	left, right valueExpression
+}
+
+func (v arithmeticValue) compute(item map[string]types.AttributeValue) (types.AttributeValue, error) {
+	left, err := v.left.compute(item)
+	if err != nil {
+		return nil, err
+	}
+	right, err := v.right.compute(item)
+	if err != nil {
+		return nil, err
+	}
+	x, xOk := left.(*types.AttributeValueMemberN)
+	y, yOk := right.(*types.AttributeValueMemberN)
+	if !xOk || !yOk {
+		return nil, fmt.Errorf("incorrect operand type for operator or function; operator: %v", v.op)
+	}
+	a, _ := parseNumber(x.Value)
+	b, _ := parseNumber(y.Value)
+	if v.op == "-" {
+		b = new(big.Rat).Neg(b)
+	}
+	return &types.AttributeValueMemberN{Value: formatNumber(new(big.Rat).Add(a, b))}, nil
+}
+
+type ifNotExistsValue struct {
+	path     documentPath
+	fallback valueExpression
+}
+
+func (v ifNotExistsValue) compute(item map[string]types.AttributeValue) (types.AttributeValue, error) {
+	if value, found := v.path.resolve(item); found {
+		return value, nil
+	}
+	return v.fallback.compute(item)
+}
+
+type listAppendValue struct {
+	first, second valueExpression
+}
+
+func (v listAppendValue) compute(item map[string]types.AttributeValue) (types.AttributeValue, error) {
+	first, err := v.first.compute(item)
+	if err != nil {
+		return nil, err
+	}
+	second, err := v.second.compute(item)
+	if err != nil {
+		return nil, err
+	}
+	x, xOk := first.(*types.AttributeValueMemberL)
+	y, yOk := second.(*types.AttributeValueMemberL)
+	if !xOk || !yOk {
+		return nil, fmt.Errorf("incorrect operand type for operator or function; operator or function: list_append")
+	}
+	result := make([]types.AttributeValue, 0, len(x.Value)+len(y.Value))
+	result = append(result, x.Value...)
+	return &types.AttributeValueMemberL{Value: append(result, y.Value...)}, nil
+}
+
+func formatNumber(n *big.Rat) string {
+	if n.IsInt() {
+		return n.Num().String()
+	}
+	result := strings.TrimRight(n.FloatString(38), "0")
+	return strings.TrimSuffix(result, ".")
+}
+
+func parseUpdate(expression string, ctx *exprContext) ([]updateAction, error) {
+	p, err := newParser(expression, ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]updateAction, 0)
+	seenClauses := make(map[string]bool)
+	for p.peek().kind != tokEOF {
+		t := p.next()
+		clause := strings.ToUpper(t.text)
+		if t.kind != tokIdent || (clause != "SET" && clause != "REMOVE" && clause != "ADD" && clause != "DELETE") {
+			return nil, fmt.Errorf("syntax error: unexpected %q", t.text)
+		}
+		if seenClauses[clause] {
+			return nil, fmt.Errorf("the %v section can only be used once in an update expression", clause)
+		}
+		seenClauses[clause] = true
+		for {
+			action := updateAction{clause: clause}
+			if action.path, err = p.parsePath(); err != nil {
+				return nil, err
+			}
+			switch clause {
+			case "SET":
+				if op := p.next(); op.kind != tokOp || op.text != "=" {
+					return nil, fmt.Errorf("syntax error: expected = after %v", action.path)
+				}
+				if action.value, err = p.parseSetValue(); err != nil {
+					return nil, err
+				}
+			case "ADD", "DELETE":
+				if action.value, err = p.parseValueTerm(); err != nil {
+					return nil, err
+				}
+			}
+			result = append(result, action)
+			if p.peek().kind != tokComma {
+				break
+			}
+			p.next()
+		}
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("update expression must not be empty")
+	}
+	for i, a := range result {
+		for _, b := range result[i+1:] {
+			if overlaps(a.path, b.path) {
+				return nil, fmt.Errorf("two document paths overlap with each other: [%v], [%v]", a.path, b.path)
+			}
+		}
+	}
+	return result, nil
+}
+
+func overlaps(a, b documentPath) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *parser) parseSetValue() (valueExpression, error) {
+	left, err := p.parseValueTerm()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind == tokOp && (t.text == "+" || t.text == "-") {
+		p.next()
+		right, err := p.parseValueTerm()
+		if err != nil {
+			return nil, err
+		}
+		return arithmeticValue{t.text, left, right}, nil
+	}
+	return left, nil
+}
+
+func (p *parser) parseValueTerm() (valueExpression, error) {
+	t := p.peek()
+	if t.kind == tokIdent && p.tokens[p.pos+1].kind == tokLParen {
+		switch strings.ToLower(t.text) {
+		case "if_not_exists":
+			p.next()
+			p.next()
+			path, err := p.parsePath()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.expect(tokComma, ","); err != nil {
+				return nil, err
+			}
+			fallback, err := p.parseValueTerm()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.expect(tokRParen, ")"); err != nil {
+				return nil, err
+			}
+			return ifNotExistsValue{path, fallback}, nil
+		case "list_append":
+			p.next()
+			p.next()
+			first, err := p.parseValueTerm()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.expect(tokComma, ","); err != nil {
+				return nil, err
+			}
+			second, err := p.parseValueTerm()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.expect(tokRParen, ")"); err != nil {
+				return nil, err
+			}
+			return listAppendValue{first, second}, nil
+		}
+	}
+	if operand, err := p.parseOperand(); err != nil {
+		return nil, err
+	} else {
+		return operandValue{operand}, nil
+	}
+}
+
+// applyUpdate computes every value against the original item as DynamoDB does.
+func applyUpdate(item map[string]types.AttributeValue, actions []updateAction) (map[string]types.AttributeValue, error) {
+	values := make([]types.AttributeValue, len(actions))
+	for i, action := range actions {
+		if action.value != nil {
+			value, err := action.value.compute(item)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+	}
+	result := copyItem(item)
+	for i, action := range actions {
+		switch action.clause {
+		case "SET":
+			setPath(result, action.path, copyValue(values[i]))
+		case "REMOVE":
+			removePath(result, action.path)
+		case "ADD":
+			current, found := action.path.resolve(result)
+			if updated, err := addValue(current, found, values[i]); err != nil {
+				return nil, err
+			} else {
+				setPath(result, action.path, updated)
+			}
+		case "DELETE":
+			current, found := action.path.resolve(result)
+			if !found {
+				continue
+			}
+			if updated, err := deleteFromSet(current, values[i]); err != nil {
+				return nil, err
+			} else if updated == nil {
+				removePath(result, action.path)
+			} else {
+				setPath(result, action.path, updated)
+			}
+		}
+	}
+	return result, nil
+}
+
+func removePath(item map[string]types.AttributeValue, path documentPath) {
+	parent := documentPath(path[:len(path)-1])
+	last := path[len(path)-1]
+	var container types.AttributeValue = &types.AttributeValueMemberM{Value: item}
+	if len(parent) > 0 {
+		var found bool
+		if container, found = parent.resolve(item); !found {
+			return
+		}
+	}
+	switch c := container.(type) {
+	case *types.AttributeValueMemberM:
+		delete(c.Value, last.name)
+	case *types.AttributeValueMemberL:
+		if last.isIndex && last.index < len(c.Value) {
+			c.Value = append(c.Value[:last.index], c.Value[last.index+1:]...)
+		}
+	}
+}
+
+func addValue(current types.AttributeValue, found bool, value types.AttributeValue) (types.AttributeValue, error) {
+	switch v := value.(type) {
+	case *types.AttributeValueMemberN:
+		if !found {
+			return copyValue(v), nil
+		}
+		return arithmeticValue{"+", operandValue{valueOperand{current}}, operandValue{valueOperand{v}}}.compute(nil)
+	case *types.AttributeValueMemberSS:
+		if !found {
+			return copyValue(v), nil
+		} else if c, ok := current.(*types.AttributeValueMemberSS); ok {
+			return &types.AttributeValueMemberSS{Value: union(c.Value, v.Value, func(s string) string { return s })}, nil
+		}
+	case *types.AttributeValueMemberNS:
+		if !found {
+			return copyValue(v), nil
+		} else if c, ok := current.(*types.AttributeValueMemberNS); ok {
+			return &types.AttributeValueMemberNS{Value: union(c.Value, v.Value, normalizeNumber)}, nil
+		}
+	case *types.AttributeValueMemberBS:
+		if !found {
+			return copyValue(v), nil
+		} else if c, ok := current.(*types.AttributeValueMemberBS); ok {
+			return &types.AttributeValueMemberBS{Value: union(c.Value, v.Value, func(b []byte) string { return string(b) })}, nil
+		}
+	}
+	return nil, fmt.Errorf("incorrect operand type for operator or function; operator: ADD")
+}
+
+func deleteFromSet(current types.AttributeValue, value types.AttributeValue) (types.AttributeValue, error) {
+	switch v := value.(type) {
+	case *types.AttributeValueMemberSS:
+		if c, ok := current.(*types.AttributeValueMemberSS); ok {
+			if rest := difference(c.Value, v.Value, func(s string) string { return s }); len(rest) > 0 {
+				return &types.AttributeValueMemberSS{Value: rest}, nil
+			}
+			return nil, nil
+		}
+	case *types.AttributeValueMemberNS:
+		if c, ok := current.(*types.AttributeValueMemberNS); ok {
+			if rest := difference(c.Value, v.Value, normalizeNumber); len(rest) > 0 {
+				return &types.AttributeValueMemberNS{Value: rest}, nil
+			}
+			return nil, nil
+		}
+	case *types.AttributeValueMemberBS:
+		if c, ok := current.(*types.AttributeValueMemberBS); ok {
+			if rest := difference(c.Value, v.Value, func(b []byte) string { return string(b) }); len(rest) > 0 {
+				return &types.AttributeValueMemberBS{Value: rest}, nil
+			}
+			return nil, nil
+		}
+	}
+	return nil, fmt.Errorf("incorrect operand type for operator or function; operator: DELETE")
+}
+
+func union[E any](a, b []E, key func(E) string) []E {
+	seen := make(map[string]bool, len(a))
+	result := make([]E, 0, len(a)+len(b))
+	for _, list := range [][]E{a, b} {
+		for _, e := range list {
+			if k := key(e); !seen[k] {
+				seen[k] = true
+				result = append(result, e)
+			}
+		}
+	}
+	return result
+}
+
+func difference[E any](a, b []E, key func(E) string) []E {
+	removed := make(map[string]bool, len(b))
+	for _, e := range b {
+		removed[key(e)] = true
+	}
+	result := make([]E, 0, len(a))
+	for _, e := range a {
+		if !removed[key(e)] {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+func updatedAttributes(actions []updateAction) []string {
+	result := make([]string, 0, len(actions))
+	for _, action := range actions {
+		result = append(result, action.path[0].name)
+	}
+	return result
+}
+
+func parseUpdateRequest(update *string, conditionExpression *string, names map[string]string, values map[string]types.AttributeValue) ([]updateAction, condition, error) {
+	if err := checkPlaceholders(names, values); err != nil {
+		return nil, nil, err
+	}
+	if update == nil {
+		return nil, nil, validationError("UpdateExpression is required")
+	}
+	ctx := newExprContext(names, values)
+	actions, err := parseUpdate(*update, ctx)
+	if err != nil {
+		return nil, nil, validationError("invalid UpdateExpression: %v", err)
+	}
+	var cond condition
+	if conditionExpression != nil {
+		if cond, err = parseCondition(*conditionExpression, ctx); err != nil {
+			return nil, nil, validationError("invalid ConditionExpression: %v", err)
+		}
+	}
+	if err := ctx.checkUnused(); err != nil {
+		return nil, nil, validationError("%v", err)
+	}
+	return actions, cond, nil
+}
+
+func (t *memoryTable) update(key map[string]types.AttributeValue, actions []updateAction) (map[string]types.AttributeValue, error) {
+	for _, action := range actions {
+		if name := action.path[0].name; name == t.hashKey || name == t.rangeKey {
+			return nil, validationError("cannot update attribute %v; this attribute is part of the key", name)
+		}
+	}
+	base, found := t.items[t.primaryKey(key)]
+	if !found {
+		base = key
+	}
+	updated, err := applyUpdate(base, actions)
+	if err != nil {
+		return nil, validationError("invalid UpdateExpression: %v", err)
+	}
+	if err := t.checkItem(updated); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+func (db *MemoryDynamoDb) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	table, err := db.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+	if err := table.checkKey(params.Key, true); err != nil {
+		return nil, err
+	}
+	actions, cond, err := parseUpdateRequest(params.UpdateExpression, params.ConditionExpression, params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+	if err != nil {
+		return nil, err
+	}
+	key := table.primaryKey(params.Key)
+	old := table.items[key]
+	if cond != nil && !cond.matches(orEmpty(old)) {
+		return nil, conditionFailed(old, params.ReturnValuesOnConditionCheckFailure)
+	}
+	updated, err := table.update(params.Key, actions)
+	if err != nil {
+		return nil, err
+	}
+	table.items[key] = updated
+	output := &dynamodb.UpdateItemOutput{}
+	switch params.ReturnValues {
+	case types.ReturnValueAllOld:
+		output.Attributes = copyItem(old)
+	case types.ReturnValueAllNew:
+		output.Attributes = copyItem(updated)
+	case types.ReturnValueUpdatedOld:
+		output.Attributes = pick(old, updatedAttributes(actions))
+	case types.ReturnValueUpdatedNew:
+		output.Attributes = pick(updated, updatedAttributes(actions))
+	}
+	return output, nil
+}
+
+func pick(item map[string]types.AttributeValue, names []string) map[string]types.AttributeValue {
+	result := make(map[string]types.AttributeValue)
+	for _, name := range names {
+		if value, found := item[name]; found {
+			result[name] = copyValue(value)
+		}
+	}
+	return result
+}
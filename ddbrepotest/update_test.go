@@ -0,0 +1,82 @@
+package ddbrepotest
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/rotmistrk/must"
+	"testing"
+)
+
+func TestMemoryDynamoDb_UpdateItem(t *testing.T) {
+	_, db := newOrderRepo(t)
+	key := map[string]types.AttributeValue{
+		"customer": &types.AttributeValueMemberS{Value: "c"},
+		"number":   &types.AttributeValueMemberN{Value: "1"},
+	}
+	tests := []struct {
+		name    string
+		update  string
+		values  map[string]types.AttributeValue
+		want    string
+		wantErr bool
+	}{
+		{"set creates", "SET state = :s, total = :n", map[string]types.AttributeValue{
+			":s": &types.AttributeValueMemberS{Value: "open"},
+			":n": &types.AttributeValueMemberN{Value: "1.5"},
+		}, `{"customer":"c","number":1,"state":"open","total":1.5}`, false},
+		{"arithmetic", "SET total = total + :n", map[string]types.AttributeValue{
+			":n": &types.AttributeValueMemberN{Value: "2.25"},
+		}, `{"customer":"c","number":1,"state":"open","total":3.75}`, false},
+		{"add and list append", "SET tags = list_append(if_not_exists(tags, :empty), :tags) ADD seen :one", map[string]types.AttributeValue{
+			":empty": &types.AttributeValueMemberL{},
+			":tags":  &types.AttributeValueMemberL{Value: []types.AttributeValue{&types.AttributeValueMemberS{Value: "x"}}},
+			":one":   &types.AttributeValueMemberN{Value: "1"},
+		}, `{"customer":"c","number":1,"seen":1,"state":"open","tags":["x"],"total":3.75}`, false},
+		{"sets", "ADD labels :ab REMOVE tags, seen", map[string]types.AttributeValue{
+			":ab": &types.AttributeValueMemberSS{Value: []string{"a", "b"}},
+		}, `{"customer":"c","labels":["a","b"],"number":1,"state":"open","total":3.75}`, false},
+		{"delete from set", "DELETE labels :a", map[string]types.AttributeValue{
+			":a": &types.AttributeValueMemberSS{Value: []string{"a"}},
+		}, `{"customer":"c","labels":["b"],"number":1,"state":"open","total":3.75}`, false},
+		{"key attribute", "SET customer = :s", map[string]types.AttributeValue{
+			":s": &types.AttributeValueMemberS{Value: "d"},
+		}, "", true},
+		{"overlapping paths", "SET state = :s REMOVE state", map[string]types.AttributeValue{
+			":s": &types.AttributeValueMemberS{Value: "d"},
+		}, "", true},
+		{"wrong operand type", "SET state = state + :n", map[string]types.AttributeValue{
+			":n": &types.AttributeValueMemberN{Value: "1"},
+		}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := db.UpdateItem(context.TODO(), &dynamodb.UpdateItemInput{
+				TableName:                 aws.String("orders"),
+				Key:                       key,
+				UpdateExpression:          aws.String(tt.update),
+				ExpressionAttributeValues: tt.values,
+				ReturnValues:              types.ReturnValueAllNew,
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UpdateItem() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				if got := itemJson(t, output.Attributes); got != tt.want {
+					t.Errorf("UpdateItem() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func itemJson(t *testing.T, item map[string]types.AttributeValue) string {
+	var decoded map[string]any
+	if err := attributevalue.UnmarshalMap(item, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	return string(must.Must(json.Marshal(decoded)))
+}
@@ -0,0 +1,309 @@
+package ddbrepotest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"math/big"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+func parseNumber(value string) (*big.Rat, bool) {
+	return new(big.Rat).SetString(strings.TrimSpace(value))
+}
+
+func compareNumbers(a, b string) (int, bool) {
+	x, xOk := parseNumber(a)
+	y, yOk := parseNumber(b)
+	if !xOk || !yOk {
+		return 0, false
+	}
+	return x.Cmp(y), true
+}
+
+func compareValues(a, b types.AttributeValue) (int, bool) {
+	switch x := a.(type) {
+	case *types.AttributeValueMemberS:
+		if y, ok := b.(*types.AttributeValueMemberS); ok {
+			return strings.Compare(x.Value, y.Value), true
+		}
+	case *types.AttributeValueMemberN:
+		if y, ok := b.(*types.AttributeValueMemberN); ok {
+			return compareNumbers(x.Value, y.Value)
+		}
+	case *types.AttributeValueMemberB:
+		if y, ok := b.(*types.AttributeValueMemberB); ok {
+			return bytes.Compare(x.Value, y.Value), true
+		}
+	}
+	return 0, false
+}
+
+func equalValues(a, b types.AttributeValue) bool {
+	switch x := a.(type) {
+	case *types.AttributeValueMemberS:
+		y, ok := b.(*types.AttributeValueMemberS)
+		return ok && x.Value == y.Value
+	case *types.AttributeValueMemberN:
+		y, ok := b.(*types.AttributeValueMemberN)
+		if !ok {
+			return false
+		}
+		cmp, valid := compareNumbers(x.Value, y.Value)
+		return valid && cmp == 0
+	case *types.AttributeValueMemberB:
+		y, ok := b.(*types.AttributeValueMemberB)
+		return ok && bytes.Equal(x.Value, y.Value)
+	case *types.AttributeValueMemberBOOL:
+		y, ok := b.(*types.AttributeValueMemberBOOL)
+		return ok && x.Value == y.Value
+	case *types.AttributeValueMemberNULL:
+		_, ok := b.(*types.AttributeValueMemberNULL)
+		return ok
+	case *types.AttributeValueMemberSS:
+		y, ok := b.(*types.AttributeValueMemberSS)
+		return ok && equalSets(x.Value, y.Value, func(v string) string { return v })
+	case *types.AttributeValueMemberNS:
+		y, ok := b.(*types.AttributeValueMemberNS)
+		return ok && equalSets(x.Value, y.Value, normalizeNumber)
+	case *types.AttributeValueMemberBS:
+		y, ok := b.(*types.AttributeValueMemberBS)
+		return ok && equalSets(x.Value, y.Value, func(v []byte) string { return string(v) })
+	case *types.AttributeValueMemberL:
+		y, ok := b.(*types.AttributeValueMemberL)
+		if !ok || len(x.Value) != len(y.Value) {
+			return false
+		}
+		for i := range x.Value {
+			if !equalValues(x.Value[i], y.Value[i]) {
+				return false
+			}
+		}
+		return true
+	case *types.AttributeValueMemberM:
+		y, ok := b.(*types.AttributeValueMemberM)
+		if !ok || len(x.Value) != len(y.Value) {
+			return false
+		}
+		for k, v := range x.Value {
+			if w, found := y.Value[k]; !found || !equalValues(v, w) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func equalSets[E any](a, b []E, key func(E) string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	seen := make(map[string]bool, len(a))
+	for _, v := range a {
+		seen[key(v)] = true
+	}
+	for _, v := range b {
+		if !seen[key(v)] {
+			return false
+		}
+	}
+	return true
+}
+
+func normalizeNumber(value string) string {
+	if n, ok := parseNumber(value); ok {
+		return n.RatString()
+	}
+	return value
+}
+
+func keyString(value types.AttributeValue) string {
+	switch v := value.(type) {
+	case *types.AttributeValueMemberS:
+		return "S:" + v.Value
+	case *types.AttributeValueMemberN:
+		return "N:" + normalizeNumber(v.Value)
+	case *types.AttributeValueMemberB:
+		return "B:" + base64.StdEncoding.EncodeToString(v.Value)
+	}
+	return "?"
+}
+
+func typeCode(value types.AttributeValue) string {
+	switch value.(type) {
+	case *types.AttributeValueMemberS:
+		return "S"
+	case *types.AttributeValueMemberN:
+		return "N"
+	case *types.AttributeValueMemberB:
+		return "B"
+	case *types.AttributeValueMemberBOOL:
+		return "BOOL"
+	case *types.AttributeValueMemberNULL:
+		return "NULL"
+	case *types.AttributeValueMemberSS:
+		return "SS"
+	case *types.AttributeValueMemberNS:
+		return "NS"
+	case *types.AttributeValueMemberBS:
+		return "BS"
+	case *types.AttributeValueMemberL:
+		return "L"
+	case *types.AttributeValueMemberM:
+		return "M"
+	}
+	return ""
+}
+
+func sizeOf(value types.AttributeValue) (int, bool) {
+	switch v := value.(type) {
+	case *types.AttributeValueMemberS:
+		return utf8.RuneCountInString(v.Value), true
+	case *types.AttributeValueMemberB:
+		return len(v.Value), true
+	case *types.AttributeValueMemberSS:
+		return len(v.Value), true
+	case *types.AttributeValueMemberNS:
+		return len(v.Value), true
+	case *types.AttributeValueMemberBS:
+		return len(v.Value), true
+	case *types.AttributeValueMemberL:
+		return len(v.Value), true
+	case *types.AttributeValueMemberM:
+		return len(v.Value), true
+	}
+	return 0, false
+}
+
+func beginsWith(value, prefix types.AttributeValue) bool {
+	switch v := value.(type) {
+	case *types.AttributeValueMemberS:
+		p, ok := prefix.(*types.AttributeValueMemberS)
+		return ok && strings.HasPrefix(v.Value, p.Value)
+	case *types.AttributeValueMemberB:
+		p, ok := prefix.(*types.AttributeValueMemberB)
+		return ok && bytes.HasPrefix(v.Value, p.Value)
+	}
+	return false
+}
+
+func contains(value, element types.AttributeValue) bool {
+	switch v := value.(type) {
+	case *types.AttributeValueMemberS:
+		e, ok := element.(*types.AttributeValueMemberS)
+		return ok && strings.Contains(v.Value, e.Value)
+	case *types.AttributeValueMemberB:
+		e, ok := element.(*types.AttributeValueMemberB)
+		return ok && bytes.Contains(v.Value, e.Value)
+	case *types.AttributeValueMemberSS:
+		e, ok := element.(*types.AttributeValueMemberS)
+		return ok && indexOf(v.Value, e.Value, func(a, b string) bool { return a == b }) >= 0
+	case *types.AttributeValueMemberNS:
+		e, ok := element.(*types.AttributeValueMemberN)
+		return ok && indexOf(v.Value, e.Value, func(a, b string) bool { return normalizeNumber(a) == normalizeNumber(b) }) >= 0
+	case *types.AttributeValueMemberBS:
+		e, ok := element.(*types.AttributeValueMemberB)
+		return ok && indexOf(v.Value, e.Value, bytes.Equal) >= 0
+	case *types.AttributeValueMemberL:
+		for _, x := range v.Value {
+			if equalValues(x, element) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func indexOf[E any](list []E, element E, equal func(a, b E) bool) int {
+	for i, v := range list {
+		if equal(v, element) {
+			return i
+		}
+	}
+	return -1
+}
+
+func copyItem(item map[string]types.AttributeValue) map[string]types.AttributeValue {
+	if item == nil {
+		return nil
+	}
+	result := make(map[string]types.AttributeValue, len(item))
+	for k, v := range item {
+		result[k] = copyValue(v)
+	}
+	return result
+}
+
+func copyValue(value types.AttributeValue) types.AttributeValue {
+	switch v := value.(type) {
+	case *types.AttributeValueMemberS:
+		return &types.AttributeValueMemberS{Value: v.Value}
+	case *types.AttributeValueMemberN:
+		return &types.AttributeValueMemberN{Value: v.Value}
+	case *types.AttributeValueMemberB:
+		return &types.AttributeValueMemberB{Value: append([]byte(nil), v.Value...)}
+	case *types.AttributeValueMemberBOOL:
+		return &types.AttributeValueMemberBOOL{Value: v.Value}
+	case *types.AttributeValueMemberNULL:
+		return &types.AttributeValueMemberNULL{Value: v.Value}
+	case *types.AttributeValueMemberSS:
+		return &types.AttributeValueMemberSS{Value: append([]string(nil), v.Value...)}
+	case *types.AttributeValueMemberNS:
+		return &types.AttributeValueMemberNS{Value: append([]string(nil), v.Value...)}
+	case *types.AttributeValueMemberBS:
+		result := make([][]byte, len(v.Value))
+		for i, b := range v.Value {
+			result[i] = append([]byte(nil), b...)
+		}
+		return &types.AttributeValueMemberBS{Value: result}
+	case *types.AttributeValueMemberL:
+		result := make([]types.AttributeValue, len(v.Value))
+		for i, e := range v.Value {
+			result[i] = copyValue(e)
+		}
+		return &types.AttributeValueMemberL{Value: result}
+	case *types.AttributeValueMemberM:
+		return &types.AttributeValueMemberM{Value: copyItem(v.Value)}
+	}
+	return value
+}
+
+func itemSize(item map[string]types.AttributeValue) int64 {
+	var size int64
+	for k, v := range item {
+		size += int64(len(k)) + valueSize(v)
+	}
+	return size
+}
+
+func valueSize(value types.AttributeValue) int64 {
+	switch v := value.(type) {
+	case *types.AttributeValueMemberS:
+		return int64(len(v.Value))
+	case *types.AttributeValueMemberN:
+		return int64(len(v.Value))
+	case *types.AttributeValueMemberB:
+		return int64(len(v.Value))
+	case *types.AttributeValueMemberL:
+		size := int64(3)
+		for _, e := range v.Value {
+			size += 1 + valueSize(e)
+		}
+		return size
+	case *types.AttributeValueMemberM:
+		return 3 + itemSize(v.Value)
+	}
+	return 1
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	result := make([]string, 0, len(m))
+	for k := range m {
+		result = append(result, k)
+	}
+	sort.Strings(result)
+	return result
+}
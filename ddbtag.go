@@ -3,19 +3,34 @@ package ddbrepo
 import (
 	"errors"
 	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"reflect"
 	"strings"
 )
 
 const (
-	TagDdb          = "ddb"
-	TagDdbGsi       = "ddb-gsi"
-	TagItemHashKey  = "hash-key"
-	TagItemRangeKey = "range-key"
-	TagItemRequired = "required"
-	TagItemTtlField = "expire"
-	TagItemIgnore   = "ignore"
-	TagVersion      = "version"
+	TagDdb           = "ddb"
+	TagDdbGsi        = "ddb-gsi"
+	TagDdbLsi        = "ddb-lsi"
+	TagDdbProject    = "ddb-project"
+	TagItemHashKey   = "hash-key"
+	TagItemRangeKey  = "range-key"
+	TagItemRequired  = "required"
+	TagItemTtlField  = "expire"
+	TagItemIgnore    = "ignore"
+	TagItemOmitEmpty = "omitempty"
+	TagItemNull      = "null"
+	TagVersion       = "version"
+	TagTimeEncoding  = "time"
+	TagRuleMin       = "min"
+	TagRuleMax       = "max"
+	TagRuleLen       = "len"
+	TagRulePattern   = "pattern"
+	TagRuleOneOf     = "oneof"
+
+	TagProjectionAll      = "all"
+	TagProjectionKeysOnly = "keys-only"
+	TagProjectionInclude  = "include"
 )
 
 type fieldSpec struct {
@@ -25,7 +40,14 @@ type fieldSpec struct {
 	isRangeKey bool
 	isVersion  bool
 	isTtlField bool
+	omitEmpty  bool
+	null       bool
+	timeFormat TimeEncoding
 	gsiHash    map[string]bool
+	lsiHash    map[string]bool
+	projection map[string]types.ProjectionType
+	projected  []string
+	rules      []fieldRule
 }
 
 type parseProps interface {
@@ -33,73 +55,208 @@ type parseProps interface {
 	LowercaseUntaggedFields() bool
 }
 
+// FieldTags is what the ddb tags of a field declare; GlobalIndexes and
+// LocalIndexes map index names to true for hash keys.
+type FieldTags struct {
+	Name          string
+	Tagged        bool
+	HashKey       bool
+	RangeKey      bool
+	Required      bool
+	Version       bool
+	Ttl           bool
+	OmitEmpty     bool
+	Null          bool
+	TimeEncoding  TimeEncoding
+	GlobalIndexes map[string]bool
+	LocalIndexes  map[string]bool
+	Projections   map[string]types.ProjectionType
+	Projected     []string
+	Rules         []string
+}
+
+// ParseFieldTags parses the tags of the named field; the result is nil for
+// ignored fields and names untagged fields by their Go name.
+func ParseFieldTags(fieldName string, tag reflect.StructTag) (*FieldTags, error) {
+	tagStr, ok := tag.Lookup(TagDdb)
+	result := &FieldTags{Name: fieldName, Tagged: ok}
+	var err error
+	if gsiStr, gsiOk := tag.Lookup(TagDdbGsi); gsiOk {
+		if result.GlobalIndexes, err = result.parseIndexTag(TagDdbGsi, gsiStr, fieldName); err != nil {
+			return nil, err
+		}
+	}
+	if lsiStr, lsiOk := tag.Lookup(TagDdbLsi); lsiOk {
+		if result.LocalIndexes, err = result.parseIndexTag(TagDdbLsi, lsiStr, fieldName); err != nil {
+			return nil, err
+		}
+	}
+	if projectStr, projectOk := tag.Lookup(TagDdbProject); projectOk {
+		for _, v := range strings.Split(projectStr, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				result.Projected = append(result.Projected, v)
+			}
+		}
+	}
+	if !ok {
+		return result, nil
+	}
+	tags := strings.Split(tagStr, ",")
+	if tag0 := strings.TrimSpace(tags[0]); len(tag0) > 0 {
+		result.Name = tag0
+	}
+	for _, v := range tags[1:] {
+		switch directive := strings.TrimSpace(v); directive {
+		case TagItemRequired:
+			result.Required = true
+		case TagItemHashKey:
+			result.HashKey = true
+		case TagItemRangeKey:
+			result.RangeKey = true
+		case TagItemTtlField:
+			result.Ttl = true
+		case TagVersion:
+			result.Version = true
+		case TagItemOmitEmpty:
+			result.OmitEmpty = true
+		case TagItemNull:
+			result.Null = true
+		case TagItemIgnore:
+			return nil, nil
+		default:
+			name, arg, found := strings.Cut(directive, "=")
+			switch {
+			case found && name == TagTimeEncoding:
+				if result.TimeEncoding = TimeEncoding(arg); !result.TimeEncoding.isValid() {
+					return nil, fmt.Errorf("unknown time encoding %v for %v", arg, fieldName)
+				}
+			case found && isRuleName(name):
+				result.Rules = append(result.Rules, directive)
+			default:
+				return nil, errors.New("unknown annotation: [" + v + "]")
+			}
+		}
+	}
+	if result.RangeKey && result.HashKey {
+		return nil, errors.New("both " + TagItemHashKey + " and " + TagItemRangeKey + " are set for " + fieldName)
+	} else if result.Ttl && result.TimeEncoding != "" && !result.TimeEncoding.isNumeric() {
+		return nil, fmt.Errorf("expiration field %v must be stored as a number, not %v", fieldName, result.TimeEncoding)
+	} else if result.OmitEmpty && result.Null {
+		return nil, errors.New("both " + TagItemOmitEmpty + " and " + TagItemNull + " are set for " + fieldName)
+	}
+	return result, nil
+}
+
+func isRuleName(name string) bool {
+	switch name {
+	case TagRuleMin, TagRuleMax, TagRuleLen, TagRulePattern, TagRuleOneOf:
+		return true
+	}
+	return false
+}
+
 func newFieldSpec(props parseProps, field *reflect.StructField) (*fieldSpec, error) {
-	tagStr, ok := field.Tag.Lookup(TagDdb)
-	gsiStr, gsiOk := field.Tag.Lookup(TagDdbGsi)
-	if (ok || gsiOk) && !field.IsExported() {
+	_, ok := field.Tag.Lookup(TagDdb)
+	_, gsiOk := field.Tag.Lookup(TagDdbGsi)
+	_, lsiOk := field.Tag.Lookup(TagDdbLsi)
+	_, projectOk := field.Tag.Lookup(TagDdbProject)
+	if (ok || gsiOk || lsiOk || projectOk) && !field.IsExported() {
 		return nil, errors.New("can't handle ddb tags on private field " + field.Name)
 	}
 	if !field.IsExported() {
 		return nil, nil
 	}
+	tags, err := ParseFieldTags(field.Name, field.Tag)
+	if err != nil || tags == nil {
+		return nil, err
+	} else if !tags.Tagged && !props.AllowUntaggedFields() {
+		return nil, nil
+	}
 	spec := &fieldSpec{
-		name: field.Name,
-	}
-	if gsiOk {
-		gsis := strings.Split(gsiStr, ",")
-		spec.gsiHash = make(map[string]bool)
-		for _, v := range gsis {
-			v = strings.TrimSpace(v)
-			parts := strings.Split(v, " ")
-			if len(parts) != 2 {
-				return nil, fmt.Errorf("malformed annotation element %v for %v of %v", v, TagDdbGsi, field.Name)
-			}
-			if _, found := spec.gsiHash[parts[0]]; found {
-				return nil, fmt.Errorf("duplicate gsi spec for gsi %v of %v", parts[0], field.Name)
-			}
-			switch parts[1] {
-			case TagItemRangeKey:
-				spec.gsiHash[parts[0]] = false
-			case TagItemHashKey:
-				spec.gsiHash[parts[0]] = true
-			default:
-				return nil, fmt.Errorf("unexpected key type in %v on %v for field %v", v, TagDdbGsi, field.Name)
-			}
+		name:       tags.Name,
+		required:   tags.Required,
+		isHashKey:  tags.HashKey,
+		isRangeKey: tags.RangeKey,
+		isVersion:  tags.Version,
+		isTtlField: tags.Ttl,
+		omitEmpty:  tags.OmitEmpty,
+		null:       tags.Null,
+		timeFormat: tags.TimeEncoding,
+		gsiHash:    tags.GlobalIndexes,
+		lsiHash:    tags.LocalIndexes,
+		projection: tags.Projections,
+		projected:  tags.Projected,
+	}
+	for _, directive := range tags.Rules {
+		if rule, err := newFieldRule(field, directive); err != nil {
+			return nil, err
+		} else if rule == nil {
+			return nil, errors.New("unknown annotation: [" + directive + "]")
+		} else {
+			spec.rules = append(spec.rules, *rule)
 		}
 	}
-	if ok {
-		tags := strings.Split(tagStr, ",")
-		if tag0 := strings.TrimSpace(tags[0]); len(tag0) > 0 {
-			spec.name = tag0
+	if !tags.Tagged && props.LowercaseUntaggedFields() && len(spec.name) > 0 {
+		spec.name = mangleName(spec)
+	}
+	return spec, nil
+}
+
+func (t *FieldTags) parseIndexTag(tag string, value string, fieldName string) (map[string]bool, error) {
+	result := make(map[string]bool)
+	for _, v := range strings.Split(value, ",") {
+		v = strings.TrimSpace(v)
+		parts := strings.Split(v, " ")
+		if len(parts) != 2 && len(parts) != 3 {
+			return nil, fmt.Errorf("malformed annotation element %v for %v of %v", v, tag, fieldName)
 		}
-		for _, v := range tags[1:] {
-			switch strings.TrimSpace(v) {
-			case TagItemRequired:
-				spec.required = true
-			case TagItemHashKey:
-				spec.isHashKey = true
-			case TagItemRangeKey:
-				spec.isRangeKey = true
-			case TagItemTtlField:
-				spec.isTtlField = true
-			case TagVersion:
-				spec.isVersion = true
-			case TagItemIgnore:
-				return nil, nil
-			default:
-				return nil, errors.New("unknown annotation: [" + v + "]")
-			}
+		if _, found := result[parts[0]]; found {
+			return nil, fmt.Errorf("duplicate %v spec for index %v of %v", tag, parts[0], fieldName)
 		}
-		if spec.isRangeKey && spec.isHashKey {
-			return nil, errors.New("both " + TagItemHashKey + " and " + TagItemRangeKey + " are set for " + field.Name)
+		switch parts[1] {
+		case TagItemRangeKey:
+			result[parts[0]] = false
+		case TagItemHashKey:
+			result[parts[0]] = true
+		default:
+			return nil, fmt.Errorf("unexpected key type in %v on %v for field %v", v, tag, fieldName)
+		}
+		if len(parts) == 3 {
+			projection, err := projectionType(parts[2])
+			if err != nil {
+				return nil, fmt.Errorf("%w in %v on %v for field %v", err, v, tag, fieldName)
+			}
+			if t.Projections == nil {
+				t.Projections = make(map[string]types.ProjectionType)
+			}
+			t.Projections[parts[0]] = projection
 		}
-	} else if !props.AllowUntaggedFields() {
-		return nil, nil
-	} else if props.LowercaseUntaggedFields() && len(spec.name) > 0 {
-		spec.name = mangleName(spec)
 	}
+	return result, nil
+}
 
-	return spec, nil
+func projectionType(tag string) (types.ProjectionType, error) {
+	switch tag {
+	case TagProjectionAll:
+		return types.ProjectionTypeAll, nil
+	case TagProjectionKeysOnly:
+		return types.ProjectionTypeKeysOnly, nil
+	case TagProjectionInclude:
+		return types.ProjectionTypeInclude, nil
+	}
+	return "", fmt.Errorf("unknown projection %v", tag)
+}
+
+func fieldSpecsOf(props parseProps, sample interface{}) (map[string]*fieldSpec, error) {
+	codec, err := codecOf(props, reflect.TypeOf(sample).Elem())
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]*fieldSpec, len(codec.byName))
+	for name, field := range codec.byName {
+		result[name] = field.spec
+	}
+	return result, nil
 }
 
 func mangleName(spec *fieldSpec) string {
@@ -122,6 +279,10 @@ func (s fieldSpec) IsKey() bool {
 	return s.isHashKey || s.isRangeKey
 }
 
+func (s fieldSpec) isAttributeKey() bool {
+	return s.IsKey() || len(s.gsiHash) > 0 || len(s.lsiHash) > 0
+}
+
 func (s fieldSpec) IsTtlField() bool {
 	return s.isTtlField
 }
@@ -133,3 +294,11 @@ func (s fieldSpec) FieldName() string {
 func (s fieldSpec) IsVersionField() bool {
 	return s.isVersion
 }
+
+func (s fieldSpec) IsOmitEmpty() bool {
+	return s.omitEmpty
+}
+
+func (s fieldSpec) IsNullable() bool {
+	return s.null
+}
@@ -1,6 +1,7 @@
 package ddbrepo
 
 import (
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"reflect"
 	"testing"
 	"time"
@@ -32,6 +33,8 @@ type testStruct struct {
 	BadTag             string    `ddb:",bad-tag"`
 	NamedField         string    `ddb:"RenamedField,hash-key"`
 	GsiMember          string    `ddb-gsi:"gsi1 hash-key, gsi2 range-key"`
+	LsiMember          string    `ddb-lsi:"lsi1 range-key keys-only" ddb-project:"lsi2"`
+	GsiProjected       string    `ddb-gsi:"gsi1 hash-key include, gsi2 range-key all"`
 }
 
 func TestDdbRepo_newFieldSpec(t *testing.T) {
@@ -182,6 +185,33 @@ func TestDdbRepo_newFieldSpec(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "LsiMember has an LSI with a projection",
+			args: args{
+				props: &props{true, true},
+				field: fields["LsiMember"],
+			},
+			want: &fieldSpec{
+				name:       "lsiMember",
+				lsiHash:    map[string]bool{"lsi1": false},
+				projection: map[string]types.ProjectionType{"lsi1": types.ProjectionTypeKeysOnly},
+				projected:  []string{"lsi2"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "GsiProjected has GSIs with projections",
+			args: args{
+				props: &props{true, true},
+				field: fields["GsiProjected"],
+			},
+			want: &fieldSpec{
+				name:       "gsiProjected",
+				gsiHash:    map[string]bool{"gsi1": true, "gsi2": false},
+				projection: map[string]types.ProjectionType{"gsi1": types.ProjectionTypeInclude, "gsi2": types.ProjectionTypeAll},
+			},
+			wantErr: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -197,6 +227,38 @@ func TestDdbRepo_newFieldSpec(t *testing.T) {
 	}
 }
 
+func TestParseFieldTags(t *testing.T) {
+	tests := []struct {
+		name    string
+		tag     reflect.StructTag
+		want    *FieldTags
+		wantErr bool
+	}{
+		{"untagged", ``, &FieldTags{Name: "Field"}, false},
+		{"tagged", `ddb:"field,range-key,required,time=unixmilli,min=1,oneof=a b" ddb-gsi:"byField hash-key keys-only"`, &FieldTags{
+			Name: "field", Tagged: true, RangeKey: true, Required: true, TimeEncoding: TimeUnixMilli,
+			GlobalIndexes: map[string]bool{"byField": true},
+			Projections:   map[string]types.ProjectionType{"byField": types.ProjectionTypeKeysOnly},
+			Rules:         []string{"min=1", "oneof=a b"},
+		}, false},
+		{"ignored", `ddb:"field,ignore"`, nil, false},
+		{"unknown rule", `ddb:"field,max"`, nil, true},
+		{"unknown time encoding", `ddb:"field,time=julian"`, nil, true},
+		{"unknown projection", `ddb-lsi:"byField range-key some"`, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseFieldTags("Field", tt.tag)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseFieldTags() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseFieldTags() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
 func Test_fieldSpec_FieldName(t *testing.T) {
 	type fields struct {
 		name       string
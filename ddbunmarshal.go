@@ -1,34 +1,135 @@
 package ddbrepo
 
 import (
+	"fmt"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"reflect"
 )
 
+// Unmarshal fills the target struct from the item, with its generated code
+// when it has one; with strict props a required attribute missing from the
+// item is a validation error.
 func Unmarshal(props parseProps, target interface{}, item map[string]types.AttributeValue) error {
-	callback := makeUnmarshalCallback(props, target, item)
-	if err := listStructFieldsCbk(target, callback); err != nil {
+	if generated, ok := target.(ItemUnmarshaler); ok && useGenerated(props, target) {
+		var invalid fieldErrors
+		if err := generated.UnmarshalDdbItem(item); err != nil {
+			return err
+		} else if !isStrict(props) {
+			return nil
+		} else if err := checkRequiredAttributes(props, target, item, &invalid); err != nil {
+			return err
+		}
+		return invalid.err()
+	}
+	value, err := getValidMarshallingTargetValue(target)
+	if err != nil {
+		return err
+	}
+	var invalid fieldErrors
+	if err := unmarshalFields(props, "", item, value, &invalid); err != nil {
 		return err
 	} else {
-		return nil
+		return invalid.err()
 	}
 }
 
-func makeUnmarshalCallback(props parseProps, target interface{}, item map[string]types.AttributeValue) func(pos int, field *reflect.StructField, value *reflect.Value) error {
-	return func(pos int, field *reflect.StructField, value *reflect.Value) error {
-		if spec, err := newFieldSpec(props, field); err != nil {
-			return err
-		} else if spec != nil {
-			if mapEntry, found := item[spec.name]; found {
-				// dest := value.Interface()
-				destination := value.Addr().Interface()
-				if err := attributevalue.Unmarshal(mapEntry, destination); err != nil {
-					return err
+func unmarshalFields(props parseProps, path string, item map[string]types.AttributeValue, value reflect.Value, invalid *fieldErrors) error {
+	codec, err := codecOf(props, value.Type())
+	if err != nil {
+		return err
+	}
+	strict := isStrict(props)
+	for i := range codec.fields {
+		field := &codec.fields[i]
+		spec := field.spec
+		fieldPath := path + field.field.Name
+		if strict && spec.IsRequired() && isZeroValue(item[spec.name]) {
+			invalid.add(fieldPath, spec, TagItemRequired, "is missing from the item")
+		}
+		if av, found := item[spec.name]; found {
+			fieldValue, settable := field.value(value, true)
+			if !settable {
+				return fmt.Errorf("%v: can't set a field of a nil embedded pointer", fieldPath)
+			} else if !field.converted && field.decode != nil {
+				if decoded, err := field.decode(av, fieldValue, timeEncodingOf(props, spec)); err != nil {
+					return fmt.Errorf("%v: %w", fieldPath, err)
+				} else if decoded {
+					continue
 				}
 			}
+			if err := unmarshalValue(props, fieldPath, timeEncodingOf(props, spec), av, fieldValue, invalid); err != nil {
+				return err
+			}
 		}
-		return nil
 	}
+	return nil
+}
 
+func unmarshalValue(props parseProps, path string, encoding TimeEncoding, av types.AttributeValue, destination reflect.Value, invalid *fieldErrors) error {
+	if !needsCodec(props, destination.Type()) {
+		return attributevalue.Unmarshal(av, destination.Addr().Interface())
+	}
+	if _, null := av.(*types.AttributeValueMemberNULL); null {
+		destination.SetZero()
+		return nil
+	} else if converter, found := converterFor(props, destination.Type()); found {
+		if err := converter.decode(av, destination); err != nil {
+			return fmt.Errorf("%v: %w", path, err)
+		}
+		return nil
+	} else if destination.Type() == timeType {
+		t, err := decodeTime(av, encoding)
+		if err != nil {
+			return fmt.Errorf("%v: %w", path, err)
+		}
+		destination.Set(reflect.ValueOf(t))
+		return nil
+	}
+	mismatch := fmt.Errorf("%v: can't unmarshal %T into %v", path, av, destination.Type())
+	switch destination.Kind() {
+	case reflect.Pointer:
+		if destination.IsNil() {
+			destination.Set(reflect.New(destination.Type().Elem()))
+		}
+		return unmarshalValue(props, path, encoding, av, destination.Elem(), invalid)
+	case reflect.Struct:
+		if m, ok := av.(*types.AttributeValueMemberM); !ok {
+			return mismatch
+		} else {
+			return unmarshalFields(props, path+".", m.Value, destination, invalid)
+		}
+	case reflect.Slice, reflect.Array:
+		list, ok := av.(*types.AttributeValueMemberL)
+		if !ok {
+			return mismatch
+		}
+		if destination.Kind() == reflect.Slice {
+			destination.Set(reflect.MakeSlice(destination.Type(), len(list.Value), len(list.Value)))
+		}
+		for i := 0; i < len(list.Value) && i < destination.Len(); i++ {
+			if err := unmarshalValue(props, fmt.Sprintf("%v[%v]", path, i), encoding, list.Value[i], destination.Index(i), invalid); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		m, ok := av.(*types.AttributeValueMemberM)
+		if !ok {
+			return mismatch
+		} else if destination.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("%v: map keys must be strings, not %v", path, destination.Type().Key())
+		}
+		result := reflect.MakeMapWithSize(destination.Type(), len(m.Value))
+		for key, value := range m.Value {
+			element := reflect.New(destination.Type().Elem()).Elem()
+			if err := unmarshalValue(props, fmt.Sprintf("%v[%v]", path, key), encoding, value, element, invalid); err != nil {
+				return err
+			}
+			result.SetMapIndex(reflect.ValueOf(key).Convert(destination.Type().Key()), element)
+		}
+		destination.Set(result)
+		return nil
+	}
+	return attributevalue.Unmarshal(av, destination.Addr().Interface())
 }
@@ -0,0 +1,267 @@
+package ddbrepo
+
+import (
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// FieldError is a single field of a record that failed validation; Field is
+// the Go field name, or the path given to the update builder.
+type FieldError struct {
+	Field     string
+	Attribute string
+	Rule      string
+	Message   string
+}
+
+func (e FieldError) Error() string {
+	if e.Field == "" {
+		return e.Message
+	}
+	return e.Field + ": " + e.Message
+}
+
+// ValidationError lists every field of a record that failed validation; it
+// matches ErrValidation with errors.Is.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		parts = append(parts, field.Error())
+	}
+	return ErrValidation.Error() + ": " + strings.Join(parts, "; ")
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+type fieldErrors []FieldError
+
+func (e *fieldErrors) add(field string, spec *fieldSpec, rule string, message string) {
+	*e = append(*e, FieldError{Field: field, Attribute: spec.name, Rule: rule, Message: message})
+}
+
+func (e fieldErrors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return &ValidationError{Fields: e}
+}
+
+type strictProps interface {
+	StrictUnmarshal() bool
+}
+
+func isStrict(props parseProps) bool {
+	strict, ok := props.(strictProps)
+	return ok && strict.StrictUnmarshal()
+}
+
+// WithStrictUnmarshal makes reads fail when a required attribute is missing
+// from the stored item.
+func (repo DdbRepo[T]) WithStrictUnmarshal(strict bool) *DdbRepo[T] {
+	repo.strictUnmarshal = strict
+	return &repo
+}
+
+func (repo *DdbRepo[T]) StrictUnmarshal() bool {
+	return repo.strictUnmarshal
+}
+
+func isZeroValue(value interface{}) bool {
+	if value == nil {
+		return true
+	} else if _, null := value.(*types.AttributeValueMemberNULL); null {
+		return true
+	}
+	return reflect.ValueOf(value).IsZero()
+}
+
+// Validator is implemented by records that check themselves before they are
+// written; a returned ValidationError contributes its fields as they are.
+type Validator interface {
+	Validate() error
+}
+
+type fieldRule struct {
+	name  string
+	check func(value reflect.Value) string
+}
+
+var patternCache sync.Map
+
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if cached, found := patternCache.Load(pattern); found {
+		return cached.(*regexp.Regexp), nil
+	}
+	compiled, err := regexp.Compile(pattern)
+	if err == nil {
+		patternCache.Store(pattern, compiled)
+	}
+	return compiled, err
+}
+
+func isLengthKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return true
+	}
+	return false
+}
+
+func isNumberKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func numberOf(value reflect.Value) float64 {
+	switch {
+	case value.CanInt():
+		return float64(value.Int())
+	case value.CanUint():
+		return float64(value.Uint())
+	default:
+		return value.Float()
+	}
+}
+
+func lengthOf(value reflect.Value) int {
+	if value.Kind() == reflect.String {
+		return utf8.RuneCountInString(value.String())
+	}
+	return value.Len()
+}
+
+func indirectKind(fieldType reflect.Type) reflect.Kind {
+	for fieldType.Kind() == reflect.Pointer {
+		fieldType = fieldType.Elem()
+	}
+	return fieldType.Kind()
+}
+
+func newFieldRule(field *reflect.StructField, directive string) (*fieldRule, error) {
+	name, arg, found := strings.Cut(directive, "=")
+	if !found {
+		return nil, nil
+	}
+	kind := indirectKind(field.Type)
+	switch name {
+	case TagRuleMin, TagRuleMax:
+		limit, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed %v on %v: %w", directive, field.Name, err)
+		} else if !isNumberKind(kind) && !isLengthKind(kind) {
+			return nil, fmt.Errorf("%v is not applicable to %v of type %v", name, field.Name, field.Type)
+		}
+		below, bound := name == TagRuleMin, "at least"
+		if !below {
+			bound = "at most"
+		}
+		return &fieldRule{name: name, check: func(value reflect.Value) string {
+			switch {
+			case isNumberKind(value.Kind()):
+				if n := numberOf(value); (below && n < limit) || (!below && n > limit) {
+					return fmt.Sprintf("must be %v %v", bound, arg)
+				}
+			case isLengthKind(value.Kind()):
+				if n := float64(lengthOf(value)); (below && n < limit) || (!below && n > limit) {
+					return fmt.Sprintf("length must be %v %v", bound, arg)
+				}
+			}
+			return ""
+		}}, nil
+	case TagRuleLen:
+		length, err := strconv.Atoi(arg)
+		if err != nil {
+			return nil, fmt.Errorf("malformed %v on %v: %w", directive, field.Name, err)
+		} else if !isLengthKind(kind) {
+			return nil, fmt.Errorf("%v is not applicable to %v of type %v", name, field.Name, field.Type)
+		}
+		return &fieldRule{name: name, check: func(value reflect.Value) string {
+			if isLengthKind(value.Kind()) && lengthOf(value) != length {
+				return fmt.Sprintf("length must be %v", length)
+			}
+			return ""
+		}}, nil
+	case TagRulePattern:
+		pattern, err := compilePattern(arg)
+		if err != nil {
+			return nil, fmt.Errorf("malformed %v on %v: %w", directive, field.Name, err)
+		} else if kind != reflect.String {
+			return nil, fmt.Errorf("%v is not applicable to %v of type %v", name, field.Name, field.Type)
+		}
+		return &fieldRule{name: name, check: func(value reflect.Value) string {
+			if value.Kind() == reflect.String && !pattern.MatchString(value.String()) {
+				return "must match " + arg
+			}
+			return ""
+		}}, nil
+	case TagRuleOneOf:
+		options := strings.Fields(arg)
+		if len(options) == 0 {
+			return nil, fmt.Errorf("%v on %v has no options", name, field.Name)
+		} else if kind != reflect.String && (!isNumberKind(kind) || kind == reflect.Float32 || kind == reflect.Float64) {
+			return nil, fmt.Errorf("%v is not applicable to %v of type %v", name, field.Name, field.Type)
+		}
+		return &fieldRule{name: name, check: func(value reflect.Value) string {
+			if !slices.Contains(options, fmt.Sprint(value.Interface())) {
+				return "must be one of " + strings.Join(options, ", ")
+			}
+			return ""
+		}}, nil
+	}
+	return nil, nil
+}
+
+func (s *fieldSpec) validateValue(field string, value reflect.Value, invalid *fieldErrors) {
+	for value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return
+		}
+		value = value.Elem()
+	}
+	if !value.IsValid() || value.IsZero() {
+		return
+	}
+	for _, rule := range s.rules {
+		if message := rule.check(value); message != "" {
+			invalid.add(field, s, rule.name, message)
+		}
+	}
+}
+
+func validateRecord(source interface{}, invalid *fieldErrors) {
+	validator, ok := source.(Validator)
+	if !ok {
+		return
+	}
+	err := validator.Validate()
+	var validation *ValidationError
+	var field FieldError
+	switch {
+	case err == nil:
+	case errors.As(err, &validation):
+		*invalid = append(*invalid, validation.Fields...)
+	case errors.As(err, &field):
+		*invalid = append(*invalid, field)
+	default:
+		*invalid = append(*invalid, FieldError{Rule: "validate", Message: err.Error()})
+	}
+}
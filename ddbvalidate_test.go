@@ -0,0 +1,255 @@
+package ddbrepo
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/rotmistrk/ddbrepo/ddbrepotest"
+	"github.com/rotmistrk/must"
+	"reflect"
+	"testing"
+)
+
+type memberRecord struct {
+	ID    string `ddb:"id,hash-key,required"`
+	Email string `ddb:"email,required"`
+	Name  string `ddb:"name,required"`
+	Note  string `ddb:"note"`
+}
+
+func validationFields(err error) []string {
+	var invalid *ValidationError
+	if !errors.As(err, &invalid) {
+		return nil
+	}
+	result := make([]string, 0, len(invalid.Fields))
+	for _, field := range invalid.Fields {
+		result = append(result, field.Field)
+	}
+	return result
+}
+
+func TestRequired_Write(t *testing.T) {
+	db := ddbrepotest.NewMemoryDynamoDb()
+	repo := must.Must(New[memberRecord]()).WithTableName("members").WithDynamoDbApi(db)
+	if err := repo.TableCreate(); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		call       func() error
+		wantFields []string
+	}{
+		{"marshal", func() error {
+			_, err := Marshal(repo, &memberRecord{ID: "m1"})
+			return err
+		}, []string{"Email", "Name"}},
+		{"put", func() error { return repo.PutItem(&memberRecord{Note: "n"}) }, []string{"ID", "Email", "Name"}},
+		{"put complete", func() error { return repo.PutItem(&memberRecord{ID: "m1", Email: "e", Name: "n"}) }, nil},
+		{"update set empty", func() error {
+			return repo.UpdateItem(&memberRecord{ID: "m1"}, repo.NewUpdate().Set("Email", "").Set("name", nil).Set("Note", ""))
+		}, []string{"Email", "name"}},
+		{"update remove", func() error {
+			return repo.UpdateItem(&memberRecord{ID: "m1"}, repo.NewUpdate().Remove("Name").Remove("Note"))
+		}, []string{"Name"}},
+		{"update set value", func() error {
+			return repo.UpdateItem(&memberRecord{ID: "m1"}, repo.NewUpdate().Set("Email", "f").Remove("Note"))
+		}, nil},
+		{"transact put", func() error {
+			return NewWriteTransaction(repo.TransactPut(&memberRecord{ID: "m2"}, Insert)).Execute()
+		}, []string{"Email", "Name"}},
+		{"transact check by key", func() error {
+			return NewWriteTransaction(repo.TransactConditionCheck(&memberRecord{ID: "m1"}, Update)).Execute()
+		}, nil},
+		{"transact update by key", func() error {
+			return NewWriteTransaction(repo.TransactUpdate(&memberRecord{ID: "m1"}, "SET note = :note", nil,
+				map[string]types.AttributeValue{":note": &types.AttributeValueMemberS{Value: "n"}}, Update)).Execute()
+		}, nil},
+		{"transact delete by key", func() error {
+			return NewWriteTransaction(repo.TransactDelete(&memberRecord{ID: "m0"}, Replace)).Execute()
+		}, nil},
+		{"transact delete without key", func() error {
+			return NewWriteTransaction(repo.TransactDelete(&memberRecord{Email: "e"}, Replace)).Execute()
+		}, []string{"ID"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+			if tt.wantFields == nil {
+				if err != nil {
+					t.Errorf("error = %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrValidation) {
+				t.Fatalf("error = %v, want %v", err, ErrValidation)
+			}
+			if got := validationFields(err); !reflect.DeepEqual(got, tt.wantFields) {
+				t.Errorf("invalid fields = %v, want %v", got, tt.wantFields)
+			}
+		})
+	}
+	if len(db.TableItems("members")) != 1 {
+		t.Errorf("stored %v items, want only the complete one", len(db.TableItems("members")))
+	}
+}
+
+func TestRequired_StrictUnmarshal(t *testing.T) {
+	db := ddbrepotest.NewMemoryDynamoDb()
+	repo := must.Must(New[memberRecord]()).WithTableName("members").WithDynamoDbApi(db)
+	if err := repo.TableCreate(); err != nil {
+		t.Fatal(err)
+	}
+	_, err := db.PutItem(context.TODO(), &dynamodb.PutItemInput{
+		TableName: aws.String("members"),
+		Item: map[string]types.AttributeValue{
+			"id":    &types.AttributeValueMemberS{Value: "m1"},
+			"email": &types.AttributeValueMemberNULL{Value: true},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.GetItem(&memberRecord{ID: "m1"}); err != nil {
+		t.Errorf("GetItem() error = %v, want lenient read", err)
+	}
+	strict := repo.WithStrictUnmarshal(true)
+	err = strict.GetItem(&memberRecord{ID: "m1"})
+	if got := validationFields(err); !errors.Is(err, ErrValidation) || !reflect.DeepEqual(got, []string{"Email", "Name"}) {
+		t.Errorf("strict GetItem() error = %v, invalid fields %v", err, got)
+	}
+	record := &memberRecord{ID: "m1"}
+	update := strict.NewUpdate().Set("Note", "n").Return(types.ReturnValueUpdatedNew)
+	if err := strict.UpdateItem(record, update); err != nil || record.Note != "n" {
+		t.Errorf("strict UpdateItem() = %+v, %v, want partial attributes accepted", record, err)
+	}
+}
+
+type listingRecord struct {
+	ID     string   `ddb:"id,hash-key"`
+	Title  string   `ddb:"title,min=3,max=10"`
+	Code   string   `ddb:"code,len=4,pattern=^[A-Z]+[0-9]*$"`
+	Status string   `ddb:"status,oneof=draft live"`
+	Price  *float64 `ddb:"price,min=0.5,max=100"`
+	Rank   int      `ddb:"rank,oneof=1 2 3"`
+	Tags   []string `ddb:"tags,max=2"`
+	Sale   bool     `ddb:"sale"`
+}
+
+func (r listingRecord) Validate() error {
+	if r.Sale && r.Price == nil {
+		return FieldError{Field: "Price", Attribute: "price", Rule: "sale", Message: "is required on sale"}
+	}
+	return nil
+}
+
+func TestRules(t *testing.T) {
+	db := ddbrepotest.NewMemoryDynamoDb()
+	repo := must.Must(New[listingRecord]()).WithTableName("listings").WithDynamoDbApi(db)
+	if err := repo.TableCreate(); err != nil {
+		t.Fatal(err)
+	}
+	cheap, dear := 0.1, 20.0
+	tests := []struct {
+		name       string
+		record     listingRecord
+		wantFields []string
+		wantRules  []string
+	}{
+		{
+			name:   "valid",
+			record: listingRecord{ID: "l1", Title: "lamp", Code: "AB12", Status: "live", Price: &dear, Rank: 2, Tags: []string{"a"}},
+		},
+		{
+			name:   "zero values are not checked",
+			record: listingRecord{ID: "l2"},
+		},
+		{
+			name:       "every violation",
+			record:     listingRecord{ID: "l3", Title: "ab", Code: "ab12", Status: "gone", Price: &cheap, Rank: 4, Tags: []string{"a", "b", "c"}},
+			wantFields: []string{"Title", "Code", "Status", "Price", "Rank", "Tags"},
+			wantRules:  []string{"min", "pattern", "oneof", "min", "oneof", "max"},
+		},
+		{
+			name:       "length in runes and hook",
+			record:     listingRecord{ID: "l4", Title: "ламповый абажур", Code: "ABCDE", Sale: true},
+			wantFields: []string{"Title", "Code", "Price"},
+			wantRules:  []string{"max", "len", "sale"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := repo.PutItem(&tt.record)
+			if tt.wantFields == nil {
+				if err != nil {
+					t.Errorf("PutItem() error = %v", err)
+				}
+				return
+			}
+			var invalid *ValidationError
+			if !errors.As(err, &invalid) {
+				t.Fatalf("PutItem() error = %v, want %T", err, invalid)
+			}
+			rules := make([]string, 0, len(invalid.Fields))
+			for _, field := range invalid.Fields {
+				rules = append(rules, field.Rule)
+			}
+			if got := validationFields(err); !reflect.DeepEqual(got, tt.wantFields) || !reflect.DeepEqual(rules, tt.wantRules) {
+				t.Errorf("PutItem() invalid fields = %v %v, want %v %v", got, rules, tt.wantFields, tt.wantRules)
+			}
+		})
+	}
+	update := repo.NewUpdate().Set("title", "x").Set("Status", "live").Set("Rank", 7)
+	if got := validationFields(repo.UpdateItem(&listingRecord{ID: "l1"}, update)); !reflect.DeepEqual(got, []string{"title", "Rank"}) {
+		t.Errorf("UpdateItem() invalid fields = %v", got)
+	}
+}
+
+func TestRules_Malformed(t *testing.T) {
+	type badPattern struct {
+		ID string `ddb:"id,hash-key,pattern=[a-"`
+	}
+	type badKind struct {
+		ID   string `ddb:"id,hash-key"`
+		Flag bool   `ddb:"flag,min=1"`
+	}
+	type badNumber struct {
+		ID string `ddb:"id,hash-key,len=x"`
+	}
+	if _, err := New[badPattern](); err == nil {
+		t.Errorf("New() accepted a malformed pattern")
+	}
+	if _, err := New[badKind](); err == nil {
+		t.Errorf("New() accepted min on a bool")
+	}
+	if _, err := New[badNumber](); err == nil {
+		t.Errorf("New() accepted a malformed length")
+	}
+}
+
+type variantValue struct {
+	Sku string `ddb:"sku,len=4"`
+}
+
+type catalogRecord struct {
+	ID       string         `ddb:"id,hash-key"`
+	Main     variantValue   `ddb:"main"`
+	Variants []variantValue `ddb:"variants"`
+}
+
+func TestRules_NestedUpdateValues(t *testing.T) {
+	repo := must.Must(New[catalogRecord]())
+	update := repo.NewUpdate().Set("main", variantValue{Sku: "x"}).ListAppend("variants", []variantValue{{Sku: "AB12"}, {Sku: "toolong"}})
+	_, _, err := update.Expression()
+	if !errors.Is(err, ErrValidation) {
+		t.Fatalf("Expression() error = %v, want %v", err, ErrValidation)
+	}
+	if got := validationFields(err); !reflect.DeepEqual(got, []string{"main.Sku", "variants[1].Sku"}) {
+		t.Errorf("Expression() invalid fields = %v", got)
+	}
+	if _, _, err := repo.NewUpdate().Set("main", variantValue{Sku: "AB12"}).Expression(); err != nil {
+		t.Errorf("Expression() of a valid value error = %v", err)
+	}
+}
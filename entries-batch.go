@@ -0,0 +1,273 @@
+package ddbrepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	MaxBatchGetItems   = 100
+	MaxBatchWriteItems = 25
+	maxBatchDelay      = 10 * time.Second
+)
+
+var ErrUnprocessed = errors.New("item left unprocessed")
+
+type BatchFailure struct {
+	Index int
+	Key   map[string]types.AttributeValue
+	Err   error
+}
+
+type BatchError struct {
+	Operation string
+	Table     string
+	Failures  []BatchFailure
+}
+
+func (e *BatchError) Error() string {
+	causes := make([]string, 0, 4)
+	for _, f := range e.Failures {
+		if len(causes) == 3 {
+			causes = append(causes, "...")
+			break
+		}
+		causes = append(causes, fmt.Sprintf("#%v: %v", f.Index, f.Err))
+	}
+	return fmt.Sprintf("%v on %v failed for %v item(s): %v", e.Operation, e.Table, len(e.Failures), strings.Join(causes, "; "))
+}
+
+func (e *BatchError) Unwrap() []error {
+	result := make([]error, 0, len(e.Failures))
+	for _, f := range e.Failures {
+		result = append(result, f.Err)
+	}
+	return result
+}
+
+type batchFailures []BatchFailure
+
+func (f *batchFailures) add(index int, key map[string]types.AttributeValue, err error) {
+	*f = append(*f, BatchFailure{Index: index, Key: key, Err: err})
+}
+
+func (f batchFailures) result(operation, table string) error {
+	if len(f) == 0 {
+		return nil
+	}
+	sort.SliceStable(f, func(i, j int) bool {
+		return f[i].Index < f[j].Index
+	})
+	return &BatchError{Operation: operation, Table: table, Failures: f}
+}
+
+func (repo DdbRepo[T]) WithBatchRetry(maxAttempts int, baseDelay time.Duration) *DdbRepo[T] {
+	repo.batchMaxAttempts = maxAttempts
+	repo.batchBaseDelay = baseDelay
+	return &repo
+}
+
+func (repo *DdbRepo[T]) batchDelay(attempt int) time.Duration {
+	delay := repo.batchBaseDelay << attempt
+	if delay <= 0 || delay > maxBatchDelay {
+		delay = maxBatchDelay
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+func (repo *DdbRepo[T]) retryBatch(ctx context.Context, submit func() (remaining int, err error)) error {
+	for attempt := 0; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if remaining, err := submit(); err != nil || remaining == 0 {
+			return err
+		} else if attempt+1 >= repo.batchMaxAttempts {
+			return fmt.Errorf("%w after %v attempts", ErrUnprocessed, attempt+1)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(repo.batchDelay(attempt)):
+		}
+	}
+}
+
+func (repo DdbRepo[T]) BatchGet(records []*T) error {
+	return repo.BatchGetCtx(context.TODO(), records)
+}
+
+func (repo DdbRepo[T]) BatchGetCtx(ctx context.Context, records []*T) error {
+	if err := repo.validateConfig(); err != nil {
+		return err
+	}
+	failures := make(batchFailures, 0)
+	pending := make(map[string][]int)
+	keys := make([]map[string]types.AttributeValue, 0, len(records))
+	for i, record := range records {
+		if record == nil {
+			failures.add(i, nil, errors.New("record pointer is required"))
+		} else if key, err := MarshalKey(&repo, record, ""); err != nil {
+			failures.add(i, nil, err)
+		} else {
+			keyString := itemKeyString(repo.keySchema, key)
+			if _, found := pending[keyString]; !found {
+				keys = append(keys, key)
+			}
+			pending[keyString] = append(pending[keyString], i)
+		}
+	}
+	var fatal error
+	for start := 0; start < len(keys); start += MaxBatchGetItems {
+		chunk := keys[start:min(start+MaxBatchGetItems, len(keys))]
+		err := fatal
+		if err == nil {
+			request := chunk
+			err = repo.retryBatch(ctx, func() (int, error) {
+				input := &dynamodb.BatchGetItemInput{
+					RequestItems: map[string]types.KeysAndAttributes{
+						repo.tableName: {Keys: request},
+					},
+				}
+				output, err := repo.ddbClient.BatchGetItem(ctx, input)
+				if err != nil {
+					return len(request), err
+				}
+				for _, item := range output.Responses[repo.tableName] {
+					keyString := itemKeyString(repo.keySchema, item)
+					for _, i := range pending[keyString] {
+						if err := Unmarshal(&repo, records[i], item); err != nil {
+							failures.add(i, keyOfItem(repo.keySchema, item), err)
+						}
+					}
+					delete(pending, keyString)
+				}
+				request = output.UnprocessedKeys[repo.tableName].Keys
+				return len(request), nil
+			})
+			if err != nil && !errors.Is(err, ErrUnprocessed) {
+				fatal = err
+			}
+		}
+		for _, key := range chunk {
+			keyString := itemKeyString(repo.keySchema, key)
+			for _, i := range pending[keyString] {
+				if err != nil {
+					failures.add(i, key, err)
+				} else {
+					failures.add(i, key, notFoundError("BatchGet", repo.tableName, key))
+				}
+			}
+			delete(pending, keyString)
+		}
+	}
+	return failures.result("BatchGet", repo.tableName)
+}
+
+func (repo DdbRepo[T]) BatchPut(records []*T) error {
+	return repo.BatchPutCtx(context.TODO(), records)
+}
+
+func (repo DdbRepo[T]) BatchPutCtx(ctx context.Context, records []*T) error {
+	return repo.batchWrite(ctx, "BatchPut", records, func(record *T) (map[string]types.AttributeValue, types.WriteRequest, error) {
+		item, err := Marshal(&repo, record)
+		if err != nil {
+			return nil, types.WriteRequest{}, err
+		}
+		key, err := MarshalKey(&repo, record, "")
+		return key, types.WriteRequest{PutRequest: &types.PutRequest{Item: item}}, err
+	})
+}
+
+func (repo DdbRepo[T]) BatchDelete(records []*T) error {
+	return repo.BatchDeleteCtx(context.TODO(), records)
+}
+
+func (repo DdbRepo[T]) BatchDeleteCtx(ctx context.Context, records []*T) error {
+	return repo.batchWrite(ctx, "BatchDelete", records, func(record *T) (map[string]types.AttributeValue, types.WriteRequest, error) {
+		key, err := MarshalKey(&repo, record, "")
+		return key, types.WriteRequest{DeleteRequest: &types.DeleteRequest{Key: key}}, err
+	})
+}
+
+type batchWriteEntry struct {
+	index   int
+	key     map[string]types.AttributeValue
+	request types.WriteRequest
+}
+
+func (repo DdbRepo[T]) batchWrite(ctx context.Context, operation string, records []*T, build func(record *T) (map[string]types.AttributeValue, types.WriteRequest, error)) error {
+	if err := repo.validateConfig(); err != nil {
+		return err
+	}
+	failures := make(batchFailures, 0)
+	entries := make([]batchWriteEntry, 0, len(records))
+	seen := make(map[string]bool)
+	for i, record := range records {
+		if record == nil {
+			failures.add(i, nil, errors.New("record pointer is required"))
+		} else if key, request, err := build(record); err != nil {
+			failures.add(i, key, err)
+		} else if keyString := itemKeyString(repo.keySchema, key); seen[keyString] {
+			failures.add(i, key, errors.New("duplicate key in batch"))
+		} else {
+			seen[keyString] = true
+			entries = append(entries, batchWriteEntry{index: i, key: key, request: request})
+		}
+	}
+	var fatal error
+	for start := 0; start < len(entries); start += MaxBatchWriteItems {
+		chunk := entries[start:min(start+MaxBatchWriteItems, len(entries))]
+		pending := make(map[string]batchWriteEntry, len(chunk))
+		requests := make([]types.WriteRequest, 0, len(chunk))
+		for _, entry := range chunk {
+			pending[itemKeyString(repo.keySchema, entry.key)] = entry
+			requests = append(requests, entry.request)
+		}
+		err := fatal
+		if err == nil {
+			err = repo.retryBatch(ctx, func() (int, error) {
+				input := &dynamodb.BatchWriteItemInput{
+					RequestItems: map[string][]types.WriteRequest{
+						repo.tableName: requests,
+					},
+				}
+				output, err := repo.ddbClient.BatchWriteItem(ctx, input)
+				if err != nil {
+					return len(requests), err
+				}
+				requests = output.UnprocessedItems[repo.tableName]
+				unprocessed := make(map[string]batchWriteEntry, len(requests))
+				for _, request := range requests {
+					var keyString string
+					if request.PutRequest != nil {
+						keyString = itemKeyString(repo.keySchema, request.PutRequest.Item)
+					} else if request.DeleteRequest != nil {
+						keyString = itemKeyString(repo.keySchema, request.DeleteRequest.Key)
+					}
+					if entry, found := pending[keyString]; found {
+						unprocessed[keyString] = entry
+					}
+				}
+				pending = unprocessed
+				return len(requests), nil
+			})
+			if err != nil && !errors.Is(err, ErrUnprocessed) {
+				fatal = err
+			}
+		}
+		if err != nil {
+			for _, entry := range pending {
+				failures.add(entry.index, entry.key, err)
+			}
+		}
+	}
+	return failures.result(operation, repo.tableName)
+}
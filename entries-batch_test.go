@@ -0,0 +1,135 @@
+package ddbrepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/rotmistrk/ddbrepo/ddbrepotest"
+	"testing"
+	"time"
+)
+
+type unreliableBatchApi struct {
+	*ddbrepotest.MemoryDynamoDb
+	unreliableCalls int
+	calls           int
+}
+
+func (api *unreliableBatchApi) BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	api.calls++
+	if api.calls > api.unreliableCalls {
+		return api.MemoryDynamoDb.BatchWriteItem(ctx, params, optFns...)
+	}
+	processed := make(map[string][]types.WriteRequest)
+	unprocessed := make(map[string][]types.WriteRequest)
+	for table, requests := range params.RequestItems {
+		for i, request := range requests {
+			if i%2 == 0 {
+				processed[table] = append(processed[table], request)
+			} else {
+				unprocessed[table] = append(unprocessed[table], request)
+			}
+		}
+	}
+	if _, err := api.MemoryDynamoDb.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{RequestItems: processed}); err != nil {
+		return nil, err
+	}
+	return &dynamodb.BatchWriteItemOutput{UnprocessedItems: unprocessed}, nil
+}
+
+func (api *unreliableBatchApi) BatchGetItem(ctx context.Context, params *dynamodb.BatchGetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error) {
+	api.calls++
+	if api.calls > api.unreliableCalls {
+		return api.MemoryDynamoDb.BatchGetItem(ctx, params, optFns...)
+	}
+	processed := make(map[string]types.KeysAndAttributes)
+	unprocessed := make(map[string]types.KeysAndAttributes)
+	for table, request := range params.RequestItems {
+		half := len(request.Keys) / 2
+		processed[table] = types.KeysAndAttributes{Keys: request.Keys[:half]}
+		unprocessed[table] = types.KeysAndAttributes{Keys: request.Keys[half:]}
+	}
+	output, err := api.MemoryDynamoDb.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{RequestItems: processed})
+	if err != nil {
+		return nil, err
+	}
+	output.UnprocessedKeys = unprocessed
+	return output, nil
+}
+
+func TestDdbRepo_BatchPut(t *testing.T) {
+	tests := []struct {
+		name            string
+		records         int
+		unreliableCalls int
+		maxAttempts     int
+		wantStored      int
+		wantFailures    int
+	}{
+		{"single chunk", 10, 0, 3, 10, 0},
+		{"several chunks", 60, 0, 3, 60, 0},
+		{"retried unprocessed", 30, 2, 4, 30, 0},
+		{"gives up on unprocessed", 10, 10, 2, 8, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &unreliableBatchApi{MemoryDynamoDb: ddbrepotest.NewMemoryDynamoDb(), unreliableCalls: tt.unreliableCalls}
+			repo := newTestRepo[sampleRecord](t, api, "batched").WithBatchRetry(tt.maxAttempts, time.Millisecond)
+			records := make([]*sampleRecord, 0, tt.records)
+			for i := 0; i < tt.records; i++ {
+				records = append(records, &sampleRecord{ID: fmt.Sprint("id-", i), Part: PartType(i), Name: "batch"})
+			}
+			err := repo.BatchPut(records)
+			var batchErr *BatchError
+			if tt.wantFailures == 0 && err != nil {
+				t.Errorf("BatchPut() error = %v", err)
+			} else if tt.wantFailures > 0 {
+				if !errors.As(err, &batchErr) || len(batchErr.Failures) != tt.wantFailures || !errors.Is(err, ErrUnprocessed) {
+					t.Errorf("BatchPut() error = %v, want %v unprocessed failures", err, tt.wantFailures)
+				}
+			}
+			if stored := len(api.TableItems("batched")); stored != tt.wantStored {
+				t.Errorf("BatchPut() stored %v items, want %v", stored, tt.wantStored)
+			}
+		})
+	}
+}
+
+func TestDdbRepo_BatchGetAndDelete(t *testing.T) {
+	api := &unreliableBatchApi{MemoryDynamoDb: ddbrepotest.NewMemoryDynamoDb()}
+	repo := newTestRepo[sampleRecord](t, api, "batched").WithBatchRetry(4, time.Millisecond)
+	records := make([]*sampleRecord, 0, 150)
+	for i := 0; i < 150; i++ {
+		records = append(records, &sampleRecord{ID: fmt.Sprint("id-", i), Part: PartType(i), Name: fmt.Sprint("name-", i)})
+	}
+	if err := repo.BatchPut(records); err != nil {
+		t.Fatalf("BatchPut() error = %v", err)
+	}
+	api.calls, api.unreliableCalls = 0, 2
+	keys := make([]*sampleRecord, 0, 152)
+	for i := 0; i < 150; i++ {
+		keys = append(keys, &sampleRecord{ID: fmt.Sprint("id-", i), Part: PartType(i)})
+	}
+	keys = append(keys, &sampleRecord{ID: "missing"}, &sampleRecord{ID: "id-3", Part: 3})
+	err := repo.BatchGet(keys)
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || len(batchErr.Failures) != 1 || batchErr.Failures[0].Index != 150 {
+		t.Errorf("BatchGet() error = %v, want a single failure for the missing item", err)
+	}
+	for i := 0; i < 150; i++ {
+		if *keys[i] != *records[i] {
+			t.Errorf("BatchGet() loaded %v, want %v", keys[i], records[i])
+		}
+	}
+	if *keys[151] != *records[3] {
+		t.Errorf("BatchGet() loaded duplicate key as %v, want %v", keys[151], records[3])
+	}
+	if err := repo.BatchDelete(records[:100]); err != nil {
+		t.Errorf("BatchDelete() error = %v", err)
+	}
+	if stored := len(api.TableItems("batched")); stored != 50 {
+		t.Errorf("BatchDelete() left %v items, want 50", stored)
+	}
+}
@@ -0,0 +1,140 @@
+package ddbrepo
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"iter"
+)
+
+// All iterates over the records of a scan, fetching pages lazily; no more
+// requests are made once the loop is left. An error ends the iteration.
+func (repo *DdbRepo[RecordType]) All(ctx context.Context, options ...ScanOption) iter.Seq2[*RecordType, error] {
+	return func(yield func(*RecordType, error) bool) {
+		input, err := repo.buildScan(options)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		for {
+			if err := ctx.Err(); err != nil {
+				yield(nil, err)
+				return
+			}
+			output, err := repo.ddbClient.Scan(ctx, input)
+			if err != nil {
+				yield(nil, opError("Scan", repo.tableName, nil, err))
+				return
+			}
+			for _, item := range output.Items {
+				record := new(RecordType)
+				if err := Unmarshal(repo, record, item); err != nil {
+					yield(nil, err)
+					return
+				} else if !yield(record, nil) {
+					return
+				}
+			}
+			if output.LastEvaluatedKey == nil {
+				return
+			}
+			input.ExclusiveStartKey = output.LastEvaluatedKey
+		}
+	}
+}
+
+// QueryIter iterates over the records of a query the same way All does for
+// a scan; Limit bounds the number of records.
+func (repo *DdbRepo[RecordType]) QueryIter(ctx context.Context, source *RecordType, options ...QueryArg) iter.Seq2[*RecordType, error] {
+	return func(yield func(*RecordType, error) bool) {
+		input, limit, err := buildQuery(repo, source, options)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		for item, err := range queryItems(ctx, repo, input, limit) {
+			record := new(RecordType)
+			if err == nil {
+				err = Unmarshal(repo, record, item)
+			}
+			if err != nil {
+				yield(nil, err)
+				return
+			} else if !yield(record, nil) {
+				return
+			}
+		}
+	}
+}
+
+func queryItems[T any](ctx context.Context, repo *DdbRepo[T], input *dynamodb.QueryInput, limit int32) iter.Seq2[map[string]types.AttributeValue, error] {
+	return func(yield func(map[string]types.AttributeValue, error) bool) {
+		delivered := int32(0)
+		for {
+			if err := ctx.Err(); err != nil {
+				yield(nil, err)
+				return
+			}
+			output, err := repo.ddbClient.Query(ctx, input)
+			if err != nil {
+				yield(nil, opError("Query", repo.tableName, queryKey(input), err))
+				return
+			}
+			for _, item := range output.Items {
+				if !yield(item, nil) {
+					return
+				}
+				if delivered++; limit > 0 && delivered >= limit {
+					return
+				}
+			}
+			if output.LastEvaluatedKey == nil {
+				return
+			}
+			input.ExclusiveStartKey = output.LastEvaluatedKey
+		}
+	}
+}
+
+// Take stops the iteration after n records; errors are passed through.
+func Take[V any](seq iter.Seq2[V, error], n int) iter.Seq2[V, error] {
+	return func(yield func(V, error) bool) {
+		if n <= 0 {
+			return
+		}
+		taken := 0
+		for v, err := range seq {
+			if !yield(v, err) || err != nil {
+				return
+			}
+			if taken++; taken >= n {
+				return
+			}
+		}
+	}
+}
+
+// Filter passes through the records accepted by keep and all errors.
+func Filter[V any](seq iter.Seq2[V, error], keep func(V) bool) iter.Seq2[V, error] {
+	return func(yield func(V, error) bool) {
+		for v, err := range seq {
+			if err != nil || keep(v) {
+				if !yield(v, err) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Collect gathers the records up to the first error.
+func Collect[V any](seq iter.Seq2[V, error]) ([]V, error) {
+	result := make([]V, 0)
+	for v, err := range seq {
+		if err != nil {
+			return result, err
+		}
+		result = append(result, v)
+	}
+	return result, nil
+}
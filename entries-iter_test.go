@@ -0,0 +1,83 @@
+package ddbrepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/rotmistrk/ddbrepo/ddbrepotest"
+	"testing"
+)
+
+type countingApi struct {
+	*ddbrepotest.MemoryDynamoDb
+	requests int
+}
+
+func (api *countingApi) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	api.requests++
+	return api.MemoryDynamoDb.Scan(ctx, params, optFns...)
+}
+
+func (api *countingApi) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	api.requests++
+	return api.MemoryDynamoDb.Query(ctx, params, optFns...)
+}
+
+func iterRecords() []*sampleRecord {
+	result := make([]*sampleRecord, 0, 20)
+	for i := 0; i < 20; i++ {
+		result = append(result, &sampleRecord{ID: "id", Part: PartType(i), Name: fmt.Sprint(i % 2)})
+	}
+	return result
+}
+
+func TestDdbRepo_All(t *testing.T) {
+	api := &countingApi{MemoryDynamoDb: ddbrepotest.NewMemoryDynamoDb()}
+	repo := newTestRepo(t, api, "iterated", iterRecords()...)
+	api.requests = 0
+	count := 0
+	for record, err := range repo.All(context.TODO(), ScanLimit(5)) {
+		if err != nil {
+			t.Fatalf("All() error = %v", err)
+		}
+		if count++; count == 7 || record == nil {
+			break
+		}
+	}
+	if count != 7 || api.requests != 2 {
+		t.Errorf("All() read %v records in %v requests, want 7 in 2", count, api.requests)
+	}
+
+	api.requests = 0
+	odd, err := Collect(Take(Filter(repo.All(context.TODO(), ScanLimit(5)), func(r *sampleRecord) bool {
+		return r.Name == "1"
+	}), 3))
+	if err != nil || len(odd) != 3 || odd[2].Part != 5 || api.requests != 2 {
+		t.Errorf("Collect() = %v records, %v, after %v requests", len(odd), err, api.requests)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Collect(repo.All(ctx)); !errors.Is(err, context.Canceled) {
+		t.Errorf("Collect() on a cancelled context error = %v", err)
+	}
+}
+
+func TestDdbRepo_QueryIter(t *testing.T) {
+	api := &countingApi{MemoryDynamoDb: ddbrepotest.NewMemoryDynamoDb()}
+	repo := newTestRepo(t, api, "iterated", iterRecords()...)
+	api.requests = 0
+	records, err := Collect(repo.QueryIter(context.TODO(), &sampleRecord{ID: "id"}, RangeKeyGreaterOrEqual(10), Descending()))
+	if err != nil || len(records) != 10 || records[0].Part != 19 {
+		t.Errorf("QueryIter() = %v records, %v", len(records), err)
+	}
+	api.requests = 0
+	records, err = Collect(repo.QueryIter(context.TODO(), &sampleRecord{ID: "id"}, Limit(3)))
+	if err != nil || len(records) != 3 || api.requests != 1 {
+		t.Errorf("QueryIter() with limit = %v records, %v, after %v requests", len(records), err, api.requests)
+	}
+	if _, err := Collect(repo.QueryIter(context.TODO(), nil)); err == nil {
+		t.Errorf("QueryIter() without source succeeded")
+	}
+}
@@ -0,0 +1,123 @@
+package ddbrepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"iter"
+	"reflect"
+)
+
+// ProjectionRepo queries a secondary index of the records of T and
+// unmarshals the items into the projection struct P, which may only store
+// attributes projected into the index.
+type ProjectionRepo[T any, P any] struct {
+	repo       *DdbRepo[T]
+	index      string
+	attributes []string
+}
+
+// NewProjectionRepo checks that every attribute stored by P is an attribute
+// of T projected into the index.
+func NewProjectionRepo[T any, P any](repo *DdbRepo[T], index string) (*ProjectionRepo[T, P], error) {
+	if repo == nil {
+		return nil, errors.New("repo is required")
+	}
+	projection := repo.indexProjection(index)
+	if projection == nil {
+		return nil, fmt.Errorf("index %v is not defined for %v", index, reflect.TypeOf((*T)(nil)).Elem())
+	}
+	projectionType := reflect.TypeOf((*P)(nil)).Elem()
+	if projectionType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("ddb repo can't use %v as a projection", projectionType)
+	}
+	recordCodec, err := codecOf(repo, reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil {
+		return nil, err
+	}
+	projectionCodec, err := codecOf(repo, projectionType)
+	if err != nil {
+		return nil, err
+	}
+	projected := repo.projectedAttributes(index, projection)
+	result := &ProjectionRepo[T, P]{repo: repo, index: index}
+	for _, field := range projectionCodec.fields {
+		name := field.spec.name
+		if _, found := recordCodec.byName[name]; !found {
+			return nil, fmt.Errorf("%v of %v is not an attribute of %v", field.field.Name, projectionType, reflect.TypeOf((*T)(nil)).Elem())
+		} else if projected != nil && !projected[name] {
+			return nil, fmt.Errorf("%v of %v is not projected into index %v", field.field.Name, projectionType, index)
+		}
+		result.attributes = append(result.attributes, name)
+	}
+	if len(result.attributes) == 0 {
+		return nil, fmt.Errorf("%v stores no attributes", projectionType)
+	}
+	return result, nil
+}
+
+func (repo *DdbRepo[T]) projectedAttributes(index string, projection *types.Projection) map[string]bool {
+	if projection.ProjectionType == types.ProjectionTypeAll {
+		return nil
+	}
+	result := make(map[string]bool)
+	indexKeys, _ := repo.indexKeySchema(index)
+	for _, key := range append(append([]types.KeySchemaElement{}, repo.keySchema...), indexKeys...) {
+		result[aws.ToString(key.AttributeName)] = true
+	}
+	if projection.ProjectionType == types.ProjectionTypeInclude {
+		for _, name := range projection.NonKeyAttributes {
+			result[name] = true
+		}
+	}
+	return result
+}
+
+// Index returns the name of the queried index.
+func (pr *ProjectionRepo[T, P]) Index() string {
+	return pr.index
+}
+
+func (pr *ProjectionRepo[T, P]) Query(callback func(p *P) error, source *T, options ...QueryArg) error {
+	return pr.QueryCtx(context.TODO(), callback, source, options...)
+}
+
+// QueryCtx queries the index with the hash key taken from the source record
+// and calls back with every projection; only the attributes of P are read.
+func (pr *ProjectionRepo[T, P]) QueryCtx(ctx context.Context, callback func(p *P) error, source *T, options ...QueryArg) error {
+	for projection, err := range pr.QueryIter(ctx, source, options...) {
+		if err != nil {
+			return err
+		} else if err := callback(projection); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// QueryIter iterates over the projections returned by a query of the index;
+// Limit bounds the number of projections.
+func (pr *ProjectionRepo[T, P]) QueryIter(ctx context.Context, source *T, options ...QueryArg) iter.Seq2[*P, error] {
+	return func(yield func(*P, error) bool) {
+		query := append(options[:len(options):len(options)], OnIndex(pr.index), Projection(pr.attributes...))
+		input, limit, err := buildQuery(pr.repo, source, query)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		for item, err := range queryItems(ctx, pr.repo, input, limit) {
+			projection := new(P)
+			if err == nil {
+				err = Unmarshal(pr.repo, projection, item)
+			}
+			if err != nil {
+				yield(nil, err)
+				return
+			} else if !yield(projection, nil) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package ddbrepo
+
+import (
+	"context"
+	"errors"
+	"github.com/rotmistrk/ddbrepo/ddbrepotest"
+	"github.com/rotmistrk/must"
+	"reflect"
+	"testing"
+)
+
+type orderStatusView struct {
+	Customer string `ddb:"customer"`
+	OrderID  string `ddb:"orderId"`
+	Status   string `ddb:"status"`
+}
+
+type orderShopView struct {
+	OrderID string `ddb:"orderId"`
+	Shop    string `ddb:"shop"`
+	Total   int    `ddb:"total"`
+}
+
+type orderUnknownView struct {
+	OrderID string `ddb:"orderId"`
+	Rating  int    `ddb:"rating"`
+}
+
+func TestNewProjectionRepo(t *testing.T) {
+	repo := must.Must(New[orderGsiRecord]())
+	tests := []struct {
+		name    string
+		new     func() error
+		wantErr bool
+	}{
+		{"keys of keys only", func() error {
+			_, err := NewProjectionRepo[orderGsiRecord, orderStatusView](repo, "byStatus")
+			return err
+		}, false},
+		{"included attribute", func() error { _, err := NewProjectionRepo[orderGsiRecord, orderShopView](repo, "byShop"); return err }, false},
+		{"attribute not projected", func() error { _, err := NewProjectionRepo[orderGsiRecord, orderShopView](repo, "byStatus"); return err }, true},
+		{"unknown attribute", func() error {
+			_, err := NewProjectionRepo[orderGsiRecord, orderUnknownView](repo, "byShop")
+			return err
+		}, true},
+		{"unknown index", func() error {
+			_, err := NewProjectionRepo[orderGsiRecord, orderShopView](repo, "byNothing")
+			return err
+		}, true},
+		{"not a struct", func() error { _, err := NewProjectionRepo[orderGsiRecord, string](repo, "byShop"); return err }, true},
+		{"all projected", func() error {
+			_, err := NewProjectionRepo[listingLsiRecord, listingLsiRecord](must.Must(New[listingLsiRecord]()), "byPrice")
+			return err
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.new(); (err != nil) != tt.wantErr {
+				t.Errorf("NewProjectionRepo() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProjectionRepo_Query(t *testing.T) {
+	repo := must.Must(New[orderGsiRecord]()).WithTableName("orders").WithDynamoDbApi(ddbrepotest.NewMemoryDynamoDb())
+	if err := repo.TableCreate(); err != nil {
+		t.Fatal(err)
+	}
+	for i, id := range []string{"o1", "o2", "o3"} {
+		record := &orderGsiRecord{Customer: "c1", OrderID: id, Status: "new", Placed: "2024-0" + id, Shop: "s1", Total: 10 * (i + 1), Notes: "long"}
+		if err := repo.PutItem(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	shops := must.Must(NewProjectionRepo[orderGsiRecord, orderShopView](repo, "byShop"))
+	var got []orderShopView
+	err := shops.Query(func(p *orderShopView) error {
+		got = append(got, *p)
+		return nil
+	}, &orderGsiRecord{Shop: "s1"}, RangeKeyGreaterThan("2024-0o1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []orderShopView{{OrderID: "o2", Shop: "s1", Total: 20}, {OrderID: "o3", Shop: "s1", Total: 30}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Query() = %v, want %v", JsonLine(&got), JsonLine(&want))
+	}
+
+	statuses := must.Must(NewProjectionRepo[orderGsiRecord, orderStatusView](repo, "byStatus"))
+	var ids []string
+	for view, err := range statuses.QueryIter(context.TODO(), &orderGsiRecord{Status: "new"}, Descending(), Limit(2)) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, view.OrderID)
+	}
+	if !reflect.DeepEqual(ids, []string{"o3", "o2"}) {
+		t.Errorf("QueryIter() = %v, want [o3 o2]", ids)
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err = statuses.Query(func(p *orderStatusView) error {
+		calls++
+		return stop
+	}, &orderGsiRecord{Status: "new"})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("Query() = %v after %v calls, want the callback error after 1 call", err, calls)
+	}
+}
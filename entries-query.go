@@ -2,41 +2,301 @@ package ddbrepo
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"maps"
+	"reflect"
+	"strings"
 )
 
-type QueryOption func(query *dynamodb.QueryInput)
+type queryParams struct {
+	index          string
+	rangeCondition string
+	rangeValues    []interface{}
+	descending     bool
+	limit          int32
+	consistentRead bool
+	projection     []string
+	mutators       []QueryOption
+}
+
+// QueryArg is either a QueryParam or a QueryOption.
+type QueryArg interface {
+	applyQuery(query *queryParams) error
+}
+
+// QueryOption mutates the generated input of a query, after the key
+// condition, index, limit and projection are filled in.
+type QueryOption func(input *dynamodb.QueryInput)
+
+func (o QueryOption) applyQuery(query *queryParams) error {
+	query.mutators = append(query.mutators, o)
+	return nil
+}
+
+// QueryParam sets a parameter the input of a query is generated from, like
+// a range key condition or the index.
+type QueryParam func(query *queryParams) error
+
+func (p QueryParam) applyQuery(query *queryParams) error {
+	return p(query)
+}
+
+func rangeKeyCondition(condition string, values ...interface{}) QueryParam {
+	return QueryParam(func(query *queryParams) error {
+		if query.rangeCondition != "" {
+			return errors.New("only one range key condition is allowed")
+		}
+		query.rangeCondition = condition
+		query.rangeValues = values
+		return nil
+	})
+}
+
+func RangeKeyEquals(value interface{}) QueryParam {
+	return rangeKeyCondition("#rk = :rk0", value)
+}
+
+func RangeKeyLessThan(value interface{}) QueryParam {
+	return rangeKeyCondition("#rk < :rk0", value)
+}
+
+func RangeKeyLessOrEqual(value interface{}) QueryParam {
+	return rangeKeyCondition("#rk <= :rk0", value)
+}
+
+func RangeKeyGreaterThan(value interface{}) QueryParam {
+	return rangeKeyCondition("#rk > :rk0", value)
+}
+
+func RangeKeyGreaterOrEqual(value interface{}) QueryParam {
+	return rangeKeyCondition("#rk >= :rk0", value)
+}
+
+func RangeKeyBetween(low, high interface{}) QueryParam {
+	return rangeKeyCondition("#rk BETWEEN :rk0 AND :rk1", low, high)
+}
+
+func RangeKeyBeginsWith(prefix interface{}) QueryParam {
+	return rangeKeyCondition("begins_with(#rk, :rk0)", prefix)
+}
+
+func Descending() QueryParam {
+	return QueryParam(func(query *queryParams) error {
+		query.descending = true
+		return nil
+	})
+}
+
+// Limit stops the query after the given number of records.
+func Limit(limit int32) QueryParam {
+	return QueryParam(func(query *queryParams) error {
+		if limit <= 0 {
+			return fmt.Errorf("limit must be positive, got %v", limit)
+		}
+		query.limit = limit
+		return nil
+	})
+}
+
+func ConsistentRead() QueryParam {
+	return QueryParam(func(query *queryParams) error {
+		query.consistentRead = true
+		return nil
+	})
+}
+
+// Projection restricts the loaded fields, named by Go field or attribute name.
+func Projection(fields ...string) QueryParam {
+	return QueryParam(func(query *queryParams) error {
+		query.projection = append(query.projection, fields...)
+		return nil
+	})
+}
+
+// OnIndex queries the secondary index with the given name; the hash key value
+// is taken from the index hash key field of the source record.
+func OnIndex(name string) QueryParam {
+	return QueryParam(func(query *queryParams) error {
+		query.index = name
+		return nil
+	})
+}
+
+func (repo *DdbRepo[T]) indexKeyNames(index string) (hashKey string, rangeKey string, err error) {
+	schema := repo.keySchema
+	if index != "" {
+		var found bool
+		if schema, found = repo.indexKeySchema(index); !found {
+			return "", "", fmt.Errorf("index %v is not defined for %v", index, repo.tableName)
+		}
+	}
+	if hashKey, rangeKey = keyNames(schema); hashKey == "" {
+		err = fmt.Errorf("no hash key defined for index %v", index)
+	}
+	return
+}
 
-func QueryHkCbk[R any](repo *DdbRepo[R], callback func(r *R) error, source *R, condition ...QueryOption) error {
-	hashKeyName, err := repo.HashKeyName()
+func (repo *DdbRepo[T]) isGlobalIndex(index string) bool {
+	_, found := repo.gsi[index]
+	return found
+}
+
+func buildQuery[R any](repo *DdbRepo[R], source *R, options []QueryArg) (*dynamodb.QueryInput, int32, error) {
+	if source == nil {
+		return nil, 0, errors.New("source record pointer is required")
+	}
+	query := &queryParams{}
+	for _, option := range options {
+		if err := option.applyQuery(query); err != nil {
+			return nil, 0, err
+		}
+	}
+	hashKeyName, rangeKeyName, err := repo.indexKeyNames(query.index)
 	if err != nil {
-		return err
+		return nil, 0, err
 	}
-	key, err := MarshalTagFilter(repo, source, IncludeHashKey, ":")
+	fields, err := fieldSpecsOf(repo, source)
 	if err != nil {
-		return err
+		return nil, 0, err
+	}
+	key, err := MarshalTagFilter(repo, source, func(spec fieldSpec) bool {
+		return spec.name == hashKeyName
+	}, ":")
+	if err != nil {
+		return nil, 0, err
+	}
+	names := map[string]string{"#hk": hashKeyName}
+	values := key
+	keyCondition := "#hk = :" + hashKeyName
+	if query.rangeCondition != "" {
+		if rangeKeyName == "" {
+			return nil, 0, fmt.Errorf("range key condition on %v which has no range key", repo.tableName)
+		}
+		spec := fields[rangeKeyName]
+		for i, value := range query.rangeValues {
+			if values[fmt.Sprintf(":rk%v", i)], err = marshalFieldValue(repo, spec, value); err != nil {
+				return nil, 0, fmt.Errorf("range key value: %w", err)
+			}
+		}
+		names["#rk"] = rangeKeyName
+		keyCondition += " AND " + query.rangeCondition
 	}
 	input := &dynamodb.QueryInput{
 		TableName:                 aws.String(repo.tableName),
-		KeyConditionExpression:    aws.String(hashKeyName + "= :" + hashKeyName),
-		ExpressionAttributeValues: key,
-		ExclusiveStartKey:         nil,
+		KeyConditionExpression:    aws.String(keyCondition),
+		ExpressionAttributeNames:  maps.Clone(names),
+		ExpressionAttributeValues: maps.Clone(values),
+	}
+	if query.index != "" {
+		input.IndexName = aws.String(query.index)
+	}
+	if query.descending {
+		input.ScanIndexForward = aws.Bool(false)
+	}
+	if query.limit > 0 {
+		input.Limit = aws.Int32(query.limit)
+	}
+	if query.consistentRead {
+		if repo.isGlobalIndex(query.index) {
+			return nil, 0, fmt.Errorf("consistent read is not supported on global secondary index %v", query.index)
+		}
+		input.ConsistentRead = aws.Bool(true)
+	}
+	if len(query.projection) > 0 {
+		paths := make([]string, 0, len(query.projection))
+		for i, field := range query.projection {
+			if spec, found := fields[field]; !found {
+				return nil, 0, fmt.Errorf("unknown field %v", field)
+			} else {
+				placeholder := fmt.Sprintf("#p%v", i)
+				names[placeholder] = spec.name
+				input.ExpressionAttributeNames[placeholder] = spec.name
+				paths = append(paths, placeholder)
+			}
+		}
+		input.ProjectionExpression = aws.String(strings.Join(paths, ", "))
+	}
+	for _, mutate := range query.mutators {
+		mutate(input)
+	}
+	if aws.ToString(input.KeyConditionExpression) != keyCondition {
+		if query.rangeCondition != "" || query.index != "" {
+			return nil, 0, fmt.Errorf("query option replaced the key condition %v", keyCondition)
+		}
+		input.ExpressionAttributeNames = pruneUnused(input, input.ExpressionAttributeNames, names)
+		input.ExpressionAttributeValues = pruneUnused(input, input.ExpressionAttributeValues, values)
+		return input, query.limit, nil
+	}
+	if input.ExpressionAttributeNames, err = mergePlaceholders(input.ExpressionAttributeNames, names); err != nil {
+		return nil, 0, err
+	} else if input.ExpressionAttributeValues, err = mergePlaceholders(input.ExpressionAttributeValues, values); err != nil {
+		return nil, 0, err
+	}
+	return input, query.limit, nil
+}
+
+func mergePlaceholders[V any](target map[string]V, generated map[string]V) (map[string]V, error) {
+	if target == nil {
+		target = make(map[string]V, len(generated))
+	}
+	for placeholder, value := range generated {
+		if current, found := target[placeholder]; !found {
+			target[placeholder] = value
+		} else if !reflect.DeepEqual(current, value) {
+			return nil, fmt.Errorf("query option redefined the placeholder %v", placeholder)
+		}
+	}
+	return target, nil
+}
+
+func pruneUnused[V any](input *dynamodb.QueryInput, target map[string]V, generated map[string]V) map[string]V {
+	expressions := aws.ToString(input.KeyConditionExpression) + " " + aws.ToString(input.FilterExpression) + " " + aws.ToString(input.ProjectionExpression)
+	for placeholder := range generated {
+		if !strings.Contains(expressions, placeholder) {
+			delete(target, placeholder)
+		}
 	}
-	for _, c := range condition {
-		c(input)
+	return nonEmpty(target)
+}
+
+func queryKey(input *dynamodb.QueryInput) map[string]types.AttributeValue {
+	name := input.ExpressionAttributeNames["#hk"]
+	return map[string]types.AttributeValue{name: input.ExpressionAttributeValues[":"+name]}
+}
+
+func QueryHkCbk[R any](repo *DdbRepo[R], callback func(r *R) error, source *R, condition ...QueryArg) error {
+	return QueryHkCbkCtx(context.TODO(), repo, callback, source, condition...)
+}
+
+func QueryHkCbkCtx[R any](ctx context.Context, repo *DdbRepo[R], callback func(r *R) error, source *R, condition ...QueryArg) error {
+	input, limit, err := buildQuery(repo, source, condition)
+	if err != nil {
+		return err
 	}
+	delivered := int32(0)
 	for {
-		if output, err := repo.ddbClient.Query(context.TODO(), input); err != nil {
+		if err := ctx.Err(); err != nil {
 			return err
+		}
+		if output, err := repo.ddbClient.Query(ctx, input); err != nil {
+			return opError("Query", repo.tableName, queryKey(input), err)
 		} else {
 			for _, item := range output.Items {
 				var record R
-				if err := Unmarshal(repo, &record, item); err != nil {
+				if err := ctx.Err(); err != nil {
+					return err
+				} else if err := Unmarshal(repo, &record, item); err != nil {
 					return err
 				} else if err = callback(&record); err != nil {
 					return err
 				}
+				if delivered++; limit > 0 && delivered >= limit {
+					return nil
+				}
 			}
 			if output.LastEvaluatedKey == nil {
 				break
@@ -48,3 +308,43 @@ func QueryHkCbk[R any](repo *DdbRepo[R], callback func(r *R) error, source *R, c
 
 	return nil
 }
+
+type Page[R any] struct {
+	Records []*R
+	Cursor  string
+}
+
+func newPage[R any](repo *DdbRepo[R], items []map[string]types.AttributeValue, lastKey map[string]types.AttributeValue) (*Page[R], error) {
+	page := &Page[R]{Records: make([]*R, 0, len(items))}
+	for _, item := range items {
+		record := new(R)
+		if err := Unmarshal(repo, record, item); err != nil {
+			return nil, err
+		}
+		page.Records = append(page.Records, record)
+	}
+	var err error
+	page.Cursor, err = encodeCursor(lastKey, repo.cursorSecret)
+	return page, err
+}
+
+func QueryPage[R any](repo *DdbRepo[R], source *R, cursor string, options ...QueryArg) (*Page[R], error) {
+	return QueryPageCtx(context.TODO(), repo, source, cursor, options...)
+}
+
+// QueryPageCtx reads a single page of the query starting at cursor; Limit
+// sets the page size.
+func QueryPageCtx[R any](ctx context.Context, repo *DdbRepo[R], source *R, cursor string, options ...QueryArg) (*Page[R], error) {
+	input, _, err := buildQuery(repo, source, options)
+	if err != nil {
+		return nil, err
+	}
+	if input.ExclusiveStartKey, err = decodeCursor(cursor, repo.cursorSecret); err != nil {
+		return nil, err
+	}
+	output, err := repo.ddbClient.Query(ctx, input)
+	if err != nil {
+		return nil, opError("Query", repo.tableName, queryKey(input), err)
+	}
+	return newPage(repo, output.Items, output.LastEvaluatedKey)
+}
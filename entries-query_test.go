@@ -0,0 +1,106 @@
+package ddbrepo
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/rotmistrk/ddbrepo/ddbrepotest"
+	"reflect"
+	"testing"
+)
+
+type eventRecord struct {
+	Stream string `ddb:"stream,hash-key"`
+	Seq    int    `ddb:"seq,range-key"`
+	Kind   string `ddb:"kind" ddb-gsi:"byKind hash-key"`
+	Label  string `ddb:"label" ddb-gsi:"byKind range-key"`
+	Body   string `ddb:"body"`
+}
+
+func eventRecords() []*eventRecord {
+	var result []*eventRecord
+	for i := 1; i <= 10; i++ {
+		kind := "odd"
+		if i%2 == 0 {
+			kind = "even"
+		}
+		for _, stream := range []string{"s1", "s2"} {
+			result = append(result, &eventRecord{Stream: stream, Seq: i, Kind: kind, Label: fmt.Sprintf("%v-%02d", stream, i), Body: "body"})
+		}
+	}
+	return result
+}
+
+func TestQueryHkCbk_Options(t *testing.T) {
+	repo := newTestRepo(t, ddbrepotest.NewMemoryDynamoDb(), "events", eventRecords()...)
+	tests := []struct {
+		name    string
+		source  eventRecord
+		options []QueryArg
+		want    []int
+		wantErr bool
+	}{
+		{"hash key only", eventRecord{Stream: "s1"}, nil, []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, false},
+		{"equals", eventRecord{Stream: "s1"}, []QueryArg{RangeKeyEquals(3)}, []int{3}, false},
+		{"less than", eventRecord{Stream: "s1"}, []QueryArg{RangeKeyLessThan(3)}, []int{1, 2}, false},
+		{"less or equal", eventRecord{Stream: "s1"}, []QueryArg{RangeKeyLessOrEqual(3)}, []int{1, 2, 3}, false},
+		{"greater than", eventRecord{Stream: "s1"}, []QueryArg{RangeKeyGreaterThan(8)}, []int{9, 10}, false},
+		{"greater or equal", eventRecord{Stream: "s1"}, []QueryArg{RangeKeyGreaterOrEqual(8)}, []int{8, 9, 10}, false},
+		{"between descending", eventRecord{Stream: "s1"}, []QueryArg{RangeKeyBetween(3, 5), Descending()}, []int{5, 4, 3}, false},
+		{"limit", eventRecord{Stream: "s2"}, []QueryArg{Limit(4), Descending()}, []int{10, 9, 8, 7}, false},
+		{"index", eventRecord{Kind: "even"}, []QueryArg{OnIndex("byKind"), RangeKeyBeginsWith("s2-")}, []int{2, 4, 6, 8, 10}, false},
+		{"consistent read", eventRecord{Stream: "s1"}, []QueryArg{ConsistentRead(), RangeKeyEquals(1)}, []int{1}, false},
+		{"raw", eventRecord{Stream: "s1"}, []QueryArg{QueryOption(func(input *dynamodb.QueryInput) {
+			input.TableName = nil
+		})}, nil, true},
+		{"caller option", eventRecord{Stream: "s1"}, []QueryArg{RangeKeyLessThan(5), QueryOption(func(input *dynamodb.QueryInput) {
+			input.FilterExpression = aws.String("#kind = :kind")
+			input.ExpressionAttributeNames = map[string]string{"#kind": "kind"}
+			input.ExpressionAttributeValues = map[string]types.AttributeValue{":kind": &types.AttributeValueMemberS{Value: "odd"}}
+		})}, []int{1, 3}, false},
+		{"caller filter on the hash key value", eventRecord{Stream: "s1"}, []QueryArg{QueryOption(func(input *dynamodb.QueryInput) {
+			input.FilterExpression = aws.String("begins_with(label, :stream)")
+		})}, []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, false},
+		{"caller key condition", eventRecord{Stream: "s1"}, []QueryArg{QueryOption(func(input *dynamodb.QueryInput) {
+			input.KeyConditionExpression = aws.String("stream = :stream AND seq > :seq")
+			input.ExpressionAttributeValues[":seq"] = &types.AttributeValueMemberN{Value: "8"}
+		})}, []int{9, 10}, false},
+		{"caller key condition with range condition", eventRecord{Stream: "s1"}, []QueryArg{RangeKeyLessThan(5), QueryOption(func(input *dynamodb.QueryInput) {
+			input.KeyConditionExpression = aws.String("stream = :stream")
+		})}, nil, true},
+		{"caller redefines a placeholder", eventRecord{Stream: "s1"}, []QueryArg{QueryOption(func(input *dynamodb.QueryInput) {
+			input.ExpressionAttributeValues = map[string]types.AttributeValue{":stream": &types.AttributeValueMemberS{Value: "s2"}}
+		})}, nil, true},
+		{"consistent read on gsi", eventRecord{Kind: "even"}, []QueryArg{OnIndex("byKind"), ConsistentRead()}, nil, true},
+		{"unknown index", eventRecord{Stream: "s1"}, []QueryArg{OnIndex("missing")}, nil, true},
+		{"two range conditions", eventRecord{Stream: "s1"}, []QueryArg{RangeKeyEquals(1), RangeKeyLessThan(2)}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make([]int, 0)
+			err := QueryHkCbk(repo, func(r *eventRecord) error {
+				got = append(got, r.Seq)
+				return nil
+			}, &tt.source, tt.options...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("QueryHkCbk() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("QueryHkCbk() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryHkCbk_Projection(t *testing.T) {
+	repo := newTestRepo(t, ddbrepotest.NewMemoryDynamoDb(), "events", eventRecords()...)
+	got := make([]eventRecord, 0)
+	err := QueryHkCbk(repo, func(r *eventRecord) error {
+		got = append(got, *r)
+		return nil
+	}, &eventRecord{Stream: "s1"}, RangeKeyEquals(2), Projection("Seq", "label"))
+	if err != nil || len(got) != 1 || !reflect.DeepEqual(got[0], eventRecord{Seq: 2, Label: "s1-02"}) {
+		t.Errorf("QueryHkCbk() = %v, %v", got, err)
+	}
+}
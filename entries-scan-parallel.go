@@ -0,0 +1,182 @@
+package ddbrepo
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"sync"
+)
+
+const MaxScanSegments = 1000000
+
+// ScanCheckpoint is the progress of one segment of a parallel scan; Cursor
+// is where the segment continues unless it is Done.
+type ScanCheckpoint struct {
+	Segment       int32
+	TotalSegments int32
+	Cursor        string
+	Done          bool
+}
+
+type ParallelScanConfig struct {
+	TotalSegments int32
+	// Workers bounds the number of segments scanned at once; it defaults to
+	// TotalSegments.
+	Workers int
+	// ConcurrentCallbacks allows the callback to run concurrently from
+	// several segments; otherwise invocations are serialised.
+	ConcurrentCallbacks bool
+	// Resume continues from previously saved checkpoints, one per segment.
+	Resume []ScanCheckpoint
+	// OnCheckpoint is called, serialised, after each page is delivered.
+	OnCheckpoint func(checkpoint ScanCheckpoint) error
+}
+
+func (config *ParallelScanConfig) checkpoints() ([]ScanCheckpoint, error) {
+	total := config.TotalSegments
+	if total == 0 && len(config.Resume) > 0 {
+		total = config.Resume[0].TotalSegments
+	}
+	if total < 1 || total > MaxScanSegments {
+		return nil, fmt.Errorf("total segments must be between 1 and %v, got %v", MaxScanSegments, total)
+	}
+	if config.Workers < 0 {
+		return nil, fmt.Errorf("workers must not be negative, got %v", config.Workers)
+	}
+	result := make([]ScanCheckpoint, total)
+	for i := range result {
+		result[i] = ScanCheckpoint{Segment: int32(i), TotalSegments: total}
+	}
+	if len(config.Resume) == 0 {
+		return result, nil
+	} else if len(config.Resume) != int(total) {
+		return nil, fmt.Errorf("%v checkpoints can't resume a scan of %v segments", len(config.Resume), total)
+	}
+	for _, checkpoint := range config.Resume {
+		if checkpoint.TotalSegments != total || checkpoint.Segment < 0 || checkpoint.Segment >= total {
+			return nil, fmt.Errorf("checkpoint for segment %v of %v does not match a scan of %v segments", checkpoint.Segment, checkpoint.TotalSegments, total)
+		}
+		result[checkpoint.Segment] = checkpoint
+	}
+	return result, nil
+}
+
+func (repo *DdbRepo[RecordType]) ParallelScanCbk(config ParallelScanConfig, callback func(record *RecordType) error, options ...ScanOption) error {
+	return repo.ParallelScanCbkCtx(context.TODO(), config, callback, options...)
+}
+
+// ParallelScanCbkCtx scans the segments of the table with a bounded pool of
+// workers; the first error cancels the remaining segments and is returned.
+func (repo *DdbRepo[RecordType]) ParallelScanCbkCtx(ctx context.Context, config ParallelScanConfig, callback func(record *RecordType) error, options ...ScanOption) error {
+	checkpoints, err := config.checkpoints()
+	if err != nil {
+		return err
+	}
+	if _, err := repo.buildScan(options); err != nil {
+		return err
+	}
+	workers := config.Workers
+	if workers == 0 || workers > len(checkpoints) {
+		workers = len(checkpoints)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var firstErr error
+	var failOnce sync.Once
+	fail := func(err error) {
+		failOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+	var callbackMutex, checkpointMutex sync.Mutex
+	deliver := func(record *RecordType) error {
+		if !config.ConcurrentCallbacks {
+			callbackMutex.Lock()
+			defer callbackMutex.Unlock()
+		}
+		return callback(record)
+	}
+	report := func(checkpoint ScanCheckpoint) error {
+		if config.OnCheckpoint == nil {
+			return nil
+		}
+		checkpointMutex.Lock()
+		defer checkpointMutex.Unlock()
+		return config.OnCheckpoint(checkpoint)
+	}
+
+	segments := make(chan ScanCheckpoint)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for checkpoint := range segments {
+				if err := repo.scanSegment(ctx, checkpoint, deliver, report, options); err != nil {
+					fail(err)
+				}
+			}
+		}()
+	}
+feed:
+	for _, checkpoint := range checkpoints {
+		if checkpoint.Done {
+			continue
+		}
+		select {
+		case segments <- checkpoint:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(segments)
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+func (repo *DdbRepo[RecordType]) scanSegment(ctx context.Context, checkpoint ScanCheckpoint, deliver func(record *RecordType) error, report func(checkpoint ScanCheckpoint) error, options []ScanOption) error {
+	input, err := repo.buildScan(options)
+	if err != nil {
+		return err
+	}
+	input.Segment = aws.Int32(checkpoint.Segment)
+	input.TotalSegments = aws.Int32(checkpoint.TotalSegments)
+	if input.ExclusiveStartKey, err = decodeCursor(checkpoint.Cursor, repo.cursorSecret); err != nil {
+		return err
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		output, err := repo.ddbClient.Scan(ctx, input)
+		if err != nil {
+			return opError(fmt.Sprintf("Scan segment %v", checkpoint.Segment), repo.tableName, nil, err)
+		}
+		for _, item := range output.Items {
+			var result RecordType
+			if err := ctx.Err(); err != nil {
+				return err
+			} else if err := Unmarshal(repo, &result, item); err != nil {
+				return err
+			} else if err := deliver(&result); err != nil {
+				return err
+			}
+		}
+		if checkpoint.Cursor, err = encodeCursor(output.LastEvaluatedKey, repo.cursorSecret); err != nil {
+			return err
+		}
+		checkpoint.Done = checkpoint.Cursor == ""
+		if err := report(checkpoint); err != nil {
+			return err
+		}
+		if checkpoint.Done {
+			return nil
+		}
+		input.ExclusiveStartKey = output.LastEvaluatedKey
+	}
+}
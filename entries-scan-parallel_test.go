@@ -0,0 +1,99 @@
+package ddbrepo
+
+import (
+	"errors"
+	"fmt"
+	"github.com/rotmistrk/ddbrepo/ddbrepotest"
+	"sync"
+	"testing"
+)
+
+func scanRecords(count int) []*sampleRecord {
+	result := make([]*sampleRecord, 0, count)
+	for i := 0; i < count; i++ {
+		result = append(result, &sampleRecord{ID: fmt.Sprint("id-", i)})
+	}
+	return result
+}
+
+func TestDdbRepo_ParallelScanCbk(t *testing.T) {
+	repo := newTestRepo(t, ddbrepotest.NewMemoryDynamoDb(), "parallel", scanRecords(60)...)
+	tests := []struct {
+		name   string
+		config ParallelScanConfig
+	}{
+		{"serialised", ParallelScanConfig{TotalSegments: 4, Workers: 2}},
+		{"concurrent", ParallelScanConfig{TotalSegments: 8, ConcurrentCallbacks: true}},
+		{"single segment", ParallelScanConfig{TotalSegments: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mutex sync.Mutex
+			seen := make(map[string]int)
+			err := repo.ParallelScanCbk(tt.config, func(record *sampleRecord) error {
+				mutex.Lock()
+				defer mutex.Unlock()
+				seen[record.ID]++
+				return nil
+			}, ScanLimit(5))
+			if err != nil || len(seen) != 60 {
+				t.Errorf("ParallelScanCbk() saw %v records, error = %v", len(seen), err)
+			}
+			for id, count := range seen {
+				if count != 1 {
+					t.Errorf("ParallelScanCbk() delivered %v %v times", id, count)
+				}
+			}
+		})
+	}
+	if err := repo.ParallelScanCbk(ParallelScanConfig{}, func(record *sampleRecord) error { return nil }); err == nil {
+		t.Errorf("ParallelScanCbk() without segments succeeded")
+	}
+}
+
+func TestDdbRepo_ParallelScanCbk_Resume(t *testing.T) {
+	repo := newTestRepo(t, ddbrepotest.NewMemoryDynamoDb(), "parallel", scanRecords(60)...)
+	failure := errors.New("stop")
+	checkpoints := make(map[int32]ScanCheckpoint)
+	seen := make(map[string]bool)
+	delivered := 0
+	config := ParallelScanConfig{
+		TotalSegments: 4,
+		Workers:       2,
+		OnCheckpoint: func(checkpoint ScanCheckpoint) error {
+			checkpoints[checkpoint.Segment] = checkpoint
+			return nil
+		},
+	}
+	err := repo.ParallelScanCbk(config, func(record *sampleRecord) error {
+		if delivered++; delivered > 20 {
+			return failure
+		}
+		seen[record.ID] = true
+		return nil
+	}, ScanLimit(4))
+	if !errors.Is(err, failure) {
+		t.Fatalf("ParallelScanCbk() error = %v, want %v", err, failure)
+	}
+	config.TotalSegments = 0
+	config.Resume = make([]ScanCheckpoint, 0, 4)
+	for i := int32(0); i < 4; i++ {
+		if checkpoint, found := checkpoints[i]; found {
+			config.Resume = append(config.Resume, checkpoint)
+		} else {
+			config.Resume = append(config.Resume, ScanCheckpoint{Segment: i, TotalSegments: 4})
+		}
+	}
+	err = repo.ParallelScanCbk(config, func(record *sampleRecord) error {
+		seen[record.ID] = true
+		return nil
+	}, ScanLimit(4))
+	if err != nil || len(seen) != 60 {
+		t.Errorf("resumed ParallelScanCbk() saw %v records, error = %v", len(seen), err)
+	}
+	for segment, checkpoint := range checkpoints {
+		if !checkpoint.Done {
+			t.Errorf("segment %v is not done after resume", segment)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package ddbrepo
 
 import (
 	"context"
+	"fmt"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
@@ -24,22 +25,52 @@ func ScanCondition(expression string, param map[string]types.AttributeValue) Sca
 	}
 }
 
-func (repo *DdbRepo[RecordType]) ScanCbk(callback func(record *RecordType) error, options ...ScanOption) error {
+// ScanLimit sets the number of items evaluated per request, which is the page
+// size of ScanPage.
+func ScanLimit(limit int32) ScanOption {
+	return func(input *dynamodb.ScanInput) error {
+		if limit <= 0 {
+			return fmt.Errorf("limit must be positive, got %v", limit)
+		}
+		input.Limit = aws.Int32(limit)
+		return nil
+	}
+}
+
+func (repo *DdbRepo[RecordType]) buildScan(options []ScanOption) (*dynamodb.ScanInput, error) {
 	input := &dynamodb.ScanInput{
 		TableName: aws.String(repo.tableName),
 	}
 	for _, option := range options {
 		if err := option(input); err != nil {
-			return err
+			return nil, err
 		}
 	}
+	return input, nil
+}
+
+func (repo *DdbRepo[RecordType]) ScanCbk(callback func(record *RecordType) error, options ...ScanOption) error {
+	return repo.ScanCbkCtx(context.TODO(), callback, options...)
+}
+
+func (repo *DdbRepo[RecordType]) ScanCbkCtx(ctx context.Context, callback func(record *RecordType) error, options ...ScanOption) error {
+	input, err := repo.buildScan(options)
+	if err != nil {
+		return err
+	}
 	for {
-		output, err := repo.ddbClient.Scan(context.TODO(), input)
-		if err != nil {
+		if err := ctx.Err(); err != nil {
 			return err
 		}
+		output, err := repo.ddbClient.Scan(ctx, input)
+		if err != nil {
+			return opError("Scan", repo.tableName, nil, err)
+		}
 		for _, item := range output.Items {
 			var result RecordType
+			if err = ctx.Err(); err != nil {
+				return err
+			}
 			if err = Unmarshal(repo, &result, item); err != nil {
 				return err
 			}
@@ -50,6 +81,27 @@ func (repo *DdbRepo[RecordType]) ScanCbk(callback func(record *RecordType) error
 		if output.LastEvaluatedKey == nil {
 			return nil
 		}
-		input.ExpressionAttributeValues = output.LastEvaluatedKey
+		input.ExclusiveStartKey = output.LastEvaluatedKey
+	}
+}
+
+func (repo *DdbRepo[RecordType]) ScanPage(cursor string, options ...ScanOption) (*Page[RecordType], error) {
+	return repo.ScanPageCtx(context.TODO(), cursor, options...)
+}
+
+// ScanPageCtx reads a single page starting at cursor, empty for the first
+// page; the returned cursor is empty once the scan is complete.
+func (repo *DdbRepo[RecordType]) ScanPageCtx(ctx context.Context, cursor string, options ...ScanOption) (*Page[RecordType], error) {
+	input, err := repo.buildScan(options)
+	if err != nil {
+		return nil, err
+	}
+	if input.ExclusiveStartKey, err = decodeCursor(cursor, repo.cursorSecret); err != nil {
+		return nil, err
+	}
+	output, err := repo.ddbClient.Scan(ctx, input)
+	if err != nil {
+		return nil, opError("Scan", repo.tableName, nil, err)
 	}
+	return newPage(repo, output.Items, output.LastEvaluatedKey)
 }
@@ -0,0 +1,145 @@
+package ddbrepo
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/rotmistrk/ddbrepo/ddbrepotest"
+	"github.com/rotmistrk/must"
+	"reflect"
+	"testing"
+)
+
+type pagingMockedScan struct {
+	DynamoDbApi
+	pages [][]map[string]types.AttributeValue
+	calls int
+}
+
+func (api *pagingMockedScan) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if api.calls > 0 && !reflect.DeepEqual(params.ExclusiveStartKey, api.pages[api.calls-1][len(api.pages[api.calls-1])-1]) {
+		return nil, errors.New("scan did not continue from the last evaluated key")
+	}
+	page := api.pages[api.calls]
+	api.calls++
+	output := &dynamodb.ScanOutput{Items: page}
+	if api.calls < len(api.pages) {
+		output.LastEvaluatedKey = page[len(page)-1]
+	}
+	return output, nil
+}
+
+func TestDdbRepo_ScanCbkCtx(t *testing.T) {
+	repo := must.Must(New[sampleRecord]())
+	item := func(id string) map[string]types.AttributeValue {
+		return must.Must(Marshal(repo, &sampleRecord{ID: id}))
+	}
+	tests := []struct {
+		name      string
+		cancelAt  int
+		wantCalls int
+		wantSeen  int
+		wantErr   error
+	}{
+		{
+			name:      "all pages",
+			cancelAt:  -1,
+			wantCalls: 3,
+			wantSeen:  5,
+		},
+		{
+			name:      "cancelled between callbacks",
+			cancelAt:  1,
+			wantCalls: 1,
+			wantSeen:  1,
+			wantErr:   context.Canceled,
+		},
+		{
+			name:      "cancelled between pages",
+			cancelAt:  2,
+			wantCalls: 1,
+			wantSeen:  2,
+			wantErr:   context.Canceled,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &pagingMockedScan{pages: [][]map[string]types.AttributeValue{
+				{item("a"), item("b")},
+				{item("c"), item("d")},
+				{item("e")},
+			}}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			seen := 0
+			err := repo.WithTableName("scanned").WithDynamoDbApi(api).ScanCbkCtx(ctx, func(record *sampleRecord) error {
+				seen++
+				if seen == tt.cancelAt {
+					cancel()
+				}
+				return nil
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ScanCbkCtx() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if api.calls != tt.wantCalls {
+				t.Errorf("ScanCbkCtx() made %v calls, want %v", api.calls, tt.wantCalls)
+			}
+			if seen != tt.wantSeen {
+				t.Errorf("ScanCbkCtx() saw %v records, want %v", seen, tt.wantSeen)
+			}
+		})
+	}
+}
+
+func TestDdbRepo_ScanPage(t *testing.T) {
+	db := ddbrepotest.NewMemoryDynamoDb()
+	repo := must.Must(New[sampleRecord]()).WithTableName("paged").WithDynamoDbApi(db).WithCursorSecret([]byte("secret"))
+	if err := repo.TableCreate(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 7; i++ {
+		if err := repo.PutItem(&sampleRecord{ID: "id", Part: PartType(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	seen := make(map[PartType]bool)
+	cursor, pages := "", 0
+	for {
+		page, err := repo.ScanPage(cursor, ScanLimit(3))
+		if err != nil {
+			t.Fatalf("ScanPage() error = %v", err)
+		}
+		pages++
+		for _, record := range page.Records {
+			seen[record.Part] = true
+		}
+		if cursor = page.Cursor; cursor == "" {
+			break
+		}
+	}
+	if len(seen) != 7 || pages != 3 {
+		t.Errorf("ScanPage() saw %v records in %v pages, want 7 in 3", len(seen), pages)
+	}
+
+	page, err := QueryPage(repo, &sampleRecord{ID: "id"}, "", Limit(5))
+	if err != nil || len(page.Records) != 5 || page.Cursor == "" {
+		t.Fatalf("QueryPage() = %v, %v", page, err)
+	}
+	rest, err := QueryPage(repo, &sampleRecord{ID: "id"}, page.Cursor, Limit(5))
+	if err != nil || len(rest.Records) != 2 || rest.Records[0].Part != 5 || rest.Cursor != "" {
+		t.Errorf("QueryPage() continuation = %v, %v", rest, err)
+	}
+	unsigned := repo.WithCursorSecret(nil)
+	if _, err := QueryPage(unsigned, &sampleRecord{ID: "id"}, page.Cursor); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("QueryPage() with unexpected signature error = %v", err)
+	}
+	other := repo.WithCursorSecret([]byte("other"))
+	if _, err := other.ScanPage(page.Cursor); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("ScanPage() with foreign signature error = %v", err)
+	}
+}
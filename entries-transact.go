@@ -0,0 +1,368 @@
+package ddbrepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"strings"
+)
+
+const MaxTransactItems = 100
+
+// TransactWriteEntry is a single write of a transaction; entries are built by
+// the Transact* methods of a repo and keep any error until the transaction is
+// executed.
+type TransactWriteEntry struct {
+	client    DynamoDbApi
+	operation string
+	table     string
+	key       map[string]types.AttributeValue
+	item      types.TransactWriteItem
+	err       error
+}
+
+type TransactGetEntry struct {
+	client DynamoDbApi
+	table  string
+	key    map[string]types.AttributeValue
+	item   types.TransactGetItem
+	load   func(item map[string]types.AttributeValue) error
+	err    error
+}
+
+type TransactionFailure struct {
+	Index     int
+	Operation string
+	Table     string
+	Key       map[string]types.AttributeValue
+	Code      string
+	Message   string
+	Item      map[string]types.AttributeValue
+	Err       error
+}
+
+type TransactionError struct {
+	Failures []TransactionFailure
+	Err      error
+}
+
+func (e *TransactionError) Error() string {
+	causes := make([]string, 0, len(e.Failures))
+	for _, f := range e.Failures {
+		causes = append(causes, fmt.Sprintf("#%v %v on %v: %v", f.Index, f.Operation, f.Table, f.Code))
+	}
+	return fmt.Sprintf("transaction failed: %v", strings.Join(causes, "; "))
+}
+
+// Unwrap returns the cause of the transaction and of its failures, so a
+// missing item of a read transaction matches ErrNotFound and a failed
+// condition of a write ErrConditionFailed.
+func (e *TransactionError) Unwrap() []error {
+	result := make([]error, 0, len(e.Failures)+1)
+	if e.Err != nil {
+		result = append(result, e.Err)
+	}
+	for _, f := range e.Failures {
+		if f.Err != nil {
+			result = append(result, f.Err)
+		}
+	}
+	return result
+}
+
+var cancellationKinds = map[string]error{
+	"ConditionalCheckFailed": ErrConditionFailed,
+	"ThrottlingError":        ErrThrottled,
+}
+
+func nonEmpty[V any](m map[string]V) map[string]V {
+	if len(m) == 0 {
+		return nil
+	}
+	return m
+}
+
+func mergeValues(target map[string]types.AttributeValue, source map[string]types.AttributeValue) (map[string]types.AttributeValue, error) {
+	if target == nil {
+		target = make(map[string]types.AttributeValue, len(source))
+	}
+	for k, v := range source {
+		if _, found := target[k]; found {
+			return nil, fmt.Errorf("expression value %v is defined twice", k)
+		}
+		target[k] = v
+	}
+	return target, nil
+}
+
+func (repo DdbRepo[T]) transactEntry(operation string, record *T, op PutItemOp) (TransactWriteEntry, map[string]types.AttributeValue, string, map[string]types.AttributeValue) {
+	entry := TransactWriteEntry{client: repo.ddbClient, operation: operation, table: repo.tableName}
+	if record == nil {
+		entry.err = errors.New("record pointer is required")
+		return entry, nil, "", nil
+	}
+	if err := repo.validateConfig(); err != nil {
+		entry.err = err
+		return entry, nil, "", nil
+	}
+	var item map[string]types.AttributeValue
+	var err error
+	if operation == "Put" {
+		item, err = Marshal(&repo, record)
+	} else {
+		item, err = MarshalTagFilter(&repo, record, AnyOfFilters(IncludeKey, IncludeVersion), "")
+	}
+	if err != nil {
+		entry.err = err
+		return entry, nil, "", nil
+	}
+	if entry.key, err = MarshalKey(&repo, record, ""); err != nil {
+		entry.err = err
+		return entry, nil, "", nil
+	}
+	if op == nil {
+		op = Replace
+	}
+	cond, values, err := op(&repo, item)
+	if err != nil {
+		entry.err = err
+	}
+	return entry, item, cond, values
+}
+
+func (repo DdbRepo[T]) TransactPut(record *T, op PutItemOp) TransactWriteEntry {
+	entry, item, cond, values := repo.transactEntry("Put", record, op)
+	if entry.err == nil {
+		entry.item.Put = &types.Put{
+			TableName:                           aws.String(repo.tableName),
+			Item:                                item,
+			ExpressionAttributeValues:           nonEmpty(values),
+			ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+		}
+		if cond != "" {
+			entry.item.Put.ConditionExpression = aws.String(cond)
+		}
+	}
+	return entry
+}
+
+func (repo DdbRepo[T]) TransactDelete(record *T, op PutItemOp) TransactWriteEntry {
+	entry, _, cond, values := repo.transactEntry("Delete", record, op)
+	if entry.err == nil {
+		entry.item.Delete = &types.Delete{
+			TableName:                           aws.String(repo.tableName),
+			Key:                                 entry.key,
+			ExpressionAttributeValues:           nonEmpty(values),
+			ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+		}
+		if cond != "" {
+			entry.item.Delete.ConditionExpression = aws.String(cond)
+		}
+	}
+	return entry
+}
+
+func (repo DdbRepo[T]) TransactUpdate(record *T, update string, names map[string]string, values map[string]types.AttributeValue, op PutItemOp) TransactWriteEntry {
+	entry, _, cond, condValues := repo.transactEntry("Update", record, op)
+	if entry.err == nil {
+		merged, err := mergeValues(nil, values)
+		if err == nil {
+			merged, err = mergeValues(merged, condValues)
+		}
+		if err != nil {
+			entry.err = err
+			return entry
+		}
+		entry.item.Update = &types.Update{
+			TableName:                           aws.String(repo.tableName),
+			Key:                                 entry.key,
+			UpdateExpression:                    aws.String(update),
+			ExpressionAttributeNames:            nonEmpty(names),
+			ExpressionAttributeValues:           nonEmpty(merged),
+			ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+		}
+		if cond != "" {
+			entry.item.Update.ConditionExpression = aws.String(cond)
+		}
+	}
+	return entry
+}
+
+func (repo DdbRepo[T]) TransactConditionCheck(record *T, op PutItemOp) TransactWriteEntry {
+	entry, _, cond, values := repo.transactEntry("ConditionCheck", record, op)
+	if entry.err == nil {
+		if cond == "" {
+			entry.err = errors.New("condition check requires a condition")
+			return entry
+		}
+		entry.item.ConditionCheck = &types.ConditionCheck{
+			TableName:                           aws.String(repo.tableName),
+			Key:                                 entry.key,
+			ConditionExpression:                 aws.String(cond),
+			ExpressionAttributeValues:           nonEmpty(values),
+			ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+		}
+	}
+	return entry
+}
+
+func (repo DdbRepo[T]) TransactGet(record *T) TransactGetEntry {
+	entry := TransactGetEntry{client: repo.ddbClient, table: repo.tableName}
+	if record == nil {
+		entry.err = errors.New("record pointer is required")
+	} else if err := repo.validateConfig(); err != nil {
+		entry.err = err
+	} else if entry.key, err = MarshalKey(&repo, record, ""); err != nil {
+		entry.err = err
+	} else {
+		entry.item.Get = &types.Get{
+			TableName: aws.String(repo.tableName),
+			Key:       entry.key,
+		}
+		entry.load = func(item map[string]types.AttributeValue) error {
+			return Unmarshal(&repo, record, item)
+		}
+	}
+	return entry
+}
+
+type WriteTransaction struct {
+	entries []TransactWriteEntry
+	token   string
+}
+
+func NewWriteTransaction(entries ...TransactWriteEntry) *WriteTransaction {
+	return &WriteTransaction{entries: entries}
+}
+
+func (tx *WriteTransaction) Add(entries ...TransactWriteEntry) *WriteTransaction {
+	tx.entries = append(tx.entries, entries...)
+	return tx
+}
+
+// WithClientRequestToken makes retries of the same transaction idempotent.
+func (tx *WriteTransaction) WithClientRequestToken(token string) *WriteTransaction {
+	tx.token = token
+	return tx
+}
+
+func (tx *WriteTransaction) Execute() error {
+	return tx.ExecuteCtx(context.TODO())
+}
+
+func (tx *WriteTransaction) ExecuteCtx(ctx context.Context) error {
+	if len(tx.entries) == 0 {
+		return errors.New("transaction has no entries")
+	} else if len(tx.entries) > MaxTransactItems {
+		return fmt.Errorf("transaction has %v entries, at most %v allowed", len(tx.entries), MaxTransactItems)
+	}
+	items := make([]types.TransactWriteItem, 0, len(tx.entries))
+	for i, entry := range tx.entries {
+		if entry.err != nil {
+			return fmt.Errorf("transaction entry #%v (%v on %v): %w", i, entry.operation, entry.table, entry.err)
+		} else if entry.client == nil {
+			return fmt.Errorf("transaction entry #%v (%v on %v): ddb client is not configured", i, entry.operation, entry.table)
+		} else if entry.client != tx.entries[0].client {
+			return fmt.Errorf("transaction entry #%v (%v on %v): ddb client differs from the first entry", i, entry.operation, entry.table)
+		}
+		items = append(items, entry.item)
+	}
+	input := &dynamodb.TransactWriteItemsInput{
+		TransactItems: items,
+	}
+	if tx.token != "" {
+		input.ClientRequestToken = aws.String(tx.token)
+	}
+	_, err := tx.entries[0].client.TransactWriteItems(ctx, input)
+	var cancelled *types.TransactionCanceledException
+	if errors.As(err, &cancelled) {
+		result := &TransactionError{Err: err}
+		for i, reason := range cancelled.CancellationReasons {
+			if code := aws.ToString(reason.Code); code != "" && code != "None" && i < len(tx.entries) {
+				entry := tx.entries[i]
+				failure := TransactionFailure{
+					Index:     i,
+					Operation: entry.operation,
+					Table:     entry.table,
+					Key:       entry.key,
+					Code:      code,
+					Message:   aws.ToString(reason.Message),
+					Item:      reason.Item,
+				}
+				if kind, found := cancellationKinds[code]; found {
+					failure.Err = &OpError{Op: "Transact" + entry.operation, Table: entry.table, Key: entry.key, Kind: kind}
+				}
+				result.Failures = append(result.Failures, failure)
+			}
+		}
+		return result
+	}
+	return err
+}
+
+type ReadTransaction struct {
+	entries []TransactGetEntry
+}
+
+func NewReadTransaction(entries ...TransactGetEntry) *ReadTransaction {
+	return &ReadTransaction{entries: entries}
+}
+
+func (tx *ReadTransaction) Add(entries ...TransactGetEntry) *ReadTransaction {
+	tx.entries = append(tx.entries, entries...)
+	return tx
+}
+
+func (tx *ReadTransaction) Execute() error {
+	return tx.ExecuteCtx(context.TODO())
+}
+
+// ExecuteCtx loads every record of the transaction; items that do not exist
+// are reported as NotFound failures matching ErrNotFound while the others are
+// still loaded.
+func (tx *ReadTransaction) ExecuteCtx(ctx context.Context) error {
+	if len(tx.entries) == 0 {
+		return errors.New("transaction has no entries")
+	} else if len(tx.entries) > MaxTransactItems {
+		return fmt.Errorf("transaction has %v entries, at most %v allowed", len(tx.entries), MaxTransactItems)
+	}
+	items := make([]types.TransactGetItem, 0, len(tx.entries))
+	for i, entry := range tx.entries {
+		if entry.err != nil {
+			return fmt.Errorf("transaction entry #%v (Get on %v): %w", i, entry.table, entry.err)
+		} else if entry.client == nil {
+			return fmt.Errorf("transaction entry #%v (Get on %v): ddb client is not configured", i, entry.table)
+		} else if entry.client != tx.entries[0].client {
+			return fmt.Errorf("transaction entry #%v (Get on %v): ddb client differs from the first entry", i, entry.table)
+		}
+		items = append(items, entry.item)
+	}
+	output, err := tx.entries[0].client.TransactGetItems(ctx, &dynamodb.TransactGetItemsInput{TransactItems: items})
+	if err != nil {
+		return err
+	}
+	result := &TransactionError{}
+	for i, entry := range tx.entries {
+		var item map[string]types.AttributeValue
+		if i < len(output.Responses) {
+			item = output.Responses[i].Item
+		}
+		failure := TransactionFailure{Index: i, Operation: "Get", Table: entry.table, Key: entry.key}
+		if item == nil {
+			failure.Code, failure.Message = "NotFound", "item not found in "+entry.table
+			failure.Err = notFoundError("TransactGet", entry.table, entry.key)
+		} else if err := entry.load(item); err != nil {
+			failure.Code, failure.Message, failure.Err = "UnmarshalFailed", err.Error(), err
+		} else {
+			continue
+		}
+		result.Failures = append(result.Failures, failure)
+	}
+	if len(result.Failures) > 0 {
+		return result
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package ddbrepo
+
+import (
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/rotmistrk/ddbrepo/ddbrepotest"
+	"testing"
+)
+
+type accountRecord struct {
+	Account string `ddb:"account,hash-key"`
+	Balance int    `ddb:"balance"`
+}
+
+func TestWriteTransaction_Execute(t *testing.T) {
+	db := ddbrepotest.NewMemoryDynamoDb()
+	samples := newTestRepo(t, db, "samples", &sampleRecord{ID: "gone", Name: "old"})
+	accounts := newTestRepo(t, db, "accounts", &accountRecord{Account: "a", Balance: 10})
+	decrement := map[string]types.AttributeValue{":amount": &types.AttributeValueMemberN{Value: "3"}}
+	err := NewWriteTransaction(
+		samples.TransactPut(&sampleRecord{ID: "new", Part: 1, Name: "created"}, Insert),
+		samples.TransactDelete(&sampleRecord{ID: "gone"}, Update),
+		accounts.TransactUpdate(&accountRecord{Account: "a"}, "SET balance = balance - :amount", nil, decrement, Update),
+	).Execute()
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	account := &accountRecord{Account: "a"}
+	if err := accounts.GetItem(account); err != nil || account.Balance != 7 {
+		t.Errorf("GetItem() = %v, %v, want balance 7", account, err)
+	}
+	if items := db.TableItems("samples"); len(items) != 1 {
+		t.Errorf("samples has %v items, want 1", len(items))
+	}
+
+	err = NewWriteTransaction().
+		Add(samples.TransactPut(&sampleRecord{ID: "other", Name: "skipped"}, Insert)).
+		Add(samples.TransactPut(&sampleRecord{ID: "new", Part: 1, Name: "duplicate"}, Insert)).
+		Add(accounts.TransactConditionCheck(&accountRecord{Account: "missing"}, Update)).
+		Execute()
+	var txErr *TransactionError
+	if !errors.As(err, &txErr) || len(txErr.Failures) != 2 {
+		t.Fatalf("Execute() error = %v, want two failures", err)
+	}
+	if f := txErr.Failures[0]; f.Index != 1 || f.Table != "samples" || f.Code != "ConditionalCheckFailed" || f.Item == nil {
+		t.Errorf("Execute() first failure = %+v", f)
+	}
+	if f := txErr.Failures[1]; f.Index != 2 || f.Operation != "ConditionCheck" || f.Table != "accounts" || f.Item != nil {
+		t.Errorf("Execute() second failure = %+v", f)
+	}
+	var cancelled *types.TransactionCanceledException
+	if !errors.As(err, &cancelled) {
+		t.Errorf("Execute() error = %v, want it to wrap the cancellation", err)
+	} else if !errors.Is(err, ErrConditionFailed) {
+		t.Errorf("Execute() error = %v, want %v", err, ErrConditionFailed)
+	}
+	if items := db.TableItems("samples"); len(items) != 1 {
+		t.Errorf("cancelled transaction wrote %v items, want 1", len(items))
+	}
+
+	if err := NewWriteTransaction(samples.TransactPut(nil, Replace)).Execute(); err == nil {
+		t.Errorf("Execute() with a broken entry succeeded")
+	}
+	other := accounts.WithDynamoDbApi(ddbrepotest.NewMemoryDynamoDb())
+	err = NewWriteTransaction(samples.TransactPut(&sampleRecord{ID: "x", Name: "x"}, Replace), other.TransactDelete(&accountRecord{Account: "a"}, Replace)).Execute()
+	if err == nil || len(db.TableItems("samples")) != 1 {
+		t.Errorf("Execute() across clients = %v, want an error", err)
+	}
+	if err := NewReadTransaction(samples.TransactGet(&sampleRecord{ID: "new", Part: 1}), other.TransactGet(&accountRecord{Account: "a"})).Execute(); err == nil {
+		t.Errorf("Execute() of reads across clients succeeded")
+	}
+}
+
+func TestReadTransaction_Execute(t *testing.T) {
+	db := ddbrepotest.NewMemoryDynamoDb()
+	samples := newTestRepo(t, db, "samples", &sampleRecord{ID: "s", Part: 2, Name: "sample"})
+	accounts := newTestRepo(t, db, "accounts", &accountRecord{Account: "a", Balance: 10})
+	account, sample, missing := &accountRecord{Account: "a"}, &sampleRecord{ID: "s", Part: 2}, &sampleRecord{ID: "m"}
+	err := NewReadTransaction(accounts.TransactGet(account), samples.TransactGet(sample), samples.TransactGet(missing)).Execute()
+	var txErr *TransactionError
+	if !errors.As(err, &txErr) || len(txErr.Failures) != 1 || txErr.Failures[0].Index != 2 || txErr.Failures[0].Code != "NotFound" {
+		t.Errorf("Execute() error = %v, want the missing item reported", err)
+	} else if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Execute() error = %v, want ErrNotFound", err)
+	}
+	if account.Balance != 10 || sample.Name != "sample" {
+		t.Errorf("Execute() loaded %v and %v", account, sample)
+	}
+}
@@ -7,6 +7,13 @@ import (
 )
 
 func (repo DdbRepo[RecordType]) DelItemOp(record *RecordType) error {
+	return repo.DelItemOpCtx(context.TODO(), record)
+}
+
+func (repo DdbRepo[RecordType]) DelItemOpCtx(ctx context.Context, record *RecordType) error {
+	if repo.isVersioned() {
+		return repo.deleteVersioned(ctx, record)
+	}
 	if key, err := MarshalKey(&repo, record, ""); err != nil {
 		return err
 	} else {
@@ -14,7 +21,7 @@ func (repo DdbRepo[RecordType]) DelItemOp(record *RecordType) error {
 			TableName: aws.String(repo.tableName),
 			Key:       key,
 		}
-		_, err := repo.ddbClient.DeleteItem(context.TODO(), input)
-		return err
+		_, err := repo.ddbClient.DeleteItem(ctx, input)
+		return opError("DeleteItem", repo.tableName, key, err)
 	}
 }
@@ -8,6 +8,10 @@ import (
 )
 
 func (repo DdbRepo[RecordType]) GetItem(record *RecordType) error {
+	return repo.GetItemCtx(context.TODO(), record)
+}
+
+func (repo DdbRepo[RecordType]) GetItemCtx(ctx context.Context, record *RecordType) error {
 	if record == nil {
 		return errors.New("record pointer is required")
 	}
@@ -21,11 +25,11 @@ func (repo DdbRepo[RecordType]) GetItem(record *RecordType) error {
 			TableName: aws.String(repo.tableName),
 			Key:       key,
 		}
-		if output, err := repo.ddbClient.GetItem(context.TODO(), input); err != nil {
-			return err
+		if output, err := repo.ddbClient.GetItem(ctx, input); err != nil {
+			return opError("GetItem", repo.tableName, key, err)
 		} else {
 			if output.Item == nil {
-				return errors.New("item not found in " + repo.tableName)
+				return notFoundError("GetItem", repo.tableName, key)
 			} else {
 				return Unmarshal(&repo, record, output.Item)
 			}
@@ -17,6 +17,10 @@ type PutWorkflowColumns interface {
 	ExpirationFieldName() (string, bool)
 }
 
+type expirationEncoder interface {
+	ExpirationValue(at time.Time) (types.AttributeValue, error)
+}
+
 type PutItemOp func(
 	repo PutWorkflowColumns,
 	entry map[string]types.AttributeValue,
@@ -27,7 +31,14 @@ type PutItemOp func(
 )
 
 func (repo DdbRepo[RecordType]) PutItem(entry *RecordType) error {
-	return repo.PutItemOp(entry, Replace)
+	return repo.PutItemCtx(context.TODO(), entry)
+}
+
+func (repo DdbRepo[RecordType]) PutItemCtx(ctx context.Context, entry *RecordType) error {
+	if repo.isVersioned() {
+		return repo.putVersioned(ctx, entry)
+	}
+	return repo.PutItemOpCtx(ctx, entry, Replace)
 }
 
 func Replace(repo PutWorkflowColumns, entry map[string]types.AttributeValue) (string, map[string]types.AttributeValue, error) {
@@ -50,8 +61,14 @@ func InsertOrReplaceExpired(repo PutWorkflowColumns, entry map[string]types.Attr
 		values := make(map[string]types.AttributeValue)
 		if expname, ok := repo.ExpirationFieldName(); ok {
 			cond += fmt.Sprintf(" or (%v < :%v)", expname, expname)
-			values[":"+expname] = &types.AttributeValueMemberN{
-				Value: fmt.Sprintf("%v", time.Now().Unix()),
+			if encoder, ok := repo.(expirationEncoder); ok {
+				if values[":"+expname], err = encoder.ExpirationValue(time.Now()); err != nil {
+					return "", nil, err
+				}
+			} else {
+				values[":"+expname] = &types.AttributeValueMemberN{
+					Value: fmt.Sprintf("%v", time.Now().Unix()),
+				}
 			}
 		}
 		return cond, values, nil
@@ -111,6 +128,10 @@ func incrementNumericValueBy(value types.AttributeValue, increment int) (types.A
 }
 
 func (repo DdbRepo[RecordType]) PutItemOp(entry *RecordType, op PutItemOp) error {
+	return repo.PutItemOpCtx(context.TODO(), entry, op)
+}
+
+func (repo DdbRepo[RecordType]) PutItemOpCtx(ctx context.Context, entry *RecordType, op PutItemOp) error {
 	item, err := Marshal(&repo, entry)
 	if err != nil {
 		return err
@@ -127,8 +148,8 @@ func (repo DdbRepo[RecordType]) PutItemOp(entry *RecordType, op PutItemOp) error
 		input.ConditionExpression = aws.String(condStr)
 	}
 	input.ExpressionAttributeValues = param
-	_, err = repo.ddbClient.PutItem(context.TODO(), input)
-	return err
+	_, err = repo.ddbClient.PutItem(ctx, input)
+	return opError("PutItem", repo.tableName, keyOfItem(repo.keySchema, item), err)
 }
 
 func AttributeExists(attrName string) string {
@@ -140,6 +161,10 @@ func AttributeNotExists(attrName string) string {
 }
 
 func (repo DdbRepo[RecordType]) PutConditional(entry *RecordType, condition string, conditionValues map[string]types.AttributeValue) error {
+	return repo.PutConditionalCtx(context.TODO(), entry, condition, conditionValues)
+}
+
+func (repo DdbRepo[RecordType]) PutConditionalCtx(ctx context.Context, entry *RecordType, condition string, conditionValues map[string]types.AttributeValue) error {
 	item, err := Marshal(&repo, entry)
 	if err != nil {
 		return err
@@ -150,6 +175,6 @@ func (repo DdbRepo[RecordType]) PutConditional(entry *RecordType, condition stri
 		ConditionExpression:       aws.String(condition),
 		ExpressionAttributeValues: conditionValues,
 	}
-	_, err = repo.ddbClient.PutItem(context.TODO(), input)
-	return err
+	_, err = repo.ddbClient.PutItem(ctx, input)
+	return opError("PutItem", repo.tableName, keyOfItem(repo.keySchema, item), err)
 }
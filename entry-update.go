@@ -0,0 +1,427 @@
+package ddbrepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+const (
+	updateSet    = "SET"
+	updateRemove = "REMOVE"
+	updateAdd    = "ADD"
+	updateDelete = "DELETE"
+)
+
+var updateClauses = []string{updateSet, updateRemove, updateAdd, updateDelete}
+
+var pathSegmentPattern = regexp.MustCompile(`^([^\[\]]+)((?:\[\d+\])*)$`)
+
+// UpdateBuilder collects the actions of an UpdateItem request; fields are
+// referred to by Go field name or attribute name and the placeholders of the
+// expression are generated.
+type UpdateBuilder struct {
+	props        parseProps
+	record       reflect.Type
+	fields       map[string]*fieldSpec
+	hashKey      string
+	actions      map[string][]string
+	names        map[string]string
+	values       map[string]types.AttributeValue
+	conditions   []string
+	returnValues types.ReturnValue
+	invalid      fieldErrors
+	err          error
+}
+
+func (repo DdbRepo[T]) NewUpdate() *UpdateBuilder {
+	update := &UpdateBuilder{
+		props:   &repo,
+		record:  reflect.TypeOf((*T)(nil)).Elem(),
+		actions: make(map[string][]string),
+		names:   make(map[string]string),
+		values:  make(map[string]types.AttributeValue),
+	}
+	update.hashKey, update.err = repo.HashKeyName()
+	var sample T
+	if fields, err := fieldSpecsOf(&repo, &sample); err != nil {
+		update.fail(err)
+	} else {
+		update.fields = fields
+	}
+	return update
+}
+
+func (u *UpdateBuilder) clone() *UpdateBuilder {
+	result := *u
+	result.actions = make(map[string][]string, len(u.actions))
+	for clause, actions := range u.actions {
+		result.actions[clause] = append([]string(nil), actions...)
+	}
+	result.names = make(map[string]string, len(u.names))
+	for k, v := range u.names {
+		result.names[k] = v
+	}
+	result.values = make(map[string]types.AttributeValue, len(u.values))
+	for k, v := range u.values {
+		result.values[k] = v
+	}
+	result.conditions = append([]string(nil), u.conditions...)
+	result.invalid = append(fieldErrors(nil), u.invalid...)
+	return &result
+}
+
+func (u *UpdateBuilder) fail(err error) *UpdateBuilder {
+	if u.err == nil {
+		u.err = err
+	}
+	return u
+}
+
+func (u *UpdateBuilder) name(attribute string) string {
+	for placeholder, name := range u.names {
+		if name == attribute {
+			return placeholder
+		}
+	}
+	placeholder := fmt.Sprintf("#f%v", len(u.names))
+	u.names[placeholder] = attribute
+	return placeholder
+}
+
+func (u *UpdateBuilder) value(value types.AttributeValue) string {
+	placeholder := fmt.Sprintf(":v%v", len(u.values))
+	for i := len(u.values) + 1; u.values[placeholder] != nil; i++ {
+		placeholder = fmt.Sprintf(":v%v", i)
+	}
+	u.values[placeholder] = value
+	return placeholder
+}
+
+func (u *UpdateBuilder) marshal(field string, value interface{}) (string, error) {
+	encoding := timeEncodingOf(u.props, u.fields[field])
+	if av, ok := value.(types.AttributeValue); ok {
+		return u.value(av), nil
+	} else if av, err := marshalValue(u.props, field, encoding, reflect.ValueOf(value), &u.invalid); err != nil {
+		return "", err
+	} else {
+		return u.value(av), nil
+	}
+}
+
+func marshalSet(value interface{}) (types.AttributeValue, error) {
+	av, err := attributevalue.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	list, ok := av.(*types.AttributeValueMemberL)
+	if !ok {
+		return av, nil
+	} else if len(list.Value) == 0 {
+		return nil, errors.New("set must not be empty")
+	}
+	switch list.Value[0].(type) {
+	case *types.AttributeValueMemberS:
+		result := &types.AttributeValueMemberSS{}
+		for _, e := range list.Value {
+			if s, ok := e.(*types.AttributeValueMemberS); ok {
+				result.Value = append(result.Value, s.Value)
+			}
+		}
+		return result, nil
+	case *types.AttributeValueMemberN:
+		result := &types.AttributeValueMemberNS{}
+		for _, e := range list.Value {
+			if n, ok := e.(*types.AttributeValueMemberN); ok {
+				result.Value = append(result.Value, n.Value)
+			}
+		}
+		return result, nil
+	case *types.AttributeValueMemberB:
+		result := &types.AttributeValueMemberBS{}
+		for _, e := range list.Value {
+			if b, ok := e.(*types.AttributeValueMemberB); ok {
+				result.Value = append(result.Value, b.Value)
+			}
+		}
+		return result, nil
+	}
+	return nil, fmt.Errorf("%T can't be used as a set", value)
+}
+
+func (u *UpdateBuilder) path(field string, forUpdate bool) (string, error) {
+	segments := strings.Split(field, ".")
+	result := make([]string, 0, len(segments))
+	current := u.record
+	for i, segment := range segments {
+		match := pathSegmentPattern.FindStringSubmatch(segment)
+		if match == nil {
+			return "", fmt.Errorf("malformed field path %v", field)
+		}
+		name := match[1]
+		if i == 0 {
+			if spec, found := u.fields[name]; !found {
+				return "", fmt.Errorf("unknown field %v", name)
+			} else if forUpdate && spec.IsKey() {
+				return "", fmt.Errorf("key field %v can't be updated", name)
+			}
+		}
+		name, current = nestedField(u.props, current, name)
+		for range strings.Count(match[2], "[") {
+			current = elementType(current)
+		}
+		result = append(result, u.name(name)+match[2])
+	}
+	return strings.Join(result, "."), nil
+}
+
+func (u *UpdateBuilder) action(clause string, field string, format string, values ...interface{}) *UpdateBuilder {
+	if u.err != nil {
+		return u
+	}
+	path, err := u.path(field, true)
+	if err != nil {
+		return u.fail(err)
+	}
+	args := []interface{}{path}
+	for _, value := range values {
+		if placeholder, err := u.marshal(field, value); err != nil {
+			return u.fail(fmt.Errorf("%v: %w", field, err))
+		} else {
+			args = append(args, placeholder)
+		}
+	}
+	u.actions[clause] = append(u.actions[clause], fmt.Sprintf(format, args...))
+	return u
+}
+
+func (u *UpdateBuilder) setAction(clause string, field string, value interface{}) *UpdateBuilder {
+	if set, err := marshalSet(value); err != nil {
+		return u.fail(fmt.Errorf("%v: %w", field, err))
+	} else {
+		return u.action(clause, field, "%[1]v %[2]v", set)
+	}
+}
+
+func (u *UpdateBuilder) require(field string, message string) {
+	if spec, found := u.fields[field]; found && spec.IsRequired() {
+		u.invalid.add(field, spec, TagItemRequired, message)
+	}
+}
+
+func (u *UpdateBuilder) Set(field string, value interface{}) *UpdateBuilder {
+	if isZeroValue(value) {
+		u.require(field, "is required")
+	} else if spec, found := u.fields[field]; found {
+		if _, raw := value.(types.AttributeValue); !raw {
+			spec.validateValue(field, reflect.ValueOf(value), &u.invalid)
+		}
+	}
+	return u.action(updateSet, field, "%[1]v = %[2]v", value)
+}
+
+func (u *UpdateBuilder) SetIfNotExists(field string, value interface{}) *UpdateBuilder {
+	return u.action(updateSet, field, "%[1]v = if_not_exists(%[1]v, %[2]v)", value)
+}
+
+// Increment adds delta to a numeric field, treating a missing field as zero.
+func (u *UpdateBuilder) Increment(field string, delta interface{}) *UpdateBuilder {
+	return u.action(updateSet, field, "%[1]v = if_not_exists(%[1]v, %[3]v) + %[2]v", delta, 0)
+}
+
+// ListAppend appends the elements of the values slice to a list field,
+// creating the list when it is missing.
+func (u *UpdateBuilder) ListAppend(field string, values interface{}) *UpdateBuilder {
+	empty := &types.AttributeValueMemberL{Value: []types.AttributeValue{}}
+	return u.action(updateSet, field, "%[1]v = list_append(if_not_exists(%[1]v, %[3]v), %[2]v)", values, empty)
+}
+
+func (u *UpdateBuilder) Remove(field string) *UpdateBuilder {
+	u.require(field, "is required and can't be removed")
+	return u.action(updateRemove, field, "%[1]v")
+}
+
+// Add adds a number to a numeric field or the elements of a slice to a set.
+func (u *UpdateBuilder) Add(field string, value interface{}) *UpdateBuilder {
+	return u.setAction(updateAdd, field, value)
+}
+
+// Delete removes the elements of a slice from a set.
+func (u *UpdateBuilder) Delete(field string, value interface{}) *UpdateBuilder {
+	return u.setAction(updateDelete, field, value)
+}
+
+// Condition adds a raw condition; conditions are combined with AND.
+func (u *UpdateBuilder) Condition(condition string, values map[string]types.AttributeValue) *UpdateBuilder {
+	if u.err != nil {
+		return u
+	}
+	for k, v := range values {
+		if _, found := u.values[k]; found {
+			return u.fail(fmt.Errorf("expression value %v is defined twice", k))
+		}
+		u.values[k] = v
+	}
+	u.conditions = append(u.conditions, condition)
+	return u
+}
+
+func (u *UpdateBuilder) IfExists() *UpdateBuilder {
+	if u.err != nil {
+		return u
+	}
+	u.conditions = append(u.conditions, AttributeExists(u.name(u.hashKey)))
+	return u
+}
+
+func (u *UpdateBuilder) IfNotExists() *UpdateBuilder {
+	if u.err != nil {
+		return u
+	}
+	u.conditions = append(u.conditions, AttributeNotExists(u.name(u.hashKey)))
+	return u
+}
+
+func (u *UpdateBuilder) IfEquals(field string, value interface{}) *UpdateBuilder {
+	if u.err != nil {
+		return u
+	}
+	path, err := u.path(field, false)
+	if err != nil {
+		return u.fail(err)
+	}
+	if placeholder, err := u.marshal(field, value); err != nil {
+		return u.fail(fmt.Errorf("%v: %w", field, err))
+	} else {
+		u.conditions = append(u.conditions, fmt.Sprintf("%v = %v", path, placeholder))
+	}
+	return u
+}
+
+// Return requests the item attributes to be returned by UpdateItem and
+// unmarshalled into the record.
+func (u *UpdateBuilder) Return(returnValues types.ReturnValue) *UpdateBuilder {
+	u.returnValues = returnValues
+	return u
+}
+
+func (u *UpdateBuilder) Expression() (update string, condition string, err error) {
+	if u.err != nil {
+		return "", "", u.err
+	} else if err := u.invalid.err(); err != nil {
+		return "", "", err
+	}
+	clauses := make([]string, 0, len(updateClauses))
+	for _, clause := range updateClauses {
+		if actions := u.actions[clause]; len(actions) > 0 {
+			clauses = append(clauses, clause+" "+strings.Join(actions, ", "))
+		}
+	}
+	if len(clauses) == 0 {
+		return "", "", errors.New("update has no actions")
+	}
+	conditions := make([]string, 0, len(u.conditions))
+	for _, c := range u.conditions {
+		if len(u.conditions) > 1 {
+			c = "(" + c + ")"
+		}
+		conditions = append(conditions, c)
+	}
+	return strings.Join(clauses, " "), strings.Join(conditions, " AND "), nil
+}
+
+func (repo DdbRepo[RecordType]) UpdateItem(record *RecordType, update *UpdateBuilder) error {
+	return repo.UpdateItemCtx(context.TODO(), record, update)
+}
+
+// UpdateItemCtx applies the update to the item with the key of record; the
+// attributes requested with Return are unmarshalled into record.
+func (repo DdbRepo[RecordType]) UpdateItemCtx(ctx context.Context, record *RecordType, update *UpdateBuilder) error {
+	if record == nil {
+		return errors.New("record pointer is required")
+	}
+	if err := repo.validateConfig(); err != nil {
+		return err
+	}
+	var version *versionField
+	var expected int64
+	if repo.isVersioned() {
+		var err error
+		if update, version, expected, err = repo.versionUpdate(record, update); err != nil {
+			return err
+		}
+	}
+	updateExpr, condition, err := update.Expression()
+	if err != nil {
+		return err
+	}
+	key, err := MarshalKey(&repo, record, "")
+	if err != nil {
+		return err
+	}
+	input := &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(repo.tableName),
+		Key:                       key,
+		UpdateExpression:          aws.String(updateExpr),
+		ExpressionAttributeNames:  nonEmpty(update.names),
+		ExpressionAttributeValues: nonEmpty(update.values),
+		ReturnValues:              update.returnValues,
+	}
+	if condition != "" {
+		input.ConditionExpression = aws.String(condition)
+	}
+	if version != nil {
+		input.ReturnValuesOnConditionCheckFailure = types.ReturnValuesOnConditionCheckFailureAllOld
+	}
+	output, err := repo.ddbClient.UpdateItem(ctx, input)
+	if err != nil {
+		if version != nil {
+			err = repo.versionConflict(err, expected)
+		}
+		return opError("UpdateItem", repo.tableName, key, err)
+	}
+	if version != nil {
+		version.set(expected + 1)
+	}
+	if len(output.Attributes) > 0 {
+		if update.returnValues != types.ReturnValueAllOld && update.returnValues != types.ReturnValueAllNew {
+			// updated attributes are a partial item
+			repo.strictUnmarshal = false
+		}
+		return Unmarshal(&repo, record, output.Attributes)
+	}
+	return nil
+}
+
+func (repo DdbRepo[T]) TransactUpdateItem(record *T, update *UpdateBuilder) TransactWriteEntry {
+	entry := TransactWriteEntry{client: repo.ddbClient, operation: "Update", table: repo.tableName}
+	if record == nil {
+		entry.err = errors.New("record pointer is required")
+	} else if err := repo.validateConfig(); err != nil {
+		entry.err = err
+	} else if updateExpr, condition, err := update.Expression(); err != nil {
+		entry.err = err
+	} else if entry.key, err = MarshalKey(&repo, record, ""); err != nil {
+		entry.err = err
+	} else {
+		entry.item.Update = &types.Update{
+			TableName:                           aws.String(repo.tableName),
+			Key:                                 entry.key,
+			UpdateExpression:                    aws.String(updateExpr),
+			ExpressionAttributeNames:            nonEmpty(update.names),
+			ExpressionAttributeValues:           nonEmpty(update.values),
+			ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+		}
+		if condition != "" {
+			entry.item.Update.ConditionExpression = aws.String(condition)
+		}
+	}
+	return entry
+}
@@ -0,0 +1,113 @@
+package ddbrepo
+
+import (
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/rotmistrk/ddbrepo/ddbrepotest"
+	"github.com/rotmistrk/must"
+	"reflect"
+	"testing"
+)
+
+type profileRecord struct {
+	User   string   `ddb:"user,hash-key"`
+	Name   string   `ddb:"name"`
+	Visits int      `ddb:"visits"`
+	Tags   []string `ddb:"tags"`
+	Log    []string `ddb:"log"`
+}
+
+func TestUpdateBuilder_Expression(t *testing.T) {
+	repo := must.Must(New[profileRecord]())
+	tests := []struct {
+		name          string
+		update        *UpdateBuilder
+		wantUpdate    string
+		wantCondition string
+		wantNames     map[string]string
+		wantErr       bool
+	}{
+		{
+			name:       "set by go and attribute name",
+			update:     repo.NewUpdate().Set("Name", "x").Set("visits", 1),
+			wantUpdate: "SET #f0 = :v0, #f1 = :v1",
+			wantNames:  map[string]string{"#f0": "name", "#f1": "visits"},
+		},
+		{
+			name:          "all clauses with condition",
+			update:        repo.NewUpdate().Remove("Name").Increment("Visits", 2).Add("Tags", []string{"a"}).Delete("Log", []string{"b"}).IfExists(),
+			wantUpdate:    "SET #f1 = if_not_exists(#f1, :v1) + :v0 REMOVE #f0 ADD #f2 :v2 DELETE #f3 :v3",
+			wantCondition: "attribute_exists(#f4)",
+			wantNames:     map[string]string{"#f0": "name", "#f1": "visits", "#f2": "tags", "#f3": "log", "#f4": "user"},
+		},
+		{
+			name:       "nested path",
+			update:     repo.NewUpdate().Set("Log[2]", "entry"),
+			wantUpdate: "SET #f0[2] = :v0",
+			wantNames:  map[string]string{"#f0": "log"},
+		},
+		{
+			name:    "unknown field",
+			update:  repo.NewUpdate().Set("Missing", 1),
+			wantErr: true,
+		},
+		{
+			name:    "key field",
+			update:  repo.NewUpdate().Set("User", "other"),
+			wantErr: true,
+		},
+		{
+			name:    "no actions",
+			update:  repo.NewUpdate().IfExists(),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			update, condition, err := tt.update.Expression()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expression() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if update != tt.wantUpdate || condition != tt.wantCondition {
+				t.Errorf("Expression() = %q, %q, want %q, %q", update, condition, tt.wantUpdate, tt.wantCondition)
+			}
+			if !reflect.DeepEqual(tt.update.names, tt.wantNames) {
+				t.Errorf("Expression() names = %v, want %v", tt.update.names, tt.wantNames)
+			}
+		})
+	}
+}
+
+func TestDdbRepo_UpdateItem(t *testing.T) {
+	db := ddbrepotest.NewMemoryDynamoDb()
+	repo := must.Must(New[profileRecord]()).WithTableName("profiles").WithDynamoDbApi(db)
+	if err := repo.TableCreate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.UpdateItem(&profileRecord{User: "u"}, repo.NewUpdate().Set("Name", "x").IfExists()); err == nil {
+		t.Errorf("UpdateItem() on a missing item succeeded")
+	}
+	record := &profileRecord{User: "u"}
+	update := repo.NewUpdate().SetIfNotExists("Name", "first").Increment("Visits", 1).Add("Tags", []string{"a", "b"}).ListAppend("Log", []string{"created"}).Return(types.ReturnValueAllNew)
+	if err := repo.UpdateItem(record, update); err != nil {
+		t.Fatalf("UpdateItem() error = %v", err)
+	}
+	want := profileRecord{User: "u", Name: "first", Visits: 1, Tags: []string{"a", "b"}, Log: []string{"created"}}
+	if !reflect.DeepEqual(*record, want) {
+		t.Errorf("UpdateItem() returned %v, want %v", *record, want)
+	}
+	record = &profileRecord{User: "u"}
+	update = repo.NewUpdate().SetIfNotExists("Name", "second").Increment("Visits", 1).Delete("Tags", []string{"a"}).ListAppend("Log", []string{"updated"}).IfEquals("Visits", 1).Return(types.ReturnValueAllNew)
+	if err := repo.UpdateItem(record, update); err != nil {
+		t.Fatalf("UpdateItem() error = %v", err)
+	}
+	want = profileRecord{User: "u", Name: "first", Visits: 2, Tags: []string{"b"}, Log: []string{"created", "updated"}}
+	if !reflect.DeepEqual(*record, want) {
+		t.Errorf("UpdateItem() returned %v, want %v", *record, want)
+	}
+	if err := repo.UpdateItem(record, repo.NewUpdate().Remove("Log").IfEquals("Visits", 1)); err == nil {
+		t.Errorf("UpdateItem() with a failing condition succeeded")
+	}
+}
@@ -0,0 +1,205 @@
+package ddbrepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"reflect"
+	"strconv"
+)
+
+// VersionConflictError is returned by versioned writes when the stored item
+// has another version; Current is the stored item, nil if there is none.
+type VersionConflictError[T any] struct {
+	Table    string
+	Expected int64
+	Current  *T
+	Err      error
+}
+
+func (e *VersionConflictError[T]) Error() string {
+	if e.Current == nil {
+		return fmt.Sprintf("%v on %v: expected version %v, item does not exist", ErrVersionConflict, e.Table, e.Expected)
+	}
+	return fmt.Sprintf("%v on %v: expected version %v", ErrVersionConflict, e.Table, e.Expected)
+}
+
+func (e *VersionConflictError[T]) Is(target error) bool {
+	return target == ErrVersionConflict || target == ErrConditionFailed
+}
+
+func (e *VersionConflictError[T]) Unwrap() error {
+	return e.Err
+}
+
+// WithOptimisticLocking makes PutItem, UpdateItem and DelItemOp condition on
+// the version field of the record and bump it on success.
+func (repo DdbRepo[T]) WithOptimisticLocking(enabled bool) *DdbRepo[T] {
+	repo.optimisticLocking = enabled
+	return &repo
+}
+
+func (repo *DdbRepo[T]) isVersioned() bool {
+	return repo.optimisticLocking && repo.versionColumn != ""
+}
+
+type versionField struct {
+	name  string
+	value reflect.Value
+}
+
+func (repo *DdbRepo[T]) versionField(record *T) (*versionField, error) {
+	var result *versionField
+	codec, err := codecOf(repo, reflect.TypeOf(record).Elem())
+	if err != nil {
+		return nil, err
+	}
+	for i := range codec.fields {
+		if field := &codec.fields[i]; field.spec.IsVersionField() {
+			if value, found := field.value(reflect.ValueOf(record).Elem(), true); found {
+				result = &versionField{name: field.spec.name, value: value}
+			}
+		}
+	}
+	if result == nil {
+		return nil, errors.New("no version column defined")
+	}
+	switch result.value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return result, nil
+	default:
+		return nil, fmt.Errorf("version field %v must be an integer, not %v", result.name, result.value.Type())
+	}
+}
+
+func (v *versionField) get() int64 {
+	if v.value.CanInt() {
+		return v.value.Int()
+	}
+	return int64(v.value.Uint())
+}
+
+func (v *versionField) set(version int64) {
+	if v.value.CanInt() {
+		v.value.SetInt(version)
+	} else {
+		v.value.SetUint(uint64(version))
+	}
+}
+
+func versionValue(version int64) types.AttributeValue {
+	return &types.AttributeValueMemberN{Value: strconv.FormatInt(version, 10)}
+}
+
+func (repo *DdbRepo[T]) versionCondition(field *versionField, expected int64) (string, map[string]string, map[string]types.AttributeValue, error) {
+	if expected == 0 {
+		keyName, err := repo.HashKeyName()
+		return "attribute_not_exists(#key)", map[string]string{"#key": keyName}, nil, err
+	}
+	names := map[string]string{"#version": field.name}
+	values := map[string]types.AttributeValue{":version": versionValue(expected)}
+	return "#version = :version", names, values, nil
+}
+
+func (repo *DdbRepo[T]) versionConflict(err error, expected int64) error {
+	var failed *types.ConditionalCheckFailedException
+	if !errors.As(err, &failed) {
+		return err
+	}
+	result := &VersionConflictError[T]{Table: repo.tableName, Expected: expected, Err: err}
+	if len(failed.Item) > 0 {
+		result.Current = new(T)
+		if err := Unmarshal(repo, result.Current, failed.Item); err != nil {
+			return errors.Join(result, err)
+		}
+	}
+	return result
+}
+
+func (repo DdbRepo[T]) putVersioned(ctx context.Context, record *T) error {
+	if record == nil {
+		return errors.New("record pointer is required")
+	}
+	field, err := repo.versionField(record)
+	if err != nil {
+		return err
+	}
+	expected := field.get()
+	item, err := Marshal(&repo, record)
+	if err != nil {
+		return err
+	}
+	item[field.name] = versionValue(expected + 1)
+	condition, names, values, err := repo.versionCondition(field, expected)
+	if err != nil {
+		return err
+	}
+	input := &dynamodb.PutItemInput{
+		TableName:                           aws.String(repo.tableName),
+		Item:                                item,
+		ConditionExpression:                 aws.String(condition),
+		ExpressionAttributeNames:            names,
+		ExpressionAttributeValues:           values,
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	}
+	if _, err := repo.ddbClient.PutItem(ctx, input); err != nil {
+		return opError("PutItem", repo.tableName, keyOfItem(repo.keySchema, item), repo.versionConflict(err, expected))
+	}
+	field.set(expected + 1)
+	return nil
+}
+
+func (repo DdbRepo[T]) deleteVersioned(ctx context.Context, record *T) error {
+	if record == nil {
+		return errors.New("record pointer is required")
+	}
+	field, err := repo.versionField(record)
+	if err != nil {
+		return err
+	}
+	key, err := MarshalKey(&repo, record, "")
+	if err != nil {
+		return err
+	}
+	expected := field.get()
+	input := &dynamodb.DeleteItemInput{
+		TableName:                           aws.String(repo.tableName),
+		Key:                                 key,
+		ConditionExpression:                 aws.String("#version = :version"),
+		ExpressionAttributeNames:            map[string]string{"#version": field.name},
+		ExpressionAttributeValues:           map[string]types.AttributeValue{":version": versionValue(expected)},
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	}
+	if _, err := repo.ddbClient.DeleteItem(ctx, input); err != nil {
+		return opError("DeleteItem", repo.tableName, key, repo.versionConflict(err, expected))
+	}
+	return nil
+}
+
+func (repo *DdbRepo[T]) versionUpdate(record *T, update *UpdateBuilder) (*UpdateBuilder, *versionField, int64, error) {
+	field, err := repo.versionField(record)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	for _, name := range update.names {
+		if name == field.name {
+			return nil, nil, 0, fmt.Errorf("version field %v is managed by optimistic locking", name)
+		}
+	}
+	expected := field.get()
+	result := update.clone()
+	if expected == 0 {
+		result.IfNotExists()
+	} else {
+		result.IfEquals(field.name, expected)
+	}
+	result.Set(field.name, expected+1)
+	if result.err != nil {
+		return nil, nil, 0, result.err
+	}
+	return result, field, expected, nil
+}
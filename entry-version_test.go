@@ -0,0 +1,61 @@
+package ddbrepo
+
+import (
+	"errors"
+	"github.com/rotmistrk/ddbrepo/ddbrepotest"
+	"github.com/rotmistrk/must"
+	"testing"
+)
+
+func TestDdbRepo_OptimisticLocking(t *testing.T) {
+	db := ddbrepotest.NewMemoryDynamoDb()
+	repo := must.Must(New[sampleRecord]()).WithTableName("versioned").WithDynamoDbApi(db).WithOptimisticLocking(true)
+	if err := repo.TableCreate(); err != nil {
+		t.Fatal(err)
+	}
+	record := &sampleRecord{ID: "a", Name: "first"}
+	if err := repo.PutItem(record); err != nil || record.Version != 1 {
+		t.Fatalf("PutItem() = %v, version %v, want version 1", err, record.Version)
+	}
+	stale := *record
+	if err := repo.PutItem(&sampleRecord{ID: "a", Name: "again"}); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("second insert error = %v, want version conflict", err)
+	}
+	record.Name = "second"
+	if err := repo.PutItem(record); err != nil || record.Version != 2 {
+		t.Fatalf("PutItem() = %v, version %v, want version 2", err, record.Version)
+	}
+	stale.Name = "lost update"
+	err := repo.PutItem(&stale)
+	var conflict *VersionConflictError[sampleRecord]
+	if !errors.As(err, &conflict) || conflict.Expected != 1 || conflict.Current == nil || conflict.Current.Name != "second" {
+		t.Fatalf("stale PutItem() error = %v, want conflict carrying the stored item", err)
+	}
+	if stale.Version != 1 {
+		t.Errorf("failed PutItem() bumped version to %v", stale.Version)
+	}
+
+	if err := repo.UpdateItem(&stale, repo.NewUpdate().Set("Name", "stale update")); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("stale UpdateItem() error = %v, want version conflict", err)
+	}
+	if err := repo.UpdateItem(record, repo.NewUpdate().Set("Name", "third")); err != nil || record.Version != 3 {
+		t.Fatalf("UpdateItem() = %v, version %v, want version 3", err, record.Version)
+	}
+	if err := repo.UpdateItem(record, repo.NewUpdate().Set("Version", 10)); err == nil {
+		t.Errorf("UpdateItem() of the version field succeeded")
+	}
+	stored := &sampleRecord{ID: "a"}
+	if err := repo.GetItem(stored); err != nil || stored.Version != 3 || stored.Name != "third" {
+		t.Errorf("GetItem() = %v, %v", stored, err)
+	}
+
+	if err := repo.DelItemOp(&stale); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("stale DelItemOp() error = %v, want version conflict", err)
+	}
+	if err := repo.DelItemOp(record); err != nil {
+		t.Errorf("DelItemOp() error = %v", err)
+	}
+	if err := repo.DelItemOp(record); !errors.As(err, &conflict) || conflict.Current != nil {
+		t.Errorf("DelItemOp() of a missing item error = %v, want conflict without current item", err)
+	}
+}
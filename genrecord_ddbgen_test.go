@@ -0,0 +1,336 @@
+// Code generated by ddbrepo-gen. DO NOT EDIT.
+
+package ddbrepo
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"strconv"
+	"time"
+)
+
+// Attribute names of genRecord.
+const (
+	genRecordAttrTenant   = "tenant"
+	genRecordAttrCreated  = "created"
+	genRecordAttrOwner    = "owner"
+	genRecordAttrStatus   = "status"
+	genRecordAttrVersion  = "version"
+	genRecordAttrTitle    = "title"
+	genRecordAttrNote     = "note"
+	genRecordAttrCount    = "count"
+	genRecordAttrActive   = "active"
+	genRecordAttrPriority = "priority"
+	genRecordAttrRatio    = "ratio"
+	genRecordAttrSmall    = "small"
+	genRecordAttrData     = "data"
+	genRecordAttrTags     = "tags"
+	genRecordAttrScores   = "scores"
+	genRecordAttrLabels   = "labels"
+	genRecordAttrUpdated  = "updated"
+	genRecordAttrSeen     = "seen"
+	genRecordAttrExpires  = "expires"
+	genRecordAttrComment  = "comment"
+)
+
+// MarshalDdbItem encodes genRecord as ddbrepo.Marshal does.
+func (r *genRecord) MarshalDdbItem() (map[string]types.AttributeValue, error) {
+	item := make(map[string]types.AttributeValue, 20)
+	item[genRecordAttrTenant] = &types.AttributeValueMemberS{Value: r.Tenant}
+	if av, err := MarshalTime(r.Created, TimeUnixMilli); err != nil {
+		return nil, err
+	} else {
+		item[genRecordAttrCreated] = av
+	}
+	item[genRecordAttrOwner] = &types.AttributeValueMemberS{Value: r.Owner}
+	item[genRecordAttrStatus] = &types.AttributeValueMemberS{Value: string(r.Status)}
+	item[genRecordAttrVersion] = &types.AttributeValueMemberN{Value: strconv.FormatInt(r.Version, 10)}
+	if len(r.Title) > 0 {
+		item[genRecordAttrTitle] = &types.AttributeValueMemberS{Value: r.Title}
+	}
+	if r.Note == nil {
+		item[genRecordAttrNote] = &types.AttributeValueMemberNULL{Value: true}
+	} else {
+		item[genRecordAttrNote] = &types.AttributeValueMemberS{Value: *r.Note}
+	}
+	if r.Count != nil {
+		item[genRecordAttrCount] = &types.AttributeValueMemberN{Value: strconv.FormatInt(int64(*r.Count), 10)}
+	}
+	item[genRecordAttrActive] = &types.AttributeValueMemberBOOL{Value: r.Active}
+	item[genRecordAttrPriority] = &types.AttributeValueMemberN{Value: strconv.FormatInt(int64(r.Priority), 10)}
+	item[genRecordAttrRatio] = &types.AttributeValueMemberN{Value: strconv.FormatFloat(r.Ratio, 'f', -1, 64)}
+	item[genRecordAttrSmall] = &types.AttributeValueMemberN{Value: strconv.FormatUint(uint64(r.Small), 10)}
+	if r.Data == nil {
+		item[genRecordAttrData] = &types.AttributeValueMemberNULL{Value: true}
+	} else {
+		item[genRecordAttrData] = &types.AttributeValueMemberB{Value: append([]byte{}, r.Data...)}
+	}
+	if r.Tags == nil {
+		item[genRecordAttrTags] = &types.AttributeValueMemberNULL{Value: true}
+	} else {
+		list := make([]types.AttributeValue, len(r.Tags))
+		for i, v := range r.Tags {
+			list[i] = &types.AttributeValueMemberS{Value: v}
+		}
+		item[genRecordAttrTags] = &types.AttributeValueMemberL{Value: list}
+	}
+	if len(r.Scores) > 0 {
+		if r.Scores == nil {
+			item[genRecordAttrScores] = &types.AttributeValueMemberNULL{Value: true}
+		} else {
+			list := make([]types.AttributeValue, len(r.Scores))
+			for i, v := range r.Scores {
+				list[i] = &types.AttributeValueMemberN{Value: strconv.FormatFloat(float64(v), 'f', -1, 32)}
+			}
+			item[genRecordAttrScores] = &types.AttributeValueMemberL{Value: list}
+		}
+	}
+	if r.Labels == nil {
+		item[genRecordAttrLabels] = &types.AttributeValueMemberNULL{Value: true}
+	} else {
+		m := make(map[string]types.AttributeValue, len(r.Labels))
+		for k, v := range r.Labels {
+			m[k] = &types.AttributeValueMemberS{Value: v}
+		}
+		item[genRecordAttrLabels] = &types.AttributeValueMemberM{Value: m}
+	}
+	if r.Updated == (time.Time{}) {
+		item[genRecordAttrUpdated] = &types.AttributeValueMemberNULL{Value: true}
+	} else {
+		if av, err := MarshalTime(r.Updated, TimeRFC3339Nano); err != nil {
+			return nil, err
+		} else {
+			item[genRecordAttrUpdated] = av
+		}
+	}
+	if r.Seen != nil {
+		if av, err := MarshalTime(*r.Seen, TimeRFC3339); err != nil {
+			return nil, err
+		} else {
+			item[genRecordAttrSeen] = av
+		}
+	}
+	if av, err := MarshalTime(r.Expires, TimeUnix); err != nil {
+		return nil, err
+	} else {
+		item[genRecordAttrExpires] = av
+	}
+	item[genRecordAttrComment] = &types.AttributeValueMemberS{Value: r.Comment}
+	return item, nil
+}
+
+// MarshalDdbKey encodes the key of genRecord as ddbrepo.MarshalKey does.
+func (r *genRecord) MarshalDdbKey() (map[string]types.AttributeValue, error) {
+	item := make(map[string]types.AttributeValue, 2)
+	item[genRecordAttrTenant] = &types.AttributeValueMemberS{Value: r.Tenant}
+	if av, err := MarshalTime(r.Created, TimeUnixMilli); err != nil {
+		return nil, err
+	} else {
+		item[genRecordAttrCreated] = av
+	}
+	return item, nil
+}
+
+// UnmarshalDdbItem fills genRecord from the item as ddbrepo.Unmarshal does.
+func (r *genRecord) UnmarshalDdbItem(item map[string]types.AttributeValue) error {
+	if av, found := item[genRecordAttrTenant]; found {
+		if v, err := UnmarshalString("Tenant", av); err != nil {
+			return err
+		} else {
+			r.Tenant = v
+		}
+	}
+	if av, found := item[genRecordAttrCreated]; found {
+		if v, err := UnmarshalTime("Created", av, TimeUnixMilli); err != nil {
+			return err
+		} else {
+			r.Created = v
+		}
+	}
+	if av, found := item[genRecordAttrOwner]; found {
+		if v, err := UnmarshalString("Owner", av); err != nil {
+			return err
+		} else {
+			r.Owner = v
+		}
+	}
+	if av, found := item[genRecordAttrStatus]; found {
+		if v, err := UnmarshalString("Status", av); err != nil {
+			return err
+		} else {
+			r.Status = genStatus(v)
+		}
+	}
+	if av, found := item[genRecordAttrVersion]; found {
+		if v, err := UnmarshalInt("Version", av, 64); err != nil {
+			return err
+		} else {
+			r.Version = v
+		}
+	}
+	if av, found := item[genRecordAttrTitle]; found {
+		if v, err := UnmarshalString("Title", av); err != nil {
+			return err
+		} else {
+			r.Title = v
+		}
+	}
+	if av, found := item[genRecordAttrNote]; found {
+		if _, null := av.(*types.AttributeValueMemberNULL); null {
+			r.Note = nil
+		} else if v, err := UnmarshalString("Note", av); err != nil {
+			return err
+		} else {
+			pv := v
+			r.Note = &pv
+		}
+	}
+	if av, found := item[genRecordAttrCount]; found {
+		if _, null := av.(*types.AttributeValueMemberNULL); null {
+			r.Count = nil
+		} else if v, err := UnmarshalInt("Count", av, 0); err != nil {
+			return err
+		} else {
+			pv := int(v)
+			r.Count = &pv
+		}
+	}
+	if av, found := item[genRecordAttrActive]; found {
+		if v, err := UnmarshalBool("Active", av); err != nil {
+			return err
+		} else {
+			r.Active = v
+		}
+	}
+	if av, found := item[genRecordAttrPriority]; found {
+		if v, err := UnmarshalInt("Priority", av, 0); err != nil {
+			return err
+		} else {
+			r.Priority = int(v)
+		}
+	}
+	if av, found := item[genRecordAttrRatio]; found {
+		if v, err := UnmarshalFloat("Ratio", av, 64); err != nil {
+			return err
+		} else {
+			r.Ratio = v
+		}
+	}
+	if av, found := item[genRecordAttrSmall]; found {
+		if v, err := UnmarshalUint("Small", av, 8); err != nil {
+			return err
+		} else {
+			r.Small = uint8(v)
+		}
+	}
+	if av, found := item[genRecordAttrData]; found {
+		if v, err := UnmarshalBytes("Data", av); err != nil {
+			return err
+		} else {
+			r.Data = v
+		}
+	}
+	if av, found := item[genRecordAttrTags]; found {
+		list, found, err := UnmarshalList("Tags", av)
+		if err != nil {
+			return err
+		} else if !found {
+			r.Tags = nil
+		} else {
+			r.Tags = make([]string, len(list))
+			for i, elem := range list {
+				v, err := UnmarshalString("Tags", elem)
+				if err != nil {
+					return err
+				}
+				r.Tags[i] = v
+			}
+		}
+	}
+	if av, found := item[genRecordAttrScores]; found {
+		list, found, err := UnmarshalList("Scores", av)
+		if err != nil {
+			return err
+		} else if !found {
+			r.Scores = nil
+		} else {
+			r.Scores = make(genScores, len(list))
+			for i, elem := range list {
+				v, err := UnmarshalFloat("Scores", elem, 32)
+				if err != nil {
+					return err
+				}
+				r.Scores[i] = float32(v)
+			}
+		}
+	}
+	if av, found := item[genRecordAttrLabels]; found {
+		m, found, err := UnmarshalMap("Labels", av)
+		if err != nil {
+			return err
+		} else if !found {
+			r.Labels = nil
+		} else {
+			r.Labels = make(map[string]string, len(m))
+			for k, elem := range m {
+				v, err := UnmarshalString("Labels", elem)
+				if err != nil {
+					return err
+				}
+				r.Labels[k] = v
+			}
+		}
+	}
+	if av, found := item[genRecordAttrUpdated]; found {
+		if v, err := UnmarshalTime("Updated", av, TimeRFC3339Nano); err != nil {
+			return err
+		} else {
+			r.Updated = v
+		}
+	}
+	if av, found := item[genRecordAttrSeen]; found {
+		if _, null := av.(*types.AttributeValueMemberNULL); null {
+			r.Seen = nil
+		} else if v, err := UnmarshalTime("Seen", av, TimeRFC3339); err != nil {
+			return err
+		} else {
+			pv := v
+			r.Seen = &pv
+		}
+	}
+	if av, found := item[genRecordAttrExpires]; found {
+		if v, err := UnmarshalTime("Expires", av, TimeUnix); err != nil {
+			return err
+		} else {
+			r.Expires = v
+		}
+	}
+	if av, found := item[genRecordAttrComment]; found {
+		if v, err := UnmarshalString("Comment", av); err != nil {
+			return err
+		} else {
+			r.Comment = v
+		}
+	}
+	return nil
+}
+
+// DdbKeySchema describes the keys of genRecord for ddbrepo.New.
+func (*genRecord) DdbKeySchema() KeySchema {
+	return KeySchema{
+		Table: []types.KeySchemaElement{
+			{AttributeName: aws.String(genRecordAttrTenant), KeyType: types.KeyTypeHash},
+			{AttributeName: aws.String(genRecordAttrCreated), KeyType: types.KeyTypeRange},
+		},
+		Indexes: map[string][]types.KeySchemaElement{
+			"byOwner": {
+				{AttributeName: aws.String(genRecordAttrOwner), KeyType: types.KeyTypeHash},
+				{AttributeName: aws.String(genRecordAttrStatus), KeyType: types.KeyTypeRange},
+			},
+			"byPriority": {
+				{AttributeName: aws.String(genRecordAttrTenant), KeyType: types.KeyTypeHash},
+				{AttributeName: aws.String(genRecordAttrPriority), KeyType: types.KeyTypeRange},
+			},
+		},
+	}
+}
@@ -1,11 +1,12 @@
 module github.com/rotmistrk/ddbrepo
 
-go 1.21
+go 1.23
 
 require (
 	github.com/aws/aws-sdk-go-v2 v1.28.0
 	github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue v1.14.2
 	github.com/aws/aws-sdk-go-v2/service/dynamodb v1.32.9
+	github.com/aws/smithy-go v1.20.2
 	github.com/rotmistrk/must v0.0.0-20240618002041-2d3232bb0e9b
 )
 
@@ -15,6 +16,5 @@ require (
 	github.com/aws/aws-sdk-go-v2/service/dynamodbstreams v1.20.11 // indirect
 	github.com/aws/aws-sdk-go-v2/service/internal/accept-encoding v1.11.2 // indirect
 	github.com/aws/aws-sdk-go-v2/service/internal/endpoint-discovery v1.9.11 // indirect
-	github.com/aws/smithy-go v1.20.2 // indirect
 	github.com/jmespath/go-jmespath v0.4.0 // indirect
 )
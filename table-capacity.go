@@ -0,0 +1,100 @@
+package ddbrepo
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"maps"
+	"sort"
+)
+
+// WithBillingMode sets the billing mode of the table; with PAY_PER_REQUEST
+// no capacity is sent for the table or its global secondary indexes.
+func (repo DdbRepo[T]) WithBillingMode(mode types.BillingMode) *DdbRepo[T] {
+	repo.billingMode = mode
+	repo.billingModeConfigured = true
+	return &repo
+}
+
+// WithCapacity sets the provisioned read and write capacity units of the
+// table, also used by the global secondary indexes without a capacity of
+// their own.
+func (repo DdbRepo[T]) WithCapacity(read, write int64) *DdbRepo[T] {
+	repo.readCapacityUnitsConfig = read
+	repo.writeCapacityUnitsConfig = write
+	repo.capacityConfigured = true
+	return &repo
+}
+
+// WithIndexCapacity sets the provisioned read and write capacity units of a
+// global secondary index.
+func (repo DdbRepo[T]) WithIndexCapacity(index string, read, write int64) *DdbRepo[T] {
+	repo.indexCapacity = maps.Clone(repo.indexCapacity)
+	if repo.indexCapacity == nil {
+		repo.indexCapacity = make(map[string]types.ProvisionedThroughput)
+	}
+	repo.indexCapacity[index] = types.ProvisionedThroughput{
+		ReadCapacityUnits:  aws.Int64(read),
+		WriteCapacityUnits: aws.Int64(write),
+	}
+	return &repo
+}
+
+func (repo *DdbRepo[T]) throughputConfigured() bool {
+	return repo.billingModeConfigured || repo.capacityConfigured || len(repo.indexCapacity) > 0
+}
+
+func (repo *DdbRepo[T]) validateCapacity() error {
+	indexes := make([]string, 0, len(repo.indexCapacity))
+	for index := range repo.indexCapacity {
+		indexes = append(indexes, index)
+	}
+	sort.Strings(indexes)
+	for _, index := range indexes {
+		if _, found := repo.gsi[index]; !found {
+			return fmt.Errorf("capacity set for %v which is not a global secondary index of %v", index, repo.tableName)
+		}
+	}
+	switch repo.billingMode {
+	case types.BillingModePayPerRequest:
+		if repo.capacityConfigured {
+			return fmt.Errorf("table %v can't have provisioned capacity with %v billing", repo.tableName, repo.billingMode)
+		} else if len(indexes) > 0 {
+			return fmt.Errorf("index %v can't have provisioned capacity with %v billing", indexes[0], repo.billingMode)
+		}
+	case types.BillingModeProvisioned:
+		if repo.readCapacityUnitsConfig < 1 || repo.writeCapacityUnitsConfig < 1 {
+			return fmt.Errorf("table %v needs positive capacity units with %v billing, got %v read and %v write",
+				repo.tableName, repo.billingMode, repo.readCapacityUnitsConfig, repo.writeCapacityUnitsConfig)
+		}
+		for _, index := range indexes {
+			capacity := repo.indexCapacity[index]
+			if aws.ToInt64(capacity.ReadCapacityUnits) < 1 || aws.ToInt64(capacity.WriteCapacityUnits) < 1 {
+				return fmt.Errorf("index %v needs positive capacity units with %v billing, got %v read and %v write",
+					index, repo.billingMode, aws.ToInt64(capacity.ReadCapacityUnits), aws.ToInt64(capacity.WriteCapacityUnits))
+			}
+		}
+	default:
+		return fmt.Errorf("unknown billing mode %q for table %v", repo.billingMode, repo.tableName)
+	}
+	return nil
+}
+
+func (repo *DdbRepo[T]) getProvisionedThroughput() *types.ProvisionedThroughput {
+	if repo.billingMode == types.BillingModePayPerRequest {
+		return nil
+	}
+	return &types.ProvisionedThroughput{
+		ReadCapacityUnits:  aws.Int64(repo.readCapacityUnitsConfig),
+		WriteCapacityUnits: aws.Int64(repo.writeCapacityUnitsConfig),
+	}
+}
+
+func (repo *DdbRepo[T]) getIndexThroughput(index string) *types.ProvisionedThroughput {
+	if repo.billingMode == types.BillingModePayPerRequest {
+		return nil
+	} else if capacity, found := repo.indexCapacity[index]; found {
+		return &capacity
+	}
+	return repo.getProvisionedThroughput()
+}
@@ -0,0 +1,74 @@
+package ddbrepo
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/rotmistrk/ddbrepo/ddbrepotest"
+	"github.com/rotmistrk/must"
+	"testing"
+)
+
+func TestTableCreate_PayPerRequest(t *testing.T) {
+	repo := must.Must(New[orderGsiRecord]()).WithTableName("orders").WithDynamoDbApi(ddbrepotest.NewMemoryDynamoDb()).
+		WithBillingMode(types.BillingModePayPerRequest)
+	if input := repo.getProvisionedThroughput(); input != nil {
+		t.Errorf("getProvisionedThroughput() = %v, want nil", JsonLine(input))
+	}
+	for _, gsi := range repo.getGlobalSecondaryIndexes() {
+		if gsi.ProvisionedThroughput != nil {
+			t.Errorf("index %v has throughput %v, want nil", aws.ToString(gsi.IndexName), JsonLine(gsi.ProvisionedThroughput))
+		}
+	}
+	if err := repo.TableCreate(); err != nil {
+		t.Fatal(err)
+	}
+	report := must.Must(repo.TableReport())
+	if got := report.Table.BillingModeSummary.BillingMode; got != types.BillingModePayPerRequest {
+		t.Errorf("TableCreate() billing mode = %v, want %v", got, types.BillingModePayPerRequest)
+	}
+}
+
+func TestTableCreate_IndexCapacity(t *testing.T) {
+	repo := must.Must(New[orderGsiRecord]()).WithTableName("orders").WithDynamoDbApi(ddbrepotest.NewMemoryDynamoDb()).
+		WithCapacity(5, 3).WithIndexCapacity("byShop", 7, 2)
+	if err := repo.TableCreate(); err != nil {
+		t.Fatal(err)
+	}
+	report := must.Must(repo.TableReport())
+	if read, write := aws.ToInt64(report.Table.ProvisionedThroughput.ReadCapacityUnits), aws.ToInt64(report.Table.ProvisionedThroughput.WriteCapacityUnits); read != 5 || write != 3 {
+		t.Errorf("TableCreate() table capacity = %v/%v, want 5/3", read, write)
+	}
+	want := map[string][2]int64{"byShop": {7, 2}, "byStatus": {5, 3}}
+	for _, gsi := range report.Table.GlobalSecondaryIndexes {
+		got := [2]int64{aws.ToInt64(gsi.ProvisionedThroughput.ReadCapacityUnits), aws.ToInt64(gsi.ProvisionedThroughput.WriteCapacityUnits)}
+		if got != want[aws.ToString(gsi.IndexName)] {
+			t.Errorf("TableCreate() capacity of %v = %v, want %v", aws.ToString(gsi.IndexName), got, want[aws.ToString(gsi.IndexName)])
+		}
+	}
+}
+
+func TestDdbRepo_validateCapacity(t *testing.T) {
+	base := must.Must(New[orderGsiRecord]()).WithTableName("orders")
+	tests := []struct {
+		name    string
+		repo    *DdbRepo[orderGsiRecord]
+		wantErr bool
+	}{
+		{"default", base, false},
+		{"pay per request", base.WithBillingMode(types.BillingModePayPerRequest), false},
+		{"provisioned", base.WithCapacity(10, 10).WithIndexCapacity("byStatus", 1, 1), false},
+		{"pay per request with table capacity", base.WithBillingMode(types.BillingModePayPerRequest).WithCapacity(1, 1), true},
+		{"pay per request with index capacity", base.WithIndexCapacity("byShop", 1, 1).WithBillingMode(types.BillingModePayPerRequest), true},
+		{"zero table capacity", base.WithCapacity(0, 1), true},
+		{"zero index capacity", base.WithIndexCapacity("byShop", 1, 0), true},
+		{"unknown index", base.WithIndexCapacity("byNothing", 1, 1), true},
+		{"unknown billing mode", base.WithBillingMode("FREE"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.repo.validateCapacity(); (err != nil) != tt.wantErr {
+				t.Errorf("validateCapacity() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -5,55 +5,67 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"sort"
 	"time"
 )
 
 func (repo *DdbRepo[T]) TableCreate() (err error) {
+	return repo.TableCreateCtx(context.TODO())
+}
+
+func (repo *DdbRepo[T]) TableCreateCtx(ctx context.Context) (err error) {
 	if err = repo.validateConfig(); err != nil {
 		return
+	} else if err = repo.validateCapacity(); err != nil {
+		return
+	} else if err = repo.validateTableOptions(); err != nil {
+		return
 	}
 	input := &dynamodb.CreateTableInput{
-		TableName:              aws.String(repo.tableName),
-		AttributeDefinitions:   repo.getAttributeDefinitions(),
-		KeySchema:              repo.getKeySchema(),
-		GlobalSecondaryIndexes: repo.getGlobalSecondaryIndexes(),
-		LocalSecondaryIndexes:  repo.getLocalSecondaryIndexes(),
-		BillingMode:            repo.getBillingMode(),
-		ProvisionedThroughput:  repo.getProvisionedThroughput(),
+		TableName:                 aws.String(repo.tableName),
+		AttributeDefinitions:      repo.getAttributeDefinitions(),
+		KeySchema:                 repo.getKeySchema(),
+		GlobalSecondaryIndexes:    repo.getGlobalSecondaryIndexes(),
+		LocalSecondaryIndexes:     repo.getLocalSecondaryIndexes(),
+		BillingMode:               repo.getBillingMode(),
+		ProvisionedThroughput:     repo.getProvisionedThroughput(),
+		Tags:                      repo.getTags(),
+		SSESpecification:          repo.getSSESpecification(),
+		StreamSpecification:       repo.getStreamSpecification(),
+		TableClass:                repo.tableClass,
+		DeletionProtectionEnabled: repo.getDeletionProtection(),
 	}
 
-	if repo.gsi != nil {
-		input.GlobalSecondaryIndexes = make([]types.GlobalSecondaryIndex, 0, len(repo.gsi))
-		for _, v := range repo.gsi {
-			v.ProvisionedThroughput = &types.ProvisionedThroughput{
-				ReadCapacityUnits:  aws.Int64(repo.readCapacityUnitsConfig),
-				WriteCapacityUnits: aws.Int64(repo.writeCapacityUnitsConfig),
-			}
-			input.GlobalSecondaryIndexes = append(input.GlobalSecondaryIndexes, v)
-		}
-	}
-
-	if _, err := repo.ddbClient.CreateTable(context.TODO(), input); err != nil {
+	if _, err := repo.ddbClient.CreateTable(ctx, input); err != nil {
 		return err
 	}
 
-	if errr := repo.WaitTillReady(); errr != nil {
+	if errr := repo.WaitTillReady(ctx); errr != nil {
 		return errr
 	}
 
+	if repo.pointInTimeRecovery {
+		if err := repo.TableUpdatePointInTimeRecoveryCtx(ctx); err != nil {
+			return err
+		}
+	}
+
 	if repo.ttlColumn != "" {
-		return repo.TableUpdateTtl()
+		return repo.TableUpdateTtlCtx(ctx)
 	}
 
 	return
 }
 
-func (repo DdbRepo[RecordType]) WaitTillReady() error {
+func (repo DdbRepo[RecordType]) WaitTillReady(ctx context.Context) error {
 	if err := repo.validateConfig(); err != nil {
 		return err
 	}
 	waiter := dynamodb.NewTableExistsWaiter(repo.ddbClient)
-	return waiter.Wait(context.TODO(), repo.getDescribeTableInput(), repo.getWaitDuration())
+	if err := waiter.Wait(ctx, repo.getDescribeTableInput(), repo.getWaitDuration()); err != nil {
+		return &OpError{Op: "WaitTillReady", Table: repo.tableName, Kind: ErrTableNotReady, Err: err}
+	}
+	return nil
 }
 
 func (repo DdbRepo[RecordType]) getAttributeDefinitions() []types.AttributeDefinition {
@@ -65,11 +77,32 @@ func (repo *DdbRepo[T]) getKeySchema() []types.KeySchemaElement {
 }
 
 func (repo *DdbRepo[T]) getGlobalSecondaryIndexes() []types.GlobalSecondaryIndex {
-	return nil
+	if repo.gsi == nil {
+		return nil
+	}
+	result := make([]types.GlobalSecondaryIndex, 0, len(repo.gsi))
+	for name, gsi := range repo.gsi {
+		gsi.ProvisionedThroughput = repo.getIndexThroughput(name)
+		result = append(result, gsi)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return aws.ToString(result[i].IndexName) < aws.ToString(result[j].IndexName)
+	})
+	return result
 }
 
 func (repo *DdbRepo[T]) getLocalSecondaryIndexes() []types.LocalSecondaryIndex {
-	return nil
+	if repo.lsi == nil {
+		return nil
+	}
+	result := make([]types.LocalSecondaryIndex, 0, len(repo.lsi))
+	for _, lsi := range repo.lsi {
+		result = append(result, lsi)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return aws.ToString(result[i].IndexName) < aws.ToString(result[j].IndexName)
+	})
+	return result
 }
 
 func (repo *DdbRepo[T]) getBillingMode() types.BillingMode {
@@ -7,6 +7,10 @@ import (
 )
 
 func (repo *DdbRepo[T]) TableDelete() (err error) {
+	return repo.TableDeleteCtx(context.TODO())
+}
+
+func (repo *DdbRepo[T]) TableDeleteCtx(ctx context.Context) (err error) {
 	if err = repo.validateConfig(); err != nil {
 		return
 	}
@@ -14,7 +18,7 @@ func (repo *DdbRepo[T]) TableDelete() (err error) {
 		TableName: aws.String(repo.tableName),
 	}
 
-	if _, err := repo.ddbClient.DeleteTable(context.TODO(), input); err != nil {
+	if _, err := repo.ddbClient.DeleteTable(ctx, input); err != nil {
 		return err
 	}
 
@@ -0,0 +1,282 @@
+package ddbrepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"slices"
+	"strings"
+	"time"
+)
+
+const tablePollInterval = 5 * time.Second
+
+// TableChangeKind classifies a step of a table migration.
+type TableChangeKind string
+
+const (
+	TableChangeCreateTable TableChangeKind = "create table"
+	TableChangeDeleteIndex TableChangeKind = "delete index"
+	TableChangeBilling     TableChangeKind = "update billing"
+	TableChangeCreateIndex TableChangeKind = "create index"
+	TableChangeDisableTtl  TableChangeKind = "disable ttl"
+	TableChangeEnableTtl   TableChangeKind = "enable ttl"
+)
+
+// TableChange is a step of a table migration; Index names the secondary
+// index it concerns and Detail describes the target state.
+type TableChange struct {
+	Kind   TableChangeKind
+	Index  string
+	Detail string
+	apply  func(ctx context.Context) error
+}
+
+func (c TableChange) String() string {
+	result := string(c.Kind)
+	if c.Index != "" {
+		result += " " + c.Index
+	}
+	if c.Detail != "" {
+		result += ": " + c.Detail
+	}
+	return result
+}
+
+// TablePlan lists the changes EnsureTable applies, in order, and the
+// differences it can't migrate, like key schema changes.
+type TablePlan struct {
+	Table   string
+	Changes []TableChange
+	Refused []SchemaDifference
+}
+
+// Empty tells whether the table needs no changes.
+func (p *TablePlan) Empty() bool {
+	return len(p.Changes) == 0 && len(p.Refused) == 0
+}
+
+// Err returns an OpError of kind ErrSchemaDrift listing the refused
+// differences, nil when the plan can be applied.
+func (p *TablePlan) Err() error {
+	if len(p.Refused) == 0 {
+		return nil
+	}
+	parts := make([]string, 0, len(p.Refused))
+	for _, difference := range p.Refused {
+		parts = append(parts, difference.String())
+	}
+	return &OpError{Op: "EnsureTable", Table: p.Table, Kind: ErrSchemaDrift, Err: errors.New("can't migrate " + strings.Join(parts, "; "))}
+}
+
+// PlanTable returns the changes EnsureTable would make without making them.
+func (repo *DdbRepo[T]) PlanTable(ctx context.Context) (*TablePlan, error) {
+	if err := repo.validateConfig(); err != nil {
+		return nil, err
+	} else if err := repo.validateCapacity(); err != nil {
+		return nil, err
+	} else if err := repo.validateTableOptions(); err != nil {
+		return nil, err
+	}
+	plan := &TablePlan{Table: repo.tableName}
+	output, err := repo.ddbClient.DescribeTable(ctx, repo.getDescribeTableInput())
+	if errors.As(err, new(*types.ResourceNotFoundException)) {
+		plan.Changes = append(plan.Changes, TableChange{Kind: TableChangeCreateTable, apply: repo.TableCreateCtx})
+		return plan, nil
+	} else if err != nil {
+		return nil, opError("PlanTable", repo.tableName, nil, err)
+	}
+	ttlOutput, err := repo.ddbClient.DescribeTimeToLive(ctx, &dynamodb.DescribeTimeToLiveInput{TableName: aws.String(repo.tableName)})
+	if err != nil {
+		return nil, opError("PlanTable", repo.tableName, nil, err)
+	}
+
+	var creates, deletes []string
+	var ttl *SchemaDifference
+	for _, difference := range repo.diffSchema(output.Table, ttlOutput.TimeToLiveDescription).Differences {
+		switch {
+		case difference.Kind == SchemaMissingIndex && difference.Expected == globalIndex:
+			creates = append(creates, difference.Index)
+		case difference.Kind == SchemaExtraIndex && difference.Actual == globalIndex:
+			deletes = append(deletes, difference.Index)
+		case difference.Kind == SchemaAttributeType && difference.Actual == "":
+			// defined along with the index using it
+		case difference.Kind == SchemaTtlMismatch:
+			ttl = &difference
+		case difference.Kind == SchemaBillingMode:
+			// planned with the capacity below
+		default:
+			plan.Refused = append(plan.Refused, difference)
+		}
+	}
+
+	for _, name := range deletes {
+		input := &dynamodb.UpdateTableInput{
+			TableName:                   aws.String(repo.tableName),
+			GlobalSecondaryIndexUpdates: []types.GlobalSecondaryIndexUpdate{{Delete: &types.DeleteGlobalSecondaryIndexAction{IndexName: aws.String(name)}}},
+		}
+		plan.Changes = append(plan.Changes, TableChange{Kind: TableChangeDeleteIndex, Index: name, apply: repo.updateTableStep(input)})
+	}
+	if input, detail := repo.billingUpdate(output.Table, deletes); input != nil {
+		plan.Changes = append(plan.Changes, TableChange{Kind: TableChangeBilling, Detail: detail, apply: repo.updateTableStep(input)})
+	}
+	for _, name := range creates {
+		input, detail := repo.indexCreate(name)
+		plan.Changes = append(plan.Changes, TableChange{Kind: TableChangeCreateIndex, Index: name, Detail: detail, apply: repo.updateTableStep(input)})
+	}
+	switch status := ttlStatus(ttlOutput.TimeToLiveDescription); {
+	case ttl == nil:
+	case status == types.TimeToLiveStatusEnabling || status == types.TimeToLiveStatusDisabling:
+		ttl.Actual = fmt.Sprintf("%v (%v)", aws.ToString(ttlOutput.TimeToLiveDescription.AttributeName), status)
+		plan.Refused = append(plan.Refused, *ttl)
+	case ttl.Actual != "" && ttl.Expected != "":
+		plan.Refused = append(plan.Refused, *ttl)
+	case ttl.Actual != "":
+		attribute := ttl.Actual
+		plan.Changes = append(plan.Changes, TableChange{Kind: TableChangeDisableTtl, Detail: attribute, apply: func(ctx context.Context) error {
+			return repo.updateTtl(ctx, attribute, false)
+		}})
+	default:
+		plan.Changes = append(plan.Changes, TableChange{Kind: TableChangeEnableTtl, Detail: ttl.Expected, apply: repo.TableUpdateTtlCtx})
+	}
+	return plan, nil
+}
+
+// EnsureTable creates the table or applies the changes of PlanTable one at a
+// time, waiting for the table to become active after each. Table options
+// like tags and encryption only apply to new tables.
+func (repo *DdbRepo[T]) EnsureTable(ctx context.Context) (*TablePlan, error) {
+	plan, err := repo.PlanTable(ctx)
+	if err != nil {
+		return nil, err
+	} else if err := plan.Err(); err != nil {
+		return plan, err
+	}
+	for _, change := range plan.Changes {
+		if err := change.apply(ctx); err != nil {
+			return plan, changeError(repo.tableName, change, err)
+		}
+	}
+	return plan, nil
+}
+
+func changeError(table string, change TableChange, err error) error {
+	result := &OpError{Op: "EnsureTable", Table: table, Kind: classifyError(err), Err: fmt.Errorf("%v: %w", change, err)}
+	var existing *OpError
+	if errors.As(err, &existing) {
+		result.Kind = existing.Kind
+	}
+	return result
+}
+
+func ttlStatus(ttl *types.TimeToLiveDescription) types.TimeToLiveStatus {
+	if ttl == nil {
+		return ""
+	}
+	return ttl.TimeToLiveStatus
+}
+
+func (repo *DdbRepo[T]) billingUpdate(table *types.TableDescription, deletes []string) (*dynamodb.UpdateTableInput, string) {
+	if !repo.throughputConfigured() {
+		return nil, ""
+	}
+	input := &dynamodb.UpdateTableInput{TableName: aws.String(repo.tableName)}
+	changed := actualBillingMode(table) != repo.billingMode
+	if changed {
+		input.BillingMode = repo.billingMode
+	}
+	if repo.billingMode == types.BillingModePayPerRequest {
+		if !changed {
+			return nil, ""
+		}
+		return input, string(repo.billingMode)
+	}
+	throughput := repo.getProvisionedThroughput()
+	if changed || !sameThroughput(table.ProvisionedThroughput, throughput) {
+		input.ProvisionedThroughput = throughput
+	}
+	for _, gsi := range table.GlobalSecondaryIndexes {
+		name := aws.ToString(gsi.IndexName)
+		if slices.Contains(deletes, name) {
+			continue
+		}
+		if indexThroughput := repo.getIndexThroughput(name); changed || !sameThroughput(gsi.ProvisionedThroughput, indexThroughput) {
+			input.GlobalSecondaryIndexUpdates = append(input.GlobalSecondaryIndexUpdates, types.GlobalSecondaryIndexUpdate{
+				Update: &types.UpdateGlobalSecondaryIndexAction{IndexName: gsi.IndexName, ProvisionedThroughput: indexThroughput},
+			})
+		}
+	}
+	if input.BillingMode == "" && input.ProvisionedThroughput == nil && len(input.GlobalSecondaryIndexUpdates) == 0 {
+		return nil, ""
+	}
+	return input, fmt.Sprintf("%v with %v read and %v write capacity units", repo.billingMode, repo.readCapacityUnitsConfig, repo.writeCapacityUnitsConfig)
+}
+
+func sameThroughput(actual *types.ProvisionedThroughputDescription, expected *types.ProvisionedThroughput) bool {
+	return actual != nil && aws.ToInt64(actual.ReadCapacityUnits) == aws.ToInt64(expected.ReadCapacityUnits) &&
+		aws.ToInt64(actual.WriteCapacityUnits) == aws.ToInt64(expected.WriteCapacityUnits)
+}
+
+func (repo *DdbRepo[T]) indexCreate(name string) (*dynamodb.UpdateTableInput, string) {
+	gsi := repo.gsi[name]
+	input := &dynamodb.UpdateTableInput{
+		TableName: aws.String(repo.tableName),
+		GlobalSecondaryIndexUpdates: []types.GlobalSecondaryIndexUpdate{{Create: &types.CreateGlobalSecondaryIndexAction{
+			IndexName:             gsi.IndexName,
+			KeySchema:             gsi.KeySchema,
+			Projection:            gsi.Projection,
+			ProvisionedThroughput: repo.getIndexThroughput(name),
+		}}},
+	}
+	for _, ad := range repo.attributeDefinitions {
+		for _, key := range gsi.KeySchema {
+			if aws.ToString(key.AttributeName) == aws.ToString(ad.AttributeName) {
+				input.AttributeDefinitions = append(input.AttributeDefinitions, ad)
+			}
+		}
+	}
+	hashKey, rangeKey := keyNames(gsi.KeySchema)
+	return input, fmt.Sprintf("%v %v, %v", hashKey, rangeKey, projectionString(gsi.Projection))
+}
+
+func (repo *DdbRepo[T]) updateTableStep(input *dynamodb.UpdateTableInput) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		if _, err := repo.ddbClient.UpdateTable(ctx, input); err != nil {
+			return err
+		}
+		return repo.waitTableActive(ctx)
+	}
+}
+
+func (repo *DdbRepo[T]) waitTableActive(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, repo.getWaitDuration())
+	defer cancel()
+	for {
+		output, err := repo.ddbClient.DescribeTable(ctx, repo.getDescribeTableInput())
+		if err != nil {
+			return &OpError{Op: "WaitTillReady", Table: repo.tableName, Kind: ErrTableNotReady, Err: err}
+		} else if tableActive(output.Table) {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return &OpError{Op: "WaitTillReady", Table: repo.tableName, Kind: ErrTableNotReady, Err: ctx.Err()}
+		case <-time.After(tablePollInterval):
+		}
+	}
+}
+
+func tableActive(table *types.TableDescription) bool {
+	if table.TableStatus != types.TableStatusActive {
+		return false
+	}
+	for _, gsi := range table.GlobalSecondaryIndexes {
+		if gsi.IndexStatus != types.IndexStatusActive {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,211 @@
+package ddbrepo
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+	"github.com/rotmistrk/ddbrepo/ddbrepotest"
+	"github.com/rotmistrk/must"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type orderV1Record struct {
+	Customer string    `ddb:"customer,hash-key"`
+	OrderID  string    `ddb:"orderId,range-key"`
+	Shop     string    `ddb:"shop"`
+	Placed   string    `ddb:"placed"`
+	Total    int       `ddb:"total"`
+	Legacy   string    `ddb:"legacy" ddb-gsi:"byLegacy hash-key"`
+	Expires  time.Time `ddb:"expires,expire"`
+}
+
+func planSteps(plan *TablePlan) []string {
+	result := make([]string, 0, len(plan.Changes))
+	for _, change := range plan.Changes {
+		result = append(result, string(change.Kind)+" "+change.Index)
+	}
+	return result
+}
+
+func TestDdbRepo_EnsureTableCreates(t *testing.T) {
+	repo := must.Must(New[orderGsiRecord]()).WithTableName("orders").WithDynamoDbApi(ddbrepotest.NewMemoryDynamoDb())
+	plan, err := repo.EnsureTable(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := planSteps(plan); !reflect.DeepEqual(got, []string{"create table "}) {
+		t.Errorf("EnsureTable() = %v, want a create table", got)
+	}
+	if plan = must.Must(repo.PlanTable(context.TODO())); !plan.Empty() {
+		t.Errorf("PlanTable() after EnsureTable() = %v", planSteps(plan))
+	}
+}
+
+func TestDdbRepo_EnsureTableMigrates(t *testing.T) {
+	db := ddbrepotest.NewMemoryDynamoDb()
+	old := must.Must(New[orderV1Record]()).WithTableName("orders").WithDynamoDbApi(db).WithBillingMode(types.BillingModePayPerRequest)
+	if err := old.TableCreate(); err != nil {
+		t.Fatal(err)
+	}
+	for i, id := range []string{"o1", "o2"} {
+		record := &orderV1Record{Customer: "c1", OrderID: id, Shop: "s1", Placed: "2024-0" + id, Total: i + 1, Legacy: "l"}
+		if err := old.PutItem(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	repo := must.Must(New[orderGsiRecord]()).WithTableName("orders").WithDynamoDbApi(db).WithCapacity(2, 2)
+	plan := must.Must(repo.PlanTable(context.TODO()))
+	want := []string{"delete index byLegacy", "update billing ", "create index byShop", "create index byStatus", "disable ttl "}
+	if got := planSteps(plan); !reflect.DeepEqual(got, want) {
+		t.Fatalf("PlanTable() = %v, want %v", got, want)
+	}
+	if diff := must.Must(repo.CheckSchema(context.TODO())); diff.Empty() {
+		t.Error("PlanTable() changed the table")
+	}
+
+	if _, err := repo.EnsureTable(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	if diff := must.Must(repo.CheckSchema(context.TODO())); !diff.Empty() {
+		t.Errorf("CheckSchema() after EnsureTable() = %v", diff.Err())
+	}
+	if plan := must.Must(repo.PlanTable(context.TODO())); !plan.Empty() {
+		t.Errorf("PlanTable() after EnsureTable() = %v", planSteps(plan))
+	}
+	shops := must.Must(NewProjectionRepo[orderGsiRecord, orderShopView](repo, "byShop"))
+	var totals []int
+	err := shops.Query(func(p *orderShopView) error {
+		totals = append(totals, p.Total)
+		return nil
+	}, &orderGsiRecord{Shop: "s1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(totals, []int{1, 2}) {
+		t.Errorf("query on the created index = %v, want [1 2]", totals)
+	}
+}
+
+func TestDdbRepo_EnsureTableRefuses(t *testing.T) {
+	db := ddbrepotest.NewMemoryDynamoDb()
+	if err := must.Must(New[orderDriftRecord]()).WithTableName("orders").WithDynamoDbApi(db).TableCreate(); err != nil {
+		t.Fatal(err)
+	}
+	repo := must.Must(New[orderGsiRecord]()).WithTableName("orders").WithDynamoDbApi(db)
+	plan, err := repo.EnsureTable(context.TODO())
+	if !errors.Is(err, ErrSchemaDrift) {
+		t.Fatalf("EnsureTable() error = %v, want %v", err, ErrSchemaDrift)
+	}
+	refused := make(map[SchemaDifferenceKind]bool)
+	for _, difference := range plan.Refused {
+		refused[difference.Kind] = true
+	}
+	want := map[SchemaDifferenceKind]bool{SchemaAttributeType: true, SchemaIndexKeyMismatch: true, SchemaProjectionMismatch: true}
+	if !reflect.DeepEqual(refused, want) {
+		t.Errorf("EnsureTable() refused %v, want %v", refused, want)
+	}
+	report := must.Must(repo.TableReport())
+	if got := len(report.Table.GlobalSecondaryIndexes); got != 2 {
+		t.Errorf("EnsureTable() changed the indexes of a refused table to %v", got)
+	}
+}
+
+func TestDdbRepo_EnsureTableTtlAndCapacity(t *testing.T) {
+	db := ddbrepotest.NewMemoryDynamoDb()
+	repo := must.Must(New[orderV1Record]()).WithTableName("orders").WithDynamoDbApi(db)
+	if err := repo.TableCreate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.updateTtl(context.TODO(), "expires", false); err != nil {
+		t.Fatal(err)
+	}
+	repo = repo.WithCapacity(4, 3).WithIndexCapacity("byLegacy", 2, 1)
+	plan, err := repo.EnsureTable(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := planSteps(plan); !reflect.DeepEqual(got, []string{"update billing ", "enable ttl "}) {
+		t.Errorf("EnsureTable() = %v", got)
+	}
+	report := must.Must(repo.TableReport())
+	if report.Ttl.TimeToLiveStatus != types.TimeToLiveStatusEnabled || aws.ToString(report.Ttl.AttributeName) != "expires" {
+		t.Errorf("EnsureTable() ttl = %v", JsonLine(report.Ttl))
+	}
+	table, gsi := report.Table.ProvisionedThroughput, report.Table.GlobalSecondaryIndexes[0].ProvisionedThroughput
+	if got := []int64{aws.ToInt64(table.ReadCapacityUnits), aws.ToInt64(table.WriteCapacityUnits), aws.ToInt64(gsi.ReadCapacityUnits), aws.ToInt64(gsi.WriteCapacityUnits)}; !reflect.DeepEqual(got, []int64{4, 3, 2, 1}) {
+		t.Errorf("EnsureTable() capacity = %v, want [4 3 2 1]", got)
+	}
+}
+
+type orderRenamedTtlRecord struct {
+	Customer string    `ddb:"customer,hash-key"`
+	OrderID  string    `ddb:"orderId,range-key"`
+	Legacy   string    `ddb:"legacy" ddb-gsi:"byLegacy hash-key"`
+	Expires  time.Time `ddb:"expiresAt,expire"`
+}
+
+type ttlDisablingApi struct {
+	*ddbrepotest.MemoryDynamoDb
+}
+
+func (api *ttlDisablingApi) DescribeTimeToLive(ctx context.Context, params *dynamodb.DescribeTimeToLiveInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTimeToLiveOutput, error) {
+	return &dynamodb.DescribeTimeToLiveOutput{TimeToLiveDescription: &types.TimeToLiveDescription{
+		AttributeName:    aws.String("expires"),
+		TimeToLiveStatus: types.TimeToLiveStatusDisabling,
+	}}, nil
+}
+
+type updateThrottledApi struct {
+	*ddbrepotest.MemoryDynamoDb
+}
+
+func (api *updateThrottledApi) UpdateTable(ctx context.Context, params *dynamodb.UpdateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateTableOutput, error) {
+	return nil, &smithy.GenericAPIError{Code: "ThrottlingException", Message: "rate exceeded"}
+}
+
+func TestDdbRepo_EnsureTableKeepsUnconfigured(t *testing.T) {
+	db := ddbrepotest.NewMemoryDynamoDb()
+	repo := must.Must(New[orderV1Record]()).WithTableName("orders").WithDynamoDbApi(db)
+	if err := repo.WithBillingMode(types.BillingModePayPerRequest).TableCreate(); err != nil {
+		t.Fatal(err)
+	}
+	if plan := must.Must(repo.PlanTable(context.TODO())); !plan.Empty() {
+		t.Errorf("PlanTable() without billing or capacity = %v", planSteps(plan))
+	}
+
+	renamed := must.Must(New[orderRenamedTtlRecord]()).WithTableName("orders").WithDynamoDbApi(db)
+	plan, err := renamed.EnsureTable(context.TODO())
+	want := []SchemaDifference{{Kind: SchemaTtlMismatch, Expected: "expiresAt", Actual: "expires"}}
+	if !errors.Is(err, ErrSchemaDrift) || !reflect.DeepEqual(plan.Refused, want) {
+		t.Errorf("EnsureTable() of a renamed ttl = %v, %v, want %v refused", JsonLine(&plan.Refused), err, JsonLine(&want))
+	}
+	report := must.Must(repo.TableReport())
+	if report.Ttl.TimeToLiveStatus != types.TimeToLiveStatusEnabled || aws.ToString(report.Ttl.AttributeName) != "expires" {
+		t.Errorf("EnsureTable() changed a refused ttl to %v", JsonLine(report.Ttl))
+	}
+
+	plan = must.Must(repo.WithDynamoDbApi(&ttlDisablingApi{db}).PlanTable(context.TODO()))
+	want = []SchemaDifference{{Kind: SchemaTtlMismatch, Expected: "expires", Actual: "expires (DISABLING)"}}
+	if len(plan.Changes) != 0 || !reflect.DeepEqual(plan.Refused, want) {
+		t.Errorf("PlanTable() of a disabling ttl = %v %v, want %v refused", planSteps(plan), JsonLine(&plan.Refused), JsonLine(&want))
+	}
+}
+
+func TestDdbRepo_EnsureTableErrors(t *testing.T) {
+	db := ddbrepotest.NewMemoryDynamoDb()
+	repo := must.Must(New[orderV1Record]()).WithTableName("orders").WithDynamoDbApi(db)
+	if err := repo.TableCreate(); err != nil {
+		t.Fatal(err)
+	}
+	_, err := repo.WithDynamoDbApi(&updateThrottledApi{db}).WithCapacity(4, 3).EnsureTable(context.TODO())
+	var opErr *OpError
+	if !errors.As(err, &opErr) || opErr.Op != "EnsureTable" || !errors.Is(err, ErrThrottled) {
+		t.Errorf("EnsureTable() error = %v, want a throttled %T", err, opErr)
+	}
+}
@@ -0,0 +1,121 @@
+package ddbrepo
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"maps"
+	"sort"
+	"strings"
+)
+
+// MaxTableTags is the number of tags DynamoDB allows on a table.
+const MaxTableTags = 50
+
+// WithTags adds resource tags applied when the table is created.
+func (repo DdbRepo[T]) WithTags(tags map[string]string) *DdbRepo[T] {
+	repo.tags = maps.Clone(repo.tags)
+	if repo.tags == nil {
+		repo.tags = make(map[string]string, len(tags))
+	}
+	maps.Copy(repo.tags, tags)
+	return &repo
+}
+
+// WithKmsEncryption encrypts the table with the KMS key given by its id, ARN
+// or alias; an empty key id selects the AWS managed key of DynamoDB.
+func (repo DdbRepo[T]) WithKmsEncryption(kmsKeyId string) *DdbRepo[T] {
+	repo.kmsEncryption = true
+	repo.kmsKeyId = kmsKeyId
+	return &repo
+}
+
+// WithStream enables DynamoDB Streams with the given view type; an empty
+// view type leaves streams disabled.
+func (repo DdbRepo[T]) WithStream(viewType types.StreamViewType) *DdbRepo[T] {
+	repo.streamViewType = viewType
+	return &repo
+}
+
+// WithPointInTimeRecovery enables continuous backups once the table is
+// created.
+func (repo DdbRepo[T]) WithPointInTimeRecovery(enabled bool) *DdbRepo[T] {
+	repo.pointInTimeRecovery = enabled
+	return &repo
+}
+
+// WithTableClass sets the table class, STANDARD unless given.
+func (repo DdbRepo[T]) WithTableClass(class types.TableClass) *DdbRepo[T] {
+	repo.tableClass = class
+	return &repo
+}
+
+// WithDeletionProtection keeps the table from being deleted until the
+// protection is disabled.
+func (repo DdbRepo[T]) WithDeletionProtection(enabled bool) *DdbRepo[T] {
+	repo.deletionProtection = enabled
+	return &repo
+}
+
+func (repo *DdbRepo[T]) validateTableOptions() error {
+	if len(repo.tags) > MaxTableTags {
+		return fmt.Errorf("%v tags set for table %v, at most %v are allowed", len(repo.tags), repo.tableName, MaxTableTags)
+	}
+	for key, value := range repo.tags {
+		if key == "" || len(key) > 128 || strings.HasPrefix(key, "aws:") {
+			return fmt.Errorf("invalid tag key %q for table %v", key, repo.tableName)
+		} else if len(value) > 256 {
+			return fmt.Errorf("value of tag %v for table %v is longer than 256", key, repo.tableName)
+		}
+	}
+	switch repo.streamViewType {
+	case "", types.StreamViewTypeKeysOnly, types.StreamViewTypeNewImage, types.StreamViewTypeOldImage, types.StreamViewTypeNewAndOldImages:
+	default:
+		return fmt.Errorf("unknown stream view type %q for table %v", repo.streamViewType, repo.tableName)
+	}
+	switch repo.tableClass {
+	case "", types.TableClassStandard, types.TableClassStandardInfrequentAccess:
+	default:
+		return fmt.Errorf("unknown table class %q for table %v", repo.tableClass, repo.tableName)
+	}
+	return nil
+}
+
+func (repo *DdbRepo[T]) getTags() []types.Tag {
+	if len(repo.tags) == 0 {
+		return nil
+	}
+	result := make([]types.Tag, 0, len(repo.tags))
+	for key, value := range repo.tags {
+		result = append(result, types.Tag{Key: aws.String(key), Value: aws.String(value)})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return aws.ToString(result[i].Key) < aws.ToString(result[j].Key)
+	})
+	return result
+}
+
+func (repo *DdbRepo[T]) getSSESpecification() *types.SSESpecification {
+	if !repo.kmsEncryption {
+		return nil
+	}
+	result := &types.SSESpecification{Enabled: aws.Bool(true), SSEType: types.SSETypeKms}
+	if repo.kmsKeyId != "" {
+		result.KMSMasterKeyId = aws.String(repo.kmsKeyId)
+	}
+	return result
+}
+
+func (repo *DdbRepo[T]) getStreamSpecification() *types.StreamSpecification {
+	if repo.streamViewType == "" {
+		return nil
+	}
+	return &types.StreamSpecification{StreamEnabled: aws.Bool(true), StreamViewType: repo.streamViewType}
+}
+
+func (repo *DdbRepo[T]) getDeletionProtection() *bool {
+	if !repo.deletionProtection {
+		return nil
+	}
+	return aws.Bool(true)
+}
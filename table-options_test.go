@@ -0,0 +1,135 @@
+package ddbrepo
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+	"github.com/rotmistrk/ddbrepo/ddbrepotest"
+	"github.com/rotmistrk/must"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTableCreate_Options(t *testing.T) {
+	repo := must.Must(New[orderGsiRecord]()).WithTableName("archive").WithDynamoDbApi(ddbrepotest.NewMemoryDynamoDb()).
+		WithTags(map[string]string{"team": "orders", "env": "test"}).
+		WithKmsEncryption("alias/orders").
+		WithStream(types.StreamViewTypeNewAndOldImages).
+		WithPointInTimeRecovery(true).
+		WithTableClass(types.TableClassStandardInfrequentAccess).
+		WithDeletionProtection(true)
+	if err := repo.TableCreate(); err != nil {
+		t.Fatal(err)
+	}
+	report := must.Must(repo.TableReport())
+	wantTags := []types.Tag{{Key: aws.String("env"), Value: aws.String("test")}, {Key: aws.String("team"), Value: aws.String("orders")}}
+	if !reflect.DeepEqual(report.Tags, wantTags) {
+		t.Errorf("TableReport() tags = %v, want %v", JsonLine(&report.Tags), JsonLine(&wantTags))
+	}
+	if sse := report.Table.SSEDescription; sse == nil || sse.SSEType != types.SSETypeKms || aws.ToString(sse.KMSMasterKeyArn) != "alias/orders" {
+		t.Errorf("TableReport() encryption = %v", JsonLine(sse))
+	}
+	if stream := report.Table.StreamSpecification; stream == nil || !aws.ToBool(stream.StreamEnabled) || stream.StreamViewType != types.StreamViewTypeNewAndOldImages {
+		t.Errorf("TableReport() stream = %v", JsonLine(stream))
+	}
+	if got := report.ContinuousBackups.PointInTimeRecoveryDescription.PointInTimeRecoveryStatus; got != types.PointInTimeRecoveryStatusEnabled {
+		t.Errorf("TableReport() point-in-time recovery = %v", got)
+	}
+	if got := report.Table.TableClassSummary.TableClass; got != types.TableClassStandardInfrequentAccess {
+		t.Errorf("TableReport() table class = %v", got)
+	}
+	if !aws.ToBool(report.Table.DeletionProtectionEnabled) {
+		t.Error("TableReport() deletion protection is disabled")
+	}
+	if err := repo.TableDelete(); err == nil {
+		t.Error("TableDelete() of a protected table succeeded")
+	}
+}
+
+func TestTableCreate_DefaultOptions(t *testing.T) {
+	repo := must.Must(New[orderGsiRecord]()).WithTableName("orders").WithDynamoDbApi(ddbrepotest.NewMemoryDynamoDb())
+	if err := repo.TableCreate(); err != nil {
+		t.Fatal(err)
+	}
+	report := must.Must(repo.TableReport())
+	if report.Table.SSEDescription != nil || report.Table.StreamSpecification != nil || len(report.Tags) != 0 {
+		t.Errorf("TableReport() = %v, want no encryption, stream or tags", JsonLine(report))
+	}
+	if got := report.ContinuousBackups.PointInTimeRecoveryDescription.PointInTimeRecoveryStatus; got != types.PointInTimeRecoveryStatusDisabled {
+		t.Errorf("TableReport() point-in-time recovery = %v", got)
+	}
+	if err := repo.TableDelete(); err != nil {
+		t.Errorf("TableDelete() error = %v", err)
+	}
+}
+
+type describeOnlyApi struct {
+	*ddbrepotest.MemoryDynamoDb
+}
+
+func (api *describeOnlyApi) DescribeContinuousBackups(ctx context.Context, params *dynamodb.DescribeContinuousBackupsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeContinuousBackupsOutput, error) {
+	return nil, &smithy.GenericAPIError{Code: "AccessDeniedException", Message: "not authorized"}
+}
+
+func (api *describeOnlyApi) ListTagsOfResource(ctx context.Context, params *dynamodb.ListTagsOfResourceInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ListTagsOfResourceOutput, error) {
+	return nil, &smithy.GenericAPIError{Code: "AccessDeniedException", Message: "not authorized"}
+}
+
+func TestTableReport_AccessDenied(t *testing.T) {
+	db := ddbrepotest.NewMemoryDynamoDb()
+	repo := must.Must(New[orderGsiRecord]()).WithTableName("orders").WithDynamoDbApi(db).WithTags(map[string]string{"team": "orders"})
+	if err := repo.TableCreate(); err != nil {
+		t.Fatal(err)
+	}
+	report, err := repo.WithDynamoDbApi(&describeOnlyApi{db}).TableReport()
+	if err != nil {
+		t.Fatalf("TableReport() error = %v", err)
+	}
+	if report.Table == nil || report.ContinuousBackups != nil || report.Tags != nil {
+		t.Errorf("TableReport() = %v, want the table without backups or tags", JsonLine(report))
+	}
+}
+
+func TestTableUpdatePointInTimeRecovery_Errors(t *testing.T) {
+	repo := must.Must(New[orderGsiRecord]()).WithTableName("missing").WithDynamoDbApi(ddbrepotest.NewMemoryDynamoDb())
+	var opErr *OpError
+	if err := repo.TableUpdatePointInTimeRecovery(); !errors.As(err, &opErr) || opErr.Op != "TableUpdatePointInTimeRecovery" {
+		t.Errorf("TableUpdatePointInTimeRecovery() error = %v, want %T", err, opErr)
+	}
+	if err := repo.WithDynamoDbApi(nil).TableUpdatePointInTimeRecovery(); err == nil {
+		t.Error("TableUpdatePointInTimeRecovery() without a client succeeded")
+	}
+}
+
+func TestDdbRepo_validateTableOptions(t *testing.T) {
+	base := must.Must(New[orderGsiRecord]()).WithTableName("orders")
+	tooMany := make(map[string]string)
+	for i := 0; i <= MaxTableTags; i++ {
+		tooMany[strings.Repeat("k", i+1)] = "v"
+	}
+	tests := []struct {
+		name    string
+		repo    *DdbRepo[orderGsiRecord]
+		wantErr bool
+	}{
+		{"default", base, false},
+		{"all set", base.WithTags(map[string]string{"a": "b"}).WithKmsEncryption("").WithStream(types.StreamViewTypeKeysOnly).WithTableClass(types.TableClassStandard), false},
+		{"empty tag key", base.WithTags(map[string]string{"": "b"}), true},
+		{"reserved tag key", base.WithTags(map[string]string{"aws:owner": "b"}), true},
+		{"long tag value", base.WithTags(map[string]string{"a": strings.Repeat("v", 257)}), true},
+		{"too many tags", base.WithTags(tooMany), true},
+		{"unknown stream view type", base.WithStream("EVERYTHING"), true},
+		{"unknown table class", base.WithTableClass("GLACIER"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.repo.validateTableOptions(); (err != nil) != tt.wantErr {
+				t.Errorf("validateTableOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -7,12 +7,21 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 )
 
+// TableReportSummary describes the table with its time to live, continuous
+// backups and tags; ContinuousBackups and Tags are left empty when the caller
+// is not allowed to read them.
 type TableReportSummary struct {
-	Table *types.TableDescription
-	Ttl   *types.TimeToLiveDescription
+	Table             *types.TableDescription
+	Ttl               *types.TimeToLiveDescription
+	ContinuousBackups *types.ContinuousBackupsDescription
+	Tags              []types.Tag
 }
 
 func (repo *DdbRepo[T]) TableReport() (*TableReportSummary, error) {
+	return repo.TableReportCtx(context.TODO())
+}
+
+func (repo *DdbRepo[T]) TableReportCtx(ctx context.Context) (*TableReportSummary, error) {
 	if err := repo.validateConfig(); err != nil {
 		return nil, err
 	}
@@ -20,19 +29,47 @@ func (repo *DdbRepo[T]) TableReport() (*TableReportSummary, error) {
 		TableName: aws.String(repo.tableName),
 	}
 
-	if output, err := repo.ddbClient.DescribeTable(context.TODO(), input); err != nil {
+	output, err := repo.ddbClient.DescribeTable(ctx, input)
+	if err != nil {
 		return nil, err
-	} else {
-		ttlInput := &dynamodb.DescribeTimeToLiveInput{
-			TableName: aws.String(repo.tableName),
-		}
-		if ttlOutput, err := repo.ddbClient.DescribeTimeToLive(context.TODO(), ttlInput); err != nil {
+	}
+	ttlInput := &dynamodb.DescribeTimeToLiveInput{
+		TableName: aws.String(repo.tableName),
+	}
+	ttlOutput, err := repo.ddbClient.DescribeTimeToLive(ctx, ttlInput)
+	if err != nil {
+		return nil, err
+	}
+	backupsInput := &dynamodb.DescribeContinuousBackupsInput{
+		TableName: aws.String(repo.tableName),
+	}
+	result := &TableReportSummary{
+		Table: output.Table,
+		Ttl:   ttlOutput.TimeToLiveDescription,
+	}
+	if backupsOutput, err := repo.ddbClient.DescribeContinuousBackups(ctx, backupsInput); err == nil {
+		result.ContinuousBackups = backupsOutput.ContinuousBackupsDescription
+	} else if !isAccessDenied(err) {
+		return nil, err
+	}
+	if result.Tags, err = repo.listTags(ctx, output.Table.TableArn); err != nil && !isAccessDenied(err) {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (repo *DdbRepo[T]) listTags(ctx context.Context, arn *string) ([]types.Tag, error) {
+	var result []types.Tag
+	input := &dynamodb.ListTagsOfResourceInput{ResourceArn: arn}
+	for {
+		output, err := repo.ddbClient.ListTagsOfResource(ctx, input)
+		if err != nil {
 			return nil, err
-		} else {
-			return &TableReportSummary{
-				Table: output.Table,
-				Ttl:   ttlOutput.TimeToLiveDescription,
-			}, err
 		}
+		result = append(result, output.Tags...)
+		if output.NextToken == nil {
+			return result, nil
+		}
+		input.NextToken = output.NextToken
 	}
 }
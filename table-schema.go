@@ -0,0 +1,189 @@
+package ddbrepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// SchemaDifferenceKind classifies a difference between the schema derived
+// from the record tags and the live table.
+type SchemaDifferenceKind string
+
+const (
+	SchemaKeyMismatch        SchemaDifferenceKind = "key schema"
+	SchemaAttributeType      SchemaDifferenceKind = "attribute type"
+	SchemaMissingIndex       SchemaDifferenceKind = "missing index"
+	SchemaExtraIndex         SchemaDifferenceKind = "extra index"
+	SchemaIndexKeyMismatch   SchemaDifferenceKind = "index key schema"
+	SchemaProjectionMismatch SchemaDifferenceKind = "projection"
+	SchemaTtlMismatch        SchemaDifferenceKind = "ttl"
+	SchemaBillingMode        SchemaDifferenceKind = "billing mode"
+)
+
+const (
+	globalIndex = "global"
+	localIndex  = "local"
+)
+
+// SchemaDifference is a single difference; Index names the secondary index
+// it concerns, Attribute the attribute or key, and Expected and Actual
+// describe the repo and the table side, empty when absent.
+type SchemaDifference struct {
+	Kind      SchemaDifferenceKind
+	Index     string
+	Attribute string
+	Expected  string
+	Actual    string
+}
+
+func (d SchemaDifference) String() string {
+	var sb strings.Builder
+	sb.WriteString(string(d.Kind))
+	if d.Index != "" {
+		sb.WriteString(" of index " + d.Index)
+	}
+	if d.Attribute != "" {
+		sb.WriteString(" " + d.Attribute)
+	}
+	sb.WriteString(fmt.Sprintf(": expected %q, actual %q", d.Expected, d.Actual))
+	return sb.String()
+}
+
+// SchemaDiff lists the differences between the repo and the live table.
+type SchemaDiff struct {
+	Table       string
+	Differences []SchemaDifference
+}
+
+// Empty tells whether the table matches the repo.
+func (d *SchemaDiff) Empty() bool {
+	return len(d.Differences) == 0
+}
+
+// Err returns an OpError of kind ErrSchemaDrift listing the differences, nil
+// when there are none.
+func (d *SchemaDiff) Err() error {
+	if d.Empty() {
+		return nil
+	}
+	parts := make([]string, 0, len(d.Differences))
+	for _, difference := range d.Differences {
+		parts = append(parts, difference.String())
+	}
+	return &OpError{Op: "CheckSchema", Table: d.Table, Kind: ErrSchemaDrift, Err: errors.New(strings.Join(parts, "; "))}
+}
+
+func (d *SchemaDiff) add(kind SchemaDifferenceKind, index, attribute, expected, actual string) {
+	if expected != actual {
+		d.Differences = append(d.Differences, SchemaDifference{Kind: kind, Index: index, Attribute: attribute, Expected: expected, Actual: actual})
+	}
+}
+
+// CheckSchema compares the schema derived from the record tags with the live
+// table; the billing mode is only compared when it was configured.
+func (repo *DdbRepo[T]) CheckSchema(ctx context.Context) (*SchemaDiff, error) {
+	if err := repo.validateConfig(); err != nil {
+		return nil, err
+	}
+	output, err := repo.ddbClient.DescribeTable(ctx, repo.getDescribeTableInput())
+	if err != nil {
+		return nil, opError("CheckSchema", repo.tableName, nil, err)
+	}
+	ttlOutput, err := repo.ddbClient.DescribeTimeToLive(ctx, &dynamodb.DescribeTimeToLiveInput{TableName: aws.String(repo.tableName)})
+	if err != nil {
+		return nil, opError("CheckSchema", repo.tableName, nil, err)
+	}
+	return repo.diffSchema(output.Table, ttlOutput.TimeToLiveDescription), nil
+}
+
+func (repo *DdbRepo[T]) diffSchema(table *types.TableDescription, ttl *types.TimeToLiveDescription) *SchemaDiff {
+	diff := &SchemaDiff{Table: repo.tableName}
+	diff.addKeySchema(SchemaKeyMismatch, "", repo.keySchema, table.KeySchema)
+	actualTypes := make(map[string]types.ScalarAttributeType, len(table.AttributeDefinitions))
+	for _, ad := range table.AttributeDefinitions {
+		actualTypes[aws.ToString(ad.AttributeName)] = ad.AttributeType
+	}
+	for _, ad := range repo.attributeDefinitions {
+		name := aws.ToString(ad.AttributeName)
+		diff.add(SchemaAttributeType, "", name, string(ad.AttributeType), string(actualTypes[name]))
+	}
+
+	actualGsi := make(map[string]types.GlobalSecondaryIndexDescription, len(table.GlobalSecondaryIndexes))
+	for _, gsi := range table.GlobalSecondaryIndexes {
+		actualGsi[aws.ToString(gsi.IndexName)] = gsi
+	}
+	for _, name := range slices.Sorted(maps.Keys(repo.gsi)) {
+		if actual, found := actualGsi[name]; !found {
+			diff.add(SchemaMissingIndex, name, "", globalIndex, "")
+		} else {
+			diff.addKeySchema(SchemaIndexKeyMismatch, name, repo.gsi[name].KeySchema, actual.KeySchema)
+			diff.add(SchemaProjectionMismatch, name, "", projectionString(repo.gsi[name].Projection), projectionString(actual.Projection))
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(actualGsi)) {
+		if _, found := repo.gsi[name]; !found {
+			diff.add(SchemaExtraIndex, name, "", "", globalIndex)
+		}
+	}
+
+	actualLsi := make(map[string]types.LocalSecondaryIndexDescription, len(table.LocalSecondaryIndexes))
+	for _, lsi := range table.LocalSecondaryIndexes {
+		actualLsi[aws.ToString(lsi.IndexName)] = lsi
+	}
+	for _, name := range slices.Sorted(maps.Keys(repo.lsi)) {
+		if actual, found := actualLsi[name]; !found {
+			diff.add(SchemaMissingIndex, name, "", localIndex, "")
+		} else {
+			diff.addKeySchema(SchemaIndexKeyMismatch, name, repo.lsi[name].KeySchema, actual.KeySchema)
+			diff.add(SchemaProjectionMismatch, name, "", projectionString(repo.lsi[name].Projection), projectionString(actual.Projection))
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(actualLsi)) {
+		if _, found := repo.lsi[name]; !found {
+			diff.add(SchemaExtraIndex, name, "", "", localIndex)
+		}
+	}
+
+	actualTtl := ""
+	if ttl != nil && (ttl.TimeToLiveStatus == types.TimeToLiveStatusEnabled || ttl.TimeToLiveStatus == types.TimeToLiveStatusEnabling) {
+		actualTtl = aws.ToString(ttl.AttributeName)
+	}
+	diff.add(SchemaTtlMismatch, "", "", repo.ttlColumn, actualTtl)
+
+	if repo.throughputConfigured() {
+		diff.add(SchemaBillingMode, "", "", string(repo.billingMode), string(actualBillingMode(table)))
+	}
+	return diff
+}
+
+func actualBillingMode(table *types.TableDescription) types.BillingMode {
+	if table.BillingModeSummary != nil && table.BillingModeSummary.BillingMode != "" {
+		return table.BillingModeSummary.BillingMode
+	}
+	return types.BillingModeProvisioned
+}
+
+func (d *SchemaDiff) addKeySchema(kind SchemaDifferenceKind, index string, expected, actual []types.KeySchemaElement) {
+	expectedHash, expectedRange := keyNames(expected)
+	actualHash, actualRange := keyNames(actual)
+	d.add(kind, index, TagItemHashKey, expectedHash, actualHash)
+	d.add(kind, index, TagItemRangeKey, expectedRange, actualRange)
+}
+
+func projectionString(projection *types.Projection) string {
+	if projection == nil {
+		return ""
+	}
+	attributes := slices.Sorted(slices.Values(projection.NonKeyAttributes))
+	if len(attributes) == 0 {
+		return string(projection.ProjectionType)
+	}
+	return fmt.Sprintf("%v %v", projection.ProjectionType, attributes)
+}
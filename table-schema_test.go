@@ -0,0 +1,89 @@
+package ddbrepo
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/rotmistrk/ddbrepo/ddbrepotest"
+	"github.com/rotmistrk/must"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type orderDriftRecord struct {
+	Customer string    `ddb:"customer,hash-key"`
+	OrderID  int       `ddb:"orderId,range-key"`
+	Status   string    `ddb:"status" ddb-gsi:"byStatus hash-key all"`
+	Legacy   string    `ddb:"legacy" ddb-gsi:"byLegacy hash-key"`
+	Expires  time.Time `ddb:"expires,expire"`
+}
+
+func TestDdbRepo_CheckSchema(t *testing.T) {
+	db := ddbrepotest.NewMemoryDynamoDb()
+	repo := must.Must(New[orderGsiRecord]()).WithTableName("orders").WithDynamoDbApi(db)
+	if _, err := repo.CheckSchema(context.TODO()); !errors.Is(err, ErrTableNotReady) {
+		t.Errorf("CheckSchema() of a missing table error = %v, want %v", err, ErrTableNotReady)
+	}
+	if err := repo.TableCreate(); err != nil {
+		t.Fatal(err)
+	}
+	diff := must.Must(repo.CheckSchema(context.TODO()))
+	if !diff.Empty() || diff.Err() != nil {
+		t.Errorf("CheckSchema() of a matching table = %v", diff.Err())
+	}
+
+	drifted := must.Must(New[orderDriftRecord]()).WithTableName("drifted").WithDynamoDbApi(db).WithBillingMode(types.BillingModePayPerRequest)
+	if err := drifted.TableCreate(); err != nil {
+		t.Fatal(err)
+	}
+	diff = must.Must(repo.WithTableName("drifted").CheckSchema(context.TODO()))
+	want := []SchemaDifference{
+		{Kind: SchemaAttributeType, Attribute: "orderId", Expected: "S", Actual: "N"},
+		{Kind: SchemaAttributeType, Attribute: "placed", Expected: "S"},
+		{Kind: SchemaAttributeType, Attribute: "shop", Expected: "S"},
+		{Kind: SchemaMissingIndex, Index: "byShop", Expected: "global"},
+		{Kind: SchemaIndexKeyMismatch, Index: "byStatus", Attribute: TagItemRangeKey, Expected: "placed"},
+		{Kind: SchemaProjectionMismatch, Index: "byStatus", Expected: "KEYS_ONLY", Actual: "ALL"},
+		{Kind: SchemaExtraIndex, Index: "byLegacy", Actual: "global"},
+		{Kind: SchemaTtlMismatch, Actual: "expires"},
+	}
+	if !reflect.DeepEqual(diff.Differences, want) {
+		t.Errorf("CheckSchema() = %v, want %v", JsonLine(&diff.Differences), JsonLine(&want))
+	}
+	diff = must.Must(repo.WithTableName("drifted").WithCapacity(1, 1).CheckSchema(context.TODO()))
+	billing := SchemaDifference{Kind: SchemaBillingMode, Expected: "PROVISIONED", Actual: "PAY_PER_REQUEST"}
+	if got := diff.Differences[len(diff.Differences)-1]; got != billing {
+		t.Errorf("CheckSchema() with capacity = %v, want %v", got, billing)
+	}
+	if err := diff.Err(); !errors.Is(err, ErrSchemaDrift) {
+		t.Errorf("Err() = %v, want %v", err, ErrSchemaDrift)
+	}
+}
+
+func TestDdbRepo_CheckSchemaKeys(t *testing.T) {
+	db := ddbrepotest.NewMemoryDynamoDb()
+	if err := must.Must(New[listingLsiRecord]()).WithTableName("listings").WithDynamoDbApi(db).TableCreate(); err != nil {
+		t.Fatal(err)
+	}
+	diff := must.Must(must.Must(New[orderGsiRecord]()).WithTableName("listings").WithDynamoDbApi(db).CheckSchema(context.TODO()))
+	var keys, extra []SchemaDifference
+	for _, difference := range diff.Differences {
+		switch difference.Kind {
+		case SchemaKeyMismatch:
+			keys = append(keys, difference)
+		case SchemaExtraIndex:
+			extra = append(extra, difference)
+		}
+	}
+	wantKeys := []SchemaDifference{
+		{Kind: SchemaKeyMismatch, Attribute: TagItemHashKey, Expected: "customer", Actual: "seller"},
+		{Kind: SchemaKeyMismatch, Attribute: TagItemRangeKey, Expected: "orderId", Actual: "sku"},
+	}
+	if !reflect.DeepEqual(keys, wantKeys) {
+		t.Errorf("CheckSchema() key differences = %v, want %v", JsonLine(&keys), JsonLine(&wantKeys))
+	}
+	if len(extra) != 2 || extra[0].Index != "byListed" || extra[0].Actual != "local" {
+		t.Errorf("CheckSchema() extra indexes = %v", JsonLine(&extra))
+	}
+}
@@ -0,0 +1,30 @@
+package ddbrepo
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func (repo *DdbRepo[T]) TableUpdatePointInTimeRecovery() error {
+	return repo.TableUpdatePointInTimeRecoveryCtx(context.TODO())
+}
+
+// TableUpdatePointInTimeRecoveryCtx enables or disables the point-in-time
+// recovery of the table as configured by WithPointInTimeRecovery.
+func (repo *DdbRepo[T]) TableUpdatePointInTimeRecoveryCtx(ctx context.Context) error {
+	if err := repo.validateConfig(); err != nil {
+		return err
+	}
+	input := &dynamodb.UpdateContinuousBackupsInput{
+		TableName: aws.String(repo.tableName),
+		PointInTimeRecoverySpecification: &types.PointInTimeRecoverySpecification{
+			PointInTimeRecoveryEnabled: aws.Bool(repo.pointInTimeRecovery),
+		},
+	}
+	if _, err := repo.ddbClient.UpdateContinuousBackups(ctx, input); err != nil {
+		return opError("TableUpdatePointInTimeRecovery", repo.tableName, nil, err)
+	}
+	return nil
+}
@@ -8,19 +8,25 @@ import (
 )
 
 func (repo *DdbRepo[T]) TableUpdateTtl() error {
+	return repo.TableUpdateTtlCtx(context.TODO())
+}
+
+func (repo *DdbRepo[T]) TableUpdateTtlCtx(ctx context.Context) error {
+	return repo.updateTtl(ctx, repo.ttlColumn, repo.ttlColumn != "")
+}
+
+func (repo *DdbRepo[T]) updateTtl(ctx context.Context, attribute string, enabled bool) error {
 	input := &dynamodb.UpdateTimeToLiveInput{
-		TableName:               aws.String(repo.tableName),
-		TimeToLiveSpecification: &types.TimeToLiveSpecification{},
+		TableName: aws.String(repo.tableName),
+		TimeToLiveSpecification: &types.TimeToLiveSpecification{
+			Enabled: aws.Bool(enabled),
+		},
 	}
-	if repo.ttlColumn != "" {
-		input.TimeToLiveSpecification.AttributeName = aws.String(repo.ttlColumn)
-		input.TimeToLiveSpecification.Enabled = aws.Bool(true)
-	} else {
-		input.TimeToLiveSpecification.Enabled = aws.Bool(false)
+	if attribute != "" {
+		input.TimeToLiveSpecification.AttributeName = aws.String(attribute)
 	}
-	if _, err := repo.ddbClient.UpdateTimeToLive(context.TODO(), input); err != nil {
+	if _, err := repo.ddbClient.UpdateTimeToLive(ctx, input); err != nil {
 		return err
-	} else {
-		return nil
 	}
+	return nil
 }
@@ -0,0 +1,101 @@
+package ddbrepo
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"strings"
+)
+
+var ErrInvalidCursor = errors.New("invalid pagination cursor")
+
+// WithCursorSecret makes page cursors HMAC signed; cursors that are not
+// signed with the secret are rejected.
+func (repo DdbRepo[T]) WithCursorSecret(secret []byte) *DdbRepo[T] {
+	repo.cursorSecret = secret
+	return &repo
+}
+
+func cursorSignature(payload string, secret []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func encodeCursor(key map[string]types.AttributeValue, secret []byte) (string, error) {
+	if len(key) == 0 {
+		return "", nil
+	}
+	plain := make(map[string]map[string]string, len(key))
+	for name, value := range key {
+		switch v := value.(type) {
+		case *types.AttributeValueMemberS:
+			plain[name] = map[string]string{"S": v.Value}
+		case *types.AttributeValueMemberN:
+			plain[name] = map[string]string{"N": v.Value}
+		case *types.AttributeValueMemberB:
+			plain[name] = map[string]string{"B": base64.StdEncoding.EncodeToString(v.Value)}
+		default:
+			return "", fmt.Errorf("unsupported key attribute type %T for %v", value, name)
+		}
+	}
+	data, err := json.Marshal(plain)
+	if err != nil {
+		return "", err
+	}
+	cursor := base64.RawURLEncoding.EncodeToString(data)
+	if len(secret) > 0 {
+		cursor += "." + cursorSignature(cursor, secret)
+	}
+	return cursor, nil
+}
+
+func decodeCursor(cursor string, secret []byte) (map[string]types.AttributeValue, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	payload, signature, signed := strings.Cut(cursor, ".")
+	if len(secret) > 0 {
+		if !signed || !hmac.Equal([]byte(signature), []byte(cursorSignature(payload, secret))) {
+			return nil, fmt.Errorf("%w: bad signature", ErrInvalidCursor)
+		}
+	} else if signed {
+		return nil, fmt.Errorf("%w: unexpected signature", ErrInvalidCursor)
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	plain := make(map[string]map[string]string)
+	if err := json.Unmarshal(data, &plain); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	result := make(map[string]types.AttributeValue, len(plain))
+	for name, value := range plain {
+		if len(value) != 1 {
+			return nil, fmt.Errorf("%w: malformed attribute %v", ErrInvalidCursor, name)
+		}
+		switch {
+		case value["S"] != "":
+			result[name] = &types.AttributeValueMemberS{Value: value["S"]}
+		case value["N"] != "":
+			result[name] = &types.AttributeValueMemberN{Value: value["N"]}
+		case value["B"] != "":
+			if b, err := base64.StdEncoding.DecodeString(value["B"]); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+			} else {
+				result[name] = &types.AttributeValueMemberB{Value: b}
+			}
+		default:
+			return nil, fmt.Errorf("%w: malformed attribute %v", ErrInvalidCursor, name)
+		}
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("%w: empty key", ErrInvalidCursor)
+	}
+	return result, nil
+}
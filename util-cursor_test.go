@@ -0,0 +1,41 @@
+package ddbrepo
+
+import (
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"reflect"
+	"testing"
+)
+
+func TestCursor_RoundTrip(t *testing.T) {
+	key := map[string]types.AttributeValue{
+		"id":     &types.AttributeValueMemberS{Value: "a/b+c"},
+		"part":   &types.AttributeValueMemberN{Value: "42"},
+		"binary": &types.AttributeValueMemberB{Value: []byte{0, 1, 255}},
+	}
+	tests := []struct {
+		name   string
+		secret []byte
+	}{
+		{"plain", nil},
+		{"signed", []byte("secret")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cursor, err := encodeCursor(key, tt.secret)
+			if err != nil {
+				t.Fatalf("encodeCursor() error = %v", err)
+			}
+			decoded, err := decodeCursor(cursor, tt.secret)
+			if err != nil || !reflect.DeepEqual(decoded, key) {
+				t.Errorf("decodeCursor() = %v, %v, want %v", decoded, err, key)
+			}
+			if _, err := decodeCursor(cursor[1:], tt.secret); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("decodeCursor() of a damaged cursor error = %v", err)
+			}
+		})
+	}
+	if cursor, err := encodeCursor(nil, nil); cursor != "" || err != nil {
+		t.Errorf("encodeCursor(nil) = %q, %v", cursor, err)
+	}
+}
@@ -0,0 +1,44 @@
+package ddbrepo
+
+import (
+	"encoding/base64"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"math/big"
+	"strings"
+)
+
+func keyValueString(value types.AttributeValue) string {
+	switch v := value.(type) {
+	case *types.AttributeValueMemberS:
+		return "S:" + v.Value
+	case *types.AttributeValueMemberN:
+		if n, ok := new(big.Rat).SetString(v.Value); ok {
+			return "N:" + n.RatString()
+		}
+		return "N:" + v.Value
+	case *types.AttributeValueMemberB:
+		return "B:" + base64.StdEncoding.EncodeToString(v.Value)
+	default:
+		return "?"
+	}
+}
+
+func keyOfItem(keySchema []types.KeySchemaElement, item map[string]types.AttributeValue) map[string]types.AttributeValue {
+	result := make(map[string]types.AttributeValue, len(keySchema))
+	for _, key := range keySchema {
+		name := aws.ToString(key.AttributeName)
+		if value, found := item[name]; found {
+			result[name] = value
+		}
+	}
+	return result
+}
+
+func itemKeyString(keySchema []types.KeySchemaElement, item map[string]types.AttributeValue) string {
+	parts := make([]string, 0, len(keySchema))
+	for _, key := range keySchema {
+		parts = append(parts, keyValueString(item[aws.ToString(key.AttributeName)]))
+	}
+	return strings.Join(parts, "\x00")
+}
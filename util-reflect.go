@@ -3,12 +3,29 @@ package ddbrepo
 import (
 	"errors"
 	"fmt"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"reflect"
+	"strings"
+	"time"
 )
 
 type listStructFieldsCallback func(fldNum int, field *reflect.StructField, value *reflect.Value) error
 
-func listStructFieldsCbk(target interface{}, cbk listStructFieldsCallback) (err error) {
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	avMarshalerType   = reflect.TypeOf((*attributevalue.Marshaler)(nil)).Elem()
+	avUnmarshalerType = reflect.TypeOf((*attributevalue.Unmarshaler)(nil)).Elem()
+)
+
+func listStructFieldsCbk(target interface{}, cbk listStructFieldsCallback) error {
+	return listFields(target, false, cbk)
+}
+
+func allocStructFieldsCbk(target interface{}, cbk listStructFieldsCallback) error {
+	return listFields(target, true, cbk)
+}
+
+func listFields(target interface{}, alloc bool, cbk listStructFieldsCallback) (err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			err = errors.New(fmt.Sprintf("impossible to (un)marshal to %t: %v", target, r))
@@ -21,13 +38,130 @@ func listStructFieldsCbk(target interface{}, cbk listStructFieldsCallback) (err
 	if targetValue.Kind() != reflect.Struct || !targetValue.CanAddr() {
 		return errors.New("struct pointer expected, " + targetValue.Kind().String() + " receved")
 	}
+	return listFieldsOf(targetValue, alloc, cbk)
+}
+
+func listFieldsOf(targetValue reflect.Value, alloc bool, cbk listStructFieldsCallback) error {
+	pos := 0
+	return walkStructFields(targetValue, alloc, cbk, &pos)
+}
+
+func walkStructFields(targetValue reflect.Value, alloc bool, cbk listStructFieldsCallback, pos *int) error {
 	targetType := targetValue.Type()
 	for i, I := 0, targetValue.NumField(); i < I; i++ {
 		field := targetType.Field(i)
 		value := targetValue.Field(i)
-		if err = cbk(i, &field, &value); err != nil {
-			return
+		if embedded, flatten := embeddedStruct(&field, value, alloc); flatten {
+			if embedded.IsValid() {
+				if err := walkStructFields(embedded, alloc, cbk, pos); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if err := cbk(*pos, &field, &value); err != nil {
+			return err
+		}
+		*pos++
+	}
+	return nil
+}
+
+func embeddedStruct(field *reflect.StructField, value reflect.Value, alloc bool) (reflect.Value, bool) {
+	fieldType, flatten := embeddedType(field)
+	if fieldType == nil {
+		return reflect.Value{}, flatten
+	}
+	if value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			if !alloc || !value.CanSet() {
+				return reflect.Value{}, true
+			}
+			value.Set(reflect.New(fieldType))
+		}
+		value = value.Elem()
+	}
+	return value, true
+}
+
+func embeddedType(field *reflect.StructField) (reflect.Type, bool) {
+	if !field.Anonymous {
+		return nil, false
+	}
+	tag, tagged := field.Tag.Lookup(TagDdb)
+	if tagged {
+		options := strings.Split(tag, ",")
+		if strings.TrimSpace(options[0]) != "" {
+			return nil, false
 		}
+		for _, option := range options[1:] {
+			if strings.TrimSpace(option) == TagItemIgnore {
+				return nil, true
+			}
+		}
+	}
+	fieldType := field.Type
+	if fieldType.Kind() == reflect.Pointer {
+		fieldType = fieldType.Elem()
+	}
+	if !isNestedStruct(fieldType) {
+		return nil, false
+	}
+	return fieldType, true
+}
+
+func isNestedStruct(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct || t == timeType {
+		return false
+	}
+	pointer := reflect.PointerTo(t)
+	return !t.Implements(avMarshalerType) && !pointer.Implements(avMarshalerType) && !pointer.Implements(avUnmarshalerType)
+}
+
+func needsCodec(props parseProps, t reflect.Type) bool {
+	for {
+		if _, found := converterFor(props, t); found {
+			return true
+		} else if t.Implements(avMarshalerType) || reflect.PointerTo(t).Implements(avUnmarshalerType) {
+			return false
+		}
+		switch t.Kind() {
+		case reflect.Struct:
+			return t == timeType || isNestedStruct(t)
+		case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map:
+			t = t.Elem()
+		default:
+			return false
+		}
+	}
+}
+
+func nestedField(props parseProps, parent reflect.Type, name string) (string, reflect.Type) {
+	for parent != nil && parent.Kind() == reflect.Pointer {
+		parent = parent.Elem()
+	}
+	if parent == nil {
+		return name, nil
+	} else if parent.Kind() == reflect.Map {
+		return name, parent.Elem()
+	} else if !isNestedStruct(parent) {
+		return name, nil
+	}
+	codec, err := codecOf(props, parent)
+	if err != nil {
+		return name, nil
+	} else if field, found := codec.byName[name]; found {
+		return field.spec.name, field.field.Type
+	}
+	return name, nil
+}
+
+func elementType(t reflect.Type) reflect.Type {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+		return t.Elem()
 	}
-	return
+	return nil
 }
@@ -0,0 +1,115 @@
+package ddbrepo
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"strconv"
+	"time"
+)
+
+// TimeEncoding is how time.Time values are stored. TimeDefault stores keys as
+// unix seconds and other fields as RFC 3339 strings with nanoseconds;
+// expiration fields are unix seconds unless tagged otherwise.
+type TimeEncoding string
+
+const (
+	TimeDefault     TimeEncoding = ""
+	TimeUnix        TimeEncoding = "unix"
+	TimeUnixMilli   TimeEncoding = "unixmilli"
+	TimeUnixNano    TimeEncoding = "unixnano"
+	TimeRFC3339     TimeEncoding = "rfc3339"
+	TimeRFC3339Nano TimeEncoding = "rfc3339nano"
+)
+
+func (e TimeEncoding) isNumeric() bool {
+	return e == TimeUnix || e == TimeUnixMilli || e == TimeUnixNano
+}
+
+func (e TimeEncoding) isValid() bool {
+	return e.isNumeric() || e == TimeRFC3339 || e == TimeRFC3339Nano
+}
+
+func encodeTime(t time.Time, encoding TimeEncoding) (types.AttributeValue, error) {
+	switch encoding {
+	case TimeUnix:
+		return &types.AttributeValueMemberN{Value: strconv.FormatInt(t.Unix(), 10)}, nil
+	case TimeUnixMilli:
+		return &types.AttributeValueMemberN{Value: strconv.FormatInt(t.UnixMilli(), 10)}, nil
+	case TimeUnixNano:
+		return &types.AttributeValueMemberN{Value: strconv.FormatInt(t.UnixNano(), 10)}, nil
+	case TimeRFC3339:
+		return &types.AttributeValueMemberS{Value: t.Format(time.RFC3339)}, nil
+	case TimeRFC3339Nano:
+		return &types.AttributeValueMemberS{Value: t.Format(time.RFC3339Nano)}, nil
+	}
+	return nil, fmt.Errorf("unknown time encoding %q", encoding)
+}
+
+// decodeTime reads strings as RFC 3339 whatever the encoding is.
+func decodeTime(av types.AttributeValue, encoding TimeEncoding) (time.Time, error) {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberN:
+		n, err := strconv.ParseInt(v.Value, 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		switch encoding {
+		case TimeUnixMilli:
+			return time.UnixMilli(n).UTC(), nil
+		case TimeUnixNano:
+			return time.Unix(0, n).UTC(), nil
+		default:
+			return time.Unix(n, 0).UTC(), nil
+		}
+	case *types.AttributeValueMemberS:
+		return time.Parse(time.RFC3339Nano, v.Value)
+	}
+	return time.Time{}, fmt.Errorf("can't unmarshal %T into time", av)
+}
+
+type timeProps interface {
+	TimeEncoding() TimeEncoding
+}
+
+func timeEncodingOf(props parseProps, spec *fieldSpec) TimeEncoding {
+	if spec != nil && spec.timeFormat != "" {
+		return spec.timeFormat
+	} else if spec != nil && spec.IsTtlField() {
+		return TimeUnix
+	}
+	encoding := DefaultTimeEncoding
+	if timeProps, ok := props.(timeProps); ok {
+		encoding = timeProps.TimeEncoding()
+	}
+	if encoding == TimeDefault {
+		return defaultTimeEncoding(spec != nil && spec.isAttributeKey())
+	}
+	return encoding
+}
+
+func defaultTimeEncoding(key bool) TimeEncoding {
+	if key {
+		return TimeUnix
+	}
+	return TimeRFC3339Nano
+}
+
+// WithTimeEncoding sets the encoding of time fields without a time tag.
+func (repo DdbRepo[T]) WithTimeEncoding(encoding TimeEncoding) *DdbRepo[T] {
+	repo.timeEncoding = encoding
+	repo.redefineAttributes()
+	return &repo
+}
+
+func (repo *DdbRepo[T]) TimeEncoding() TimeEncoding {
+	return repo.timeEncoding
+}
+
+// ExpirationValue encodes a time like the expiration field of the record,
+// as unix seconds unless the field is a time.Time tagged otherwise.
+func (repo *DdbRepo[T]) ExpirationValue(at time.Time) (types.AttributeValue, error) {
+	if repo.ttlTimeSpec == nil {
+		return encodeTime(at, TimeUnix)
+	}
+	return encodeTime(at, timeEncodingOf(repo, repo.ttlTimeSpec))
+}
@@ -0,0 +1,129 @@
+package ddbrepo
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/rotmistrk/must"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_encodeTime(t *testing.T) {
+	when := time.Date(2024, 2, 29, 12, 30, 15, 250000000, time.UTC)
+	tests := []struct {
+		encoding TimeEncoding
+		want     types.AttributeValue
+		restored time.Time
+	}{
+		{TimeUnix, &types.AttributeValueMemberN{Value: "1709209815"}, when.Truncate(time.Second)},
+		{TimeUnixMilli, &types.AttributeValueMemberN{Value: "1709209815250"}, when},
+		{TimeUnixNano, &types.AttributeValueMemberN{Value: "1709209815250000000"}, when},
+		{TimeRFC3339, &types.AttributeValueMemberS{Value: "2024-02-29T12:30:15Z"}, when.Truncate(time.Second)},
+		{TimeRFC3339Nano, &types.AttributeValueMemberS{Value: "2024-02-29T12:30:15.25Z"}, when},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.encoding), func(t *testing.T) {
+			got, err := encodeTime(when, tt.encoding)
+			if err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("encodeTime() = %v, %v, want %v", got, err, tt.want)
+			}
+			if restored, err := decodeTime(got, tt.encoding); err != nil || !restored.Equal(tt.restored) {
+				t.Errorf("decodeTime() = %v, %v, want %v", restored, err, tt.restored)
+			}
+		})
+	}
+	if _, err := encodeTime(when, "julian"); err == nil {
+		t.Errorf("encodeTime() accepted an unknown encoding")
+	}
+}
+
+type shiftRecord struct {
+	Worker  string    `ddb:"worker,hash-key"`
+	Start   time.Time `ddb:"start,range-key"`
+	End     time.Time `ddb:"end,time=unix"`
+	Expires time.Time `ddb:"expires,expire,time=unixmilli"`
+	Break   time.Time `ddb:"break"`
+}
+
+func TestTimeEncoding(t *testing.T) {
+	repo := must.Must(New[shiftRecord]())
+	millis := repo.WithTimeEncoding(TimeUnixMilli)
+	rfc := repo.WithTimeEncoding(TimeRFC3339Nano)
+	start := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+	record := &shiftRecord{Worker: "w1", Start: start, End: start.Add(8 * time.Hour), Expires: start.Add(24 * time.Hour), Break: start.Add(4 * time.Hour)}
+
+	startType := func(repo *DdbRepo[shiftRecord]) types.ScalarAttributeType {
+		for _, def := range repo.attributeDefinitions {
+			if aws.ToString(def.AttributeName) == "start" {
+				return def.AttributeType
+			}
+		}
+		return ""
+	}
+	if got := startType(repo); got != types.ScalarAttributeTypeN {
+		t.Errorf("default start attribute type = %v, want N", got)
+	}
+	if got := startType(millis); got != types.ScalarAttributeTypeN {
+		t.Errorf("unixmilli start attribute type = %v, want N", got)
+	}
+	if got := startType(rfc); got != types.ScalarAttributeTypeS {
+		t.Errorf("rfc3339nano start attribute type = %v, want S", got)
+	}
+
+	key := must.Must(MarshalKey(millis, record, ""))
+	if want := (&types.AttributeValueMemberN{Value: "1709280000000"}); !reflect.DeepEqual(key["start"], want) {
+		t.Errorf("MarshalKey() start = %v, want %v", key["start"], want)
+	}
+	item := must.Must(Marshal(repo, record))
+	want := map[string]types.AttributeValue{
+		"worker":  &types.AttributeValueMemberS{Value: "w1"},
+		"start":   &types.AttributeValueMemberN{Value: "1709280000"},
+		"end":     &types.AttributeValueMemberN{Value: "1709308800"},
+		"expires": &types.AttributeValueMemberN{Value: "1709366400000"},
+		"break":   &types.AttributeValueMemberS{Value: "2024-03-01T12:00:00Z"},
+	}
+	if !reflect.DeepEqual(item, want) {
+		t.Errorf("Marshal() = %v, want %v", JsonLine(&item), JsonLine(&want))
+	}
+	var restored shiftRecord
+	if err := Unmarshal(repo, &restored, item); err != nil || !reflect.DeepEqual(&restored, record) {
+		t.Errorf("Unmarshal() = %+v, %v, want %+v", restored, err, record)
+	}
+
+	before := time.Now().Truncate(time.Millisecond)
+	_, values, err := InsertOrReplaceExpired(repo, item)
+	if expires, decodeErr := decodeTime(values[":expires"], TimeUnixMilli); err != nil || decodeErr != nil || expires.Before(before) || time.Since(expires) > time.Minute {
+		t.Errorf("InsertOrReplaceExpired() values = %v, %v, want the current time in milliseconds", JsonLine(&values), err)
+	}
+	input, _, err := buildQuery(millis, record, []QueryArg{RangeKeyGreaterThan(start)})
+	if err != nil || !reflect.DeepEqual(input.ExpressionAttributeValues[":rk0"], key["start"]) {
+		t.Errorf("buildQuery() range value = %v, %v, want %v", input.ExpressionAttributeValues[":rk0"], err, key["start"])
+	}
+	update := repo.NewUpdate().Set("End", start)
+	if _, _, err := update.Expression(); err != nil || !reflect.DeepEqual(update.values[":v0"], &types.AttributeValueMemberN{Value: "1709280000"}) {
+		t.Errorf("Set() of a time = %v, %v", update.values[":v0"], err)
+	}
+
+	type stringExpiry struct {
+		ID      string    `ddb:"id,hash-key"`
+		Expires time.Time `ddb:"expires,expire,time=rfc3339"`
+	}
+	if _, err := New[stringExpiry](); err == nil {
+		t.Errorf("New() accepted an expiration field stored as a string")
+	}
+	type defaultExpiry struct {
+		ID      string    `ddb:"id,hash-key"`
+		Expires time.Time `ddb:"expires,expire"`
+	}
+	expiry := must.Must(New[defaultExpiry]())
+	when := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+	for _, repo := range []*DdbRepo[defaultExpiry]{expiry, expiry.WithTimeEncoding(TimeUnixMilli)} {
+		if item, err := Marshal(repo, &defaultExpiry{ID: "e", Expires: when}); err != nil || !reflect.DeepEqual(item["expires"], &types.AttributeValueMemberN{Value: "1709280000"}) {
+			t.Errorf("Marshal() expiration = %v, %v, want unix seconds", JsonLine(&item), err)
+		}
+		if _, values, err := InsertOrReplaceExpired(repo, nil); err != nil || reflect.TypeOf(values[":expires"]) != reflect.TypeOf(&types.AttributeValueMemberN{}) {
+			t.Errorf("InsertOrReplaceExpired() values = %v, %v, want unix seconds", JsonLine(&values), err)
+		}
+	}
+}